@@ -0,0 +1,134 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/v3"
+)
+
+// WriteFile atomically writes data to the named file, interpreting the path
+// relative to ctx.Dir (see AbsPath). The data is written to a temporary file
+// in the same directory, synced to disk and then renamed over the target,
+// so that readers never observe a partially written file, even if the
+// process is interrupted or the disk fills up. The temporary file is named
+// so that IsTempFile reports true for it. The file is given the permissions
+// returned by ctx.FileMode(perm), whatever the process umask.
+//
+// Symbolic links are followed, so that the file they point to is replaced
+// rather than the link. Targets that exist but are not regular files, such
+// as /dev/stdout or a named pipe, cannot be replaced and are written to
+// directly.
+func (ctx *Context) WriteFile(path string, data []byte, perm os.FileMode) error {
+	file, err := ctx.createAtomicFile(path, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.abort()
+		return errors.Annotatef(err, "writing %q", file.path)
+	}
+	return file.commit()
+}
+
+// IsTempFile reports whether name is the name of a temporary file created
+// while writing a file atomically, with WriteFile, WriteFileFunc or an
+// Output. Such files are only complete once they have been renamed, so
+// commands that read a directory that another process may be writing to
+// should skip them.
+func IsTempFile(name string) bool {
+	base := filepath.Base(name)
+	return strings.HasPrefix(base, tempFilePrefix) && strings.HasSuffix(base, tempFileSuffix)
+}
+
+const (
+	tempFilePrefix = "."
+	tempFileSuffix = ".tmp"
+)
+
+// WriteFileFunc is like WriteFile, but the contents of the file are provided
+// by calling write. If write returns an error, the target file is left
+// untouched.
+func (ctx *Context) WriteFileFunc(path string, perm os.FileMode, write func(io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return errors.Trace(err)
+	}
+	return ctx.WriteFile(path, buf.Bytes(), perm)
+}
+
+// atomicFile is a file being written atomically, for output that is
+// produced incrementally. The contents are written to a temporary file in
+// the target's directory, which only replaces the target when commit is
+// called. Targets that are not regular files are written directly.
+type atomicFile struct {
+	file   *os.File
+	path   string
+	perm   os.FileMode
+	direct bool
+}
+
+// createAtomicFile starts writing the named file atomically, interpreting
+// the path relative to ctx.Dir.
+func (ctx *Context) createAtomicFile(path string, perm os.FileMode) (*atomicFile, error) {
+	path = ctx.AbsPath(path)
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if info, err := os.Stat(path); err == nil && !info.Mode().IsRegular() {
+		file, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return nil, errors.Annotatef(err, "writing %q", path)
+		}
+		return &atomicFile{file: file, path: path, direct: true}, nil
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), tempFilePrefix+filepath.Base(path)+".*"+tempFileSuffix)
+	if err != nil {
+		return nil, errors.Annotatef(err, "writing %q", path)
+	}
+	return &atomicFile{file: file, path: path, perm: ctx.FileMode(perm)}, nil
+}
+
+// Write implements io.Writer.
+func (f *atomicFile) Write(data []byte) (int, error) {
+	return f.file.Write(data)
+}
+
+// commit replaces the target file with the contents written so far.
+func (f *atomicFile) commit() error {
+	if f.direct {
+		return errors.Annotatef(f.file.Close(), "writing %q", f.path)
+	}
+	err := f.file.Chmod(f.perm)
+	if err == nil {
+		err = f.file.Sync()
+	}
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = utils.ReplaceFile(f.file.Name(), f.path)
+	}
+	if err != nil {
+		_ = os.Remove(f.file.Name())
+		return errors.Annotatef(err, "writing %q", f.path)
+	}
+	return nil
+}
+
+// abort discards the contents written so far, leaving the target file
+// untouched. Anything already written directly cannot be taken back.
+func (f *atomicFile) abort() {
+	_ = f.file.Close()
+	if !f.direct {
+		_ = os.Remove(f.file.Name())
+	}
+}
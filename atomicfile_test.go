@@ -0,0 +1,204 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/juju/testing"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type AtomicFileSuite struct {
+	testing.IsolationSuite
+
+	ctx *cmd.Context
+}
+
+var _ = gc.Suite(&AtomicFileSuite{})
+
+func (s *AtomicFileSuite) SetUpTest(c *gc.C) {
+	s.IsolationSuite.SetUpTest(c)
+	s.ctx = cmdtesting.Context(c)
+}
+
+func (s *AtomicFileSuite) assertDirContents(c *gc.C, expected ...string) {
+	entries, err := ioutil.ReadDir(s.ctx.Dir)
+	c.Assert(err, gc.IsNil)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	c.Assert(names, gc.DeepEquals, expected)
+}
+
+func (s *AtomicFileSuite) TestWriteFile(c *gc.C) {
+	err := s.ctx.WriteFile("index.json", []byte("{}\n"), 0600)
+	c.Assert(err, gc.IsNil)
+
+	path := filepath.Join(s.ctx.Dir, "index.json")
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(data), gc.Equals, "{}\n")
+	info, err := os.Stat(path)
+	c.Assert(err, gc.IsNil)
+	c.Assert(info.Mode().Perm(), gc.Equals, os.FileMode(0600))
+	s.assertDirContents(c, "index.json")
+}
+
+func (s *AtomicFileSuite) TestWriteFileReplaces(c *gc.C) {
+	path := filepath.Join(s.ctx.Dir, "index.json")
+	err := ioutil.WriteFile(path, []byte("old"), 0644)
+	c.Assert(err, gc.IsNil)
+
+	err = s.ctx.WriteFile(path, []byte("new"), 0644)
+	c.Assert(err, gc.IsNil)
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(data), gc.Equals, "new")
+	s.assertDirContents(c, "index.json")
+}
+
+func (s *AtomicFileSuite) TestWriteFileFollowsSymlink(c *gc.C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("symlinks need privileges on windows")
+	}
+	target := filepath.Join(c.MkDir(), "index.json")
+	err := ioutil.WriteFile(target, []byte("old"), 0644)
+	c.Assert(err, gc.IsNil)
+	link := filepath.Join(s.ctx.Dir, "index.json")
+	err = os.Symlink(target, link)
+	c.Assert(err, gc.IsNil)
+
+	err = s.ctx.WriteFile("index.json", []byte("new"), 0644)
+	c.Assert(err, gc.IsNil)
+	info, err := os.Lstat(link)
+	c.Assert(err, gc.IsNil)
+	c.Check(info.Mode()&os.ModeSymlink, gc.Equals, os.ModeSymlink)
+	data, err := ioutil.ReadFile(target)
+	c.Assert(err, gc.IsNil)
+	c.Check(string(data), gc.Equals, "new")
+	entries, err := ioutil.ReadDir(filepath.Dir(target))
+	c.Assert(err, gc.IsNil)
+	c.Check(entries, gc.HasLen, 1)
+}
+
+func (s *AtomicFileSuite) TestWriteFileSpecialFile(c *gc.C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("no /dev/null on windows")
+	}
+	err := s.ctx.WriteFile(os.DevNull, []byte("discarded"), 0644)
+	c.Assert(err, gc.IsNil)
+	info, err := os.Stat(os.DevNull)
+	c.Assert(err, gc.IsNil)
+	c.Check(info.Mode()&os.ModeCharDevice, gc.Equals, os.ModeCharDevice)
+}
+
+func (s *AtomicFileSuite) TestWriteFileMissingDir(c *gc.C) {
+	err := s.ctx.WriteFile("missing/index.json", []byte("{}"), 0644)
+	c.Assert(err, gc.ErrorMatches, `writing ".*/missing/index.json": open .*/missing/\.index\.json\..*\.tmp: no such file or directory`)
+}
+
+func (s *AtomicFileSuite) TestWriteFileFunc(c *gc.C) {
+	err := s.ctx.WriteFileFunc("products.json", 0644, func(w io.Writer) error {
+		_, err := fmt.Fprint(w, "products")
+		return err
+	})
+	c.Assert(err, gc.IsNil)
+	data, err := ioutil.ReadFile(filepath.Join(s.ctx.Dir, "products.json"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(data), gc.Equals, "products")
+}
+
+func (s *AtomicFileSuite) TestWriteFileFuncErrorLeavesTarget(c *gc.C) {
+	path := filepath.Join(s.ctx.Dir, "products.json")
+	err := ioutil.WriteFile(path, []byte("old"), 0644)
+	c.Assert(err, gc.IsNil)
+
+	err = s.ctx.WriteFileFunc("products.json", 0644, func(w io.Writer) error {
+		fmt.Fprint(w, "partial")
+		return errors.New("disk full")
+	})
+	c.Assert(err, gc.ErrorMatches, "disk full")
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(data), gc.Equals, "old")
+	s.assertDirContents(c, "products.json")
+}
+
+func (s *AtomicFileSuite) TestIsTempFile(c *gc.C) {
+	c.Check(cmd.IsTempFile(".index.json.123456.tmp"), gc.Equals, true)
+	c.Check(cmd.IsTempFile("/metadata/streams/v1/.index.json.123456.tmp"), gc.Equals, true)
+	c.Check(cmd.IsTempFile("index.json"), gc.Equals, false)
+	c.Check(cmd.IsTempFile(".index.json"), gc.Equals, false)
+	c.Check(cmd.IsTempFile("index.json.tmp"), gc.Equals, false)
+}
+
+func (s *AtomicFileSuite) TestTempFileNamespaced(c *gc.C) {
+	// Check the temporary file a streamed output writes while it is in
+	// progress.
+	var tempFiles []string
+	command := &StreamOutputCommand{records: streamRecords, afterWrite: func(ctx *cmd.Context, i int) {
+		entries, err := ioutil.ReadDir(ctx.Dir)
+		c.Assert(err, gc.IsNil)
+		for _, entry := range entries {
+			tempFiles = append(tempFiles, entry.Name())
+		}
+	}}
+	code := cmd.Main(command, s.ctx, []string{"--format", "json", "-o", "products.json"})
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(tempFiles, gc.HasLen, 2)
+	c.Assert(tempFiles[0], gc.Matches, `\.products\.json\..*\.tmp`)
+	c.Assert(cmd.IsTempFile(tempFiles[0]), gc.Equals, true)
+	s.assertDirContents(c, "products.json")
+}
+
+func (s *AtomicFileSuite) TestConcurrentWriters(c *gc.C) {
+	contents := make(map[string]bool)
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		data := fmt.Sprintf("writer %d", i)
+		contents[data] = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- s.ctx.WriteFile("index.json", []byte(data), 0644)
+		}()
+	}
+	// A reader that skips temporary files only ever sees complete files.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			entries, err := ioutil.ReadDir(s.ctx.Dir)
+			c.Check(err, gc.IsNil)
+			for _, entry := range entries {
+				if cmd.IsTempFile(entry.Name()) {
+					continue
+				}
+				data, err := ioutil.ReadFile(filepath.Join(s.ctx.Dir, entry.Name()))
+				c.Check(err, gc.IsNil)
+				c.Check(contents[string(data)], gc.Equals, true, gc.Commentf("read %q", data))
+			}
+		}
+	}()
+	wg.Wait()
+	<-done
+	close(errs)
+	for err := range errs {
+		c.Check(err, gc.IsNil)
+	}
+	s.assertDirContents(c, "index.json")
+}
@@ -0,0 +1,47 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"runtime"
+)
+
+// These variables describe the build of the running binary. They are empty
+// unless they are set at link time, for example:
+//
+//	go build -ldflags "-X github.com/juju/cmd/v3.BuildVersion=1.2.3 \
+//	    -X github.com/juju/cmd/v3.BuildCommit=$(git rev-parse HEAD) \
+//	    -X github.com/juju/cmd/v3.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// A SuperCommand created without SuperCommandParams.Version uses
+// BuildVersion as its version.
+var (
+	BuildVersion string
+	BuildCommit  string
+	BuildDate    string
+)
+
+// BuildInfo describes the build of the running binary. It is the output
+// of the default version subcommand when it is passed --all and no
+// SuperCommandParams.VersionDetail was given.
+type BuildInfo struct {
+	Version   string `yaml:"version" json:"version"`
+	GitCommit string `yaml:"git-commit,omitempty" json:"git-commit,omitempty"`
+	BuildDate string `yaml:"build-date,omitempty" json:"build-date,omitempty"`
+	GoVersion string `yaml:"go-version" json:"go-version"`
+}
+
+// CurrentBuildInfo returns the build information of the running binary,
+// reporting the given version, or BuildVersion if it is empty.
+func CurrentBuildInfo(version string) BuildInfo {
+	if version == "" {
+		version = BuildVersion
+	}
+	return BuildInfo{
+		Version:   version,
+		GitCommit: BuildCommit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/juju/ansiterm"
 	"github.com/juju/gnuflag"
@@ -108,6 +109,13 @@ func (c *CommandBase) AllowInterspersedFlags() bool {
 // Context represents the run context of a Command. Command implementations
 // should interpret file names relative to Dir (see AbsPath below), and print
 // output and errors to Stdout and Stderr respectively.
+//
+// While Main runs a command, Stdout and Stderr are safe for concurrent use:
+// writes to each are serialised and line buffered, so that a line written
+// in a single call, as with fmt.Fprintln, is never interleaved with other
+// output. The Context methods that write to Stdout or Stderr (Linef,
+// Infof, Verbosef) write each line in a single call, and are also safe for
+// concurrent use when the command is not run by Main.
 type Context struct {
 	context.Context
 	Dir              string
@@ -118,7 +126,14 @@ type Context struct {
 	outputFormatUsed bool
 	quiet            bool
 	verbose          bool
+	verbosity        Verbosity
 	serialisable     bool
+	eventLog         *eventLog
+	interrupted      chan struct{}
+	fileMode         os.FileMode
+	noColor          bool
+	phaseTimings     *phaseTimings
+	rootChecked      bool
 }
 
 // With returns a command context with the specified context.Context.
@@ -142,12 +157,128 @@ func (ctx *Context) IsSerial() bool {
 	return ctx.serialisable
 }
 
-func (ctx *Context) write(format string, params ...interface{}) {
+// writeMutex serialises the line writes made through Context methods, so
+// that output from concurrent goroutines is never interleaved mid-line.
+var writeMutex sync.Mutex
+
+// syncWriter makes a writer safe for concurrent use. Writes are
+// serialised, and line buffered: the end of a write that does not finish
+// a line is held back until a later write finishes it, or until the
+// writer is flushed, so that the underlying writer is given whole lines.
+type syncWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	partial []byte
+}
+
+// Write implements io.Writer.
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	end := bytes.LastIndexByte(p, '\n') + 1
+	if end == 0 {
+		w.partial = append(w.partial, p...)
+		return len(p), nil
+	}
+	line := append(w.partial, p[:end]...)
+	_, err := w.w.Write(line)
+	w.partial = append(line[:0], p[end:]...)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeNow writes any partial line held back, followed by s, without
+// waiting for s to finish a line. It is used for output to a terminal,
+// such as progress bars, that is redrawn in place.
+func (w *syncWriter) writeNow(s string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.partial = append(w.partial, s...)
+	w.w.Write(w.partial)
+	w.partial = w.partial[:0]
+}
+
+// flush writes any partial line held back.
+func (w *syncWriter) flush() {
+	w.writeNow("")
+}
+
+// writeNow writes s to w immediately, even if w is line buffered.
+func writeNow(w io.Writer, s string) {
+	if sw, ok := w.(*syncWriter); ok {
+		sw.writeNow(s)
+		return
+	}
+	io.WriteString(w, s)
+}
+
+// unwrapWriter returns the writer wrapped by a syncWriter.
+func unwrapWriter(w io.Writer) io.Writer {
+	if sw, ok := w.(*syncWriter); ok {
+		return sw.w
+	}
+	return w
+}
+
+// flushedWriter returns the writer wrapped by a syncWriter, after writing
+// any partial line held back, for handing to another process.
+func flushedWriter(w io.Writer) io.Writer {
+	if sw, ok := w.(*syncWriter); ok {
+		sw.flush()
+	}
+	return unwrapWriter(w)
+}
+
+// syncWriters makes Stdout and Stderr of ctx safe for concurrent use, and
+// returns a function that flushes them and restores the original writers.
+// A writer used for both is wrapped once, so that writes to either are
+// serialised.
+func (ctx *Context) syncWriters() func() {
+	stdout, stderr := ctx.Stdout, ctx.Stderr
+	var wrapped []*syncWriter
+	wrap := func(w io.Writer) io.Writer {
+		if _, ok := w.(*syncWriter); ok || w == nil {
+			return w
+		}
+		for _, sw := range wrapped {
+			if sw.w == w {
+				return sw
+			}
+		}
+		sw := &syncWriter{w: w}
+		wrapped = append(wrapped, sw)
+		return sw
+	}
+	ctx.Stdout, ctx.Stderr = wrap(stdout), wrap(stderr)
+	return func() {
+		for _, sw := range wrapped {
+			sw.flush()
+		}
+		ctx.Stdout, ctx.Stderr = stdout, stderr
+	}
+}
+
+func (ctx *Context) writeLine(writer io.Writer, format string, params ...interface{}) {
 	output := fmt.Sprintf(format, params...)
 	if !strings.HasSuffix(output, "\n") {
 		output = output + "\n"
 	}
-	fmt.Fprint(ctx.Stderr, output)
+	writeMutex.Lock()
+	defer writeMutex.Unlock()
+	io.WriteString(writer, output)
+}
+
+func (ctx *Context) write(format string, params ...interface{}) {
+	ctx.writeLine(ctx.Stderr, format, params...)
+}
+
+// Linef writes the formatted string to Stdout as a single line, adding a
+// trailing newline if necessary. It is safe to call Linef from multiple
+// goroutines; each line is written in one piece.
+func (ctx *Context) Linef(format string, params ...interface{}) {
+	ctx.writeLine(ctx.Stdout, format, params...)
 }
 
 // Infof will write the formatted string to Stderr if quiet is false, but if
@@ -192,6 +323,27 @@ func (ctx *Context) Verbosef(format string, params ...interface{}) {
 	}
 }
 
+// Verbosity returns the verbosity level requested for the command,
+// e.g. VerbosityDetail when -vv was specified.
+func (ctx *Context) Verbosity() Verbosity {
+	return ctx.verbosity
+}
+
+// VerbosefAt will write the formatted string to Stderr if the verbosity is
+// at least the given level, and to the logger if not. Verbosef is equivalent
+// to VerbosefAt(VerbosityProgress, ...).
+func (ctx *Context) VerbosefAt(level Verbosity, format string, params ...interface{}) {
+	if ctx.verbose && ctx.verbosity >= level {
+		ctx.write(format, params...)
+	} else {
+		// Here we use the Loggo.logger method `Logf` as opposed to
+		// `logger.Debugf` to avoid introducing an additional call stack
+		// level. This is done so that this function can produce more
+		// accurate source location debug information.
+		logger.Logf(loggo.DEBUG, format, params...)
+	}
+}
+
 // Errorf allows for the logging of error messages from a command's
 // context. This should be used for errors which cause a command to fail.
 // Usually these errors are logged by returning them in Run, but that is
@@ -303,6 +455,31 @@ type Info struct {
 	// ShowSuperFlags contains the names of the 'super' command flags
 	// that are desired to be shown in the sub-command help output.
 	ShowSuperFlags []string
+
+	// RequiredFlags contains the names of the flags that must be specified
+	// on the command line. Missing required flags are reported together,
+	// before Init is called, and are marked in the help output.
+	RequiredFlags []string
+
+	// FlagGroups groups related flags together in the help output. The
+	// groups are shown in the order given, after any flags that are not
+	// in a group.
+	FlagGroups []FlagGroup
+
+	// RootPolicy determines whether the command may be run as root. The
+	// default is to permit it. It is applied by Main, and by SuperCommand
+	// for its subcommands, which also add the --allow-root flag.
+	RootPolicy RootPolicy
+
+	// Positional declares the positional arguments of the command. If it
+	// is set, the arguments are checked with CheckArgs before Init is
+	// called, and are described in the usage line unless Args is set.
+	Positional []Arg
+
+	// PosixFlags, if set, causes the command's flags to be parsed with
+	// ParsePosixFlags, so that combined short flags such as "-vq" are
+	// handled as POSIX getopt handles them.
+	PosixFlags bool
 }
 
 // Help renders i's content, along with documentation for any
@@ -316,16 +493,9 @@ func (i *Info) Help(f *gnuflag.FlagSet) []byte {
 // Only super command flags defined in i.ShowSuperFlags are displayed, if found.
 func (i *Info) HelpWithSuperFlags(superF *gnuflag.FlagSet, f *gnuflag.FlagSet) []byte {
 	buf := &bytes.Buffer{}
-	fmt.Fprintf(buf, "Usage: %s", i.Name)
+	buf.WriteString(i.usageLine(f))
 	hasOptions := false
 	f.VisitAll(func(f *gnuflag.Flag) { hasOptions = true })
-	if hasOptions {
-		fmt.Fprintf(buf, " [%vs]", f.FlagKnownAs)
-	}
-	if i.Args != "" {
-		fmt.Fprintf(buf, " %s", i.Args)
-	}
-	fmt.Fprintf(buf, "\n")
 	if i.Purpose != "" {
 		fmt.Fprintf(buf, "\nSummary:\n%s\n", strings.TrimSpace(i.Purpose))
 	}
@@ -341,9 +511,9 @@ func (i *Info) HelpWithSuperFlags(superF *gnuflag.FlagSet, f *gnuflag.FlagSet) [
 			return false
 		}
 		superF.VisitAll(func(flag *gnuflag.Flag) {
-			if contains(flag.Name) {
+			if contains(flag.Name) && !isDeprecatedFlag(flag) {
 				hasSuperFlags = true
-				filteredSuperF.Var(flag.Value, flag.Name, flag.Usage)
+				filteredSuperF.Var(unwrapFlagValue(flag.Value), flag.Name, flag.Usage)
 			}
 		})
 		if hasSuperFlags {
@@ -355,13 +525,11 @@ func (i *Info) HelpWithSuperFlags(superF *gnuflag.FlagSet, f *gnuflag.FlagSet) [
 	}
 
 	if hasOptions {
+		heading := fmt.Sprintf("%vs", strings.Title(f.FlagKnownAs))
 		if hasSuperFlags {
-			fmt.Fprintf(buf, "\nCommand %vs:\n", strings.Title(f.FlagKnownAs))
-		} else {
-			fmt.Fprintf(buf, "\n%vs:\n", strings.Title(f.FlagKnownAs))
+			heading = "Command " + heading
 		}
-		f.SetOutput(buf)
-		f.PrintDefaults()
+		i.printFlags(buf, f, heading)
 	}
 	f.SetOutput(ioutil.Discard)
 	if i.Doc != "" {
@@ -374,6 +542,21 @@ func (i *Info) HelpWithSuperFlags(superF *gnuflag.FlagSet, f *gnuflag.FlagSet) [
 	return buf.Bytes()
 }
 
+// usageLine returns the line of help text showing how to invoke the
+// command with flags f.
+func (i *Info) usageLine(f *gnuflag.FlagSet) string {
+	line := "Usage: " + i.Name
+	hasOptions := false
+	f.VisitAll(func(f *gnuflag.Flag) { hasOptions = true })
+	if hasOptions {
+		line += fmt.Sprintf(" [%vs]", f.FlagKnownAs)
+	}
+	if args := i.argsUsage(); args != "" {
+		line += " " + args
+	}
+	return line + "\n"
+}
+
 // Errors from commands can be ErrSilent (don't print an error message),
 // ErrHelp (show the help) or some other error related to needed flags
 // missing, or needed positional args missing, in which case we should
@@ -388,7 +571,10 @@ func handleCommandError(c Command, ctx *Context, err error, f *gnuflag.FlagSet)
 	case ErrSilent:
 		return 2, true
 	default:
-		WriteError(ctx.Stderr, err)
+		ctx.writeError(err)
+		if flagErr, ok := err.(*flagParseError); ok {
+			fmt.Fprint(ctx.Stderr, flagErr.context)
+		}
 		return 2, true
 	}
 }
@@ -405,10 +591,26 @@ func FlagAlias(c Command, akaDefault string) string {
 // arguments, which should not include the command name. It returns a code
 // suitable for passing to os.Exit.
 func Main(c Command, ctx *Context, args []string) int {
+	defer ctx.syncWriters()()
 	f := gnuflag.NewFlagSetWithFlagKnownAs(c.Info().Name, gnuflag.ContinueOnError, FlagAlias(c, "flag"))
 	f.SetOutput(ioutil.Discard)
 	c.SetFlags(f)
-	if rc, done := handleCommandError(c, ctx, f.Parse(c.AllowInterspersedFlags(), args), f); done {
+	// A SuperCommand applies the root policy of the subcommand it runs.
+	var allowRoot bool
+	if !c.IsSuperCommand() {
+		addAllowRootFlag(f, c.Info(), &allowRoot)
+	}
+	if rc, done := handleCommandError(c, ctx, ApplyFlagEnv(f), f); done {
+		return rc
+	}
+	err := withFlagContext(ParseFlags(f, c.Info().PosixFlags, c.AllowInterspersedFlags(), args), f, c.Info(), c.Info().Name+" --help")
+	if rc, done := handleCommandError(c, ctx, err, f); done {
+		return rc
+	}
+	if rc, done := handleCommandError(c, ctx, CheckRequiredFlags(c.Info(), f), f); done {
+		return rc
+	}
+	if rc, done := handleCommandError(c, ctx, CheckArgs(c.Info(), f.Args()), f); done {
 		return rc
 	}
 	// Since SuperCommands can also return gnuflag.ErrHelp errors, we need to
@@ -416,14 +618,25 @@ func Main(c Command, ctx *Context, args []string) int {
 	if rc, done := handleCommandError(c, ctx, c.Init(f.Args()), f); done {
 		return rc
 	}
-	if err := c.Run(ctx); err != nil {
+	if !c.IsSuperCommand() {
+		warnDeprecatedFlags(ctx, f)
+		if err := checkRoot(ctx, c.Info(), allowRoot); err != nil {
+			ctx.writeError(err)
+			return ExitCode(err)
+		}
+	}
+	stop := ctx.handleInterrupts()
+	err = c.Run(ctx)
+	stop()
+	ctx.ClearStatus()
+	if err != nil {
 		if IsRcPassthroughError(err) {
 			return err.(*RcPassthroughError).Code
 		}
 		if err != ErrSilent {
-			WriteError(ctx.Stderr, err)
+			ctx.writeError(err)
 		}
-		return 1
+		return ExitCode(err)
 	}
 	return 0
 }
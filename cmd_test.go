@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/juju/loggo"
 	jc "github.com/juju/testing/checkers"
@@ -73,6 +74,78 @@ func (s *CmdSuite) TestContextSetenv(c *gc.C) {
 	c.Check(after, gc.Equals, "bar")
 }
 
+func (s *CmdSuite) TestLinef(c *gc.C) {
+	s.ctx.Linef("hello %s", "world")
+	s.ctx.Linef("already terminated\n")
+	c.Check(bufferString(s.ctx.Stdout), gc.Equals, "hello world\nalready terminated\n")
+	c.Check(bufferString(s.ctx.Stderr), gc.Equals, "")
+}
+
+func (s *CmdSuite) TestLinefConcurrent(c *gc.C) {
+	const workers, lines = 10, 100
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := 0; j < lines; j++ {
+				s.ctx.Linef("worker %d line %d", worker, j)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	output := strings.Split(strings.TrimSuffix(bufferString(s.ctx.Stdout), "\n"), "\n")
+	c.Assert(output, gc.HasLen, workers*lines)
+	for _, line := range output {
+		c.Check(line, gc.Matches, `worker \d+ line \d+`)
+	}
+}
+
+func (s *CmdSuite) TestMainWritersConcurrent(c *gc.C) {
+	// Run with -race: the writers are used directly from several
+	// goroutines.
+	const workers, lines = 10, 100
+	command := &TestCommand{Name: "verb", CustomRun: func(ctx *cmd.Context) error {
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(worker int) {
+				defer wg.Done()
+				for j := 0; j < lines; j++ {
+					fmt.Fprintf(ctx.Stdout, "worker %d line %d\n", worker, j)
+					fmt.Fprintf(ctx.Stderr, "worker %d line %d\n", worker, j)
+				}
+			}(i)
+		}
+		wg.Wait()
+		return nil
+	}}
+	code := cmd.Main(command, s.ctx, []string{"--option", "x"})
+	c.Assert(code, gc.Equals, 0)
+	for _, output := range []string{bufferString(s.ctx.Stdout), bufferString(s.ctx.Stderr)} {
+		written := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+		c.Assert(written, gc.HasLen, workers*lines)
+		for _, line := range written {
+			c.Check(line, gc.Matches, `worker \d+ line \d+`)
+		}
+	}
+}
+
+func (s *CmdSuite) TestMainWritersLineBuffered(c *gc.C) {
+	stdout := s.ctx.Stdout
+	command := &TestCommand{Name: "verb", CustomRun: func(ctx *cmd.Context) error {
+		fmt.Fprint(ctx.Stdout, "par")
+		c.Check(bufferString(stdout), gc.Equals, "")
+		fmt.Fprint(ctx.Stdout, "tial\nno newline")
+		c.Check(bufferString(stdout), gc.Equals, "partial\n")
+		return nil
+	}}
+	code := cmd.Main(command, s.ctx, []string{"--option", "x"})
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(bufferString(s.ctx.Stdout), gc.Equals, "partial\nno newline")
+}
+
 func (s *CmdSuite) TestInfo(c *gc.C) {
 	minimal := &TestCommand{Name: "verb", Minimal: true}
 	help := minimal.Info().Help(cmdtesting.NewFlagSet())
@@ -93,18 +166,22 @@ func (s *CmdSuite) TestInfo(c *gc.C) {
 }
 
 var initErrorTests = []struct {
-	c    *TestCommand
-	help string
+	c     *TestCommand
+	help  string
+	usage string
 }{
-	{&TestCommand{Name: "verb"}, fmt.Sprintf(fullHelp, "flag", strings.Title("flag"))},
-	{&TestCommand{Name: "verb", Minimal: true}, minimalHelp},
+	{&TestCommand{Name: "verb"}, fmt.Sprintf(fullHelp, "flag", strings.Title("flag")), "verb [flags] <something>"},
+	{&TestCommand{Name: "verb", Minimal: true}, minimalHelp, "verb"},
 }
 
 func (s *CmdSuite) TestMainInitError(c *gc.C) {
-	expected := "ERROR flag provided but not defined: --unknown\n"
 	for _, t := range initErrorTests {
 		s.SetUpTest(c)
-		s.assertOptionError(c, t.c, expected)
+		s.assertOptionError(c, t.c, fmt.Sprintf(`
+ERROR flag provided but not defined: --unknown
+Usage: %s
+See "verb --help" for details.
+`[1:], t.usage))
 		s.TearDownTest(c)
 	}
 }
@@ -119,7 +196,11 @@ func (s *CmdSuite) assertOptionError(c *gc.C, command *TestCommand, expected str
 func (s *CmdSuite) TestMainFlagsAKA(c *gc.C) {
 	s.assertOptionError(c,
 		&TestCommand{Name: "verb", FlagAKA: "option"},
-		"ERROR option provided but not defined: --unknown\n")
+		`
+ERROR option provided but not defined: --unknown
+Usage: verb [options] <something>
+See "verb --help" for details.
+`[1:])
 }
 
 func (s *CmdSuite) TestMainRunError(c *gc.C) {
@@ -391,3 +472,152 @@ type CmdDocumentationSuite struct {
 
 	targetCmd cmd.Command
 }
+
+func (s *CmdHelpSuite) TestRequiredFlags(c *gc.C) {
+	s.info.RequiredFlags = []string{"one", "three"}
+	s.assertHelp(c, `
+Usage: verb [flags] <something>
+
+Summary:
+command purpose
+
+Flags:
+--five (= "")
+    option-doc
+--one (= "")
+    (required) option-doc
+--three (= "")
+    (required) option-doc
+
+Details:
+command details
+`[1:])
+}
+
+func (s *CmdHelpSuite) TestFlagGroups(c *gc.C) {
+	s.info.RequiredFlags = []string{"five"}
+	s.info.FlagGroups = []cmd.FlagGroup{
+		{Name: "Numbered", Flags: []string{"three", "one"}},
+		{Name: "Empty", Flags: []string{"missing"}},
+	}
+	s.info.ShowSuperFlags = []string{"spiderman"}
+	s.assertHelp(c, `
+Usage: verb [flags] <something>
+
+Summary:
+command purpose
+
+Global Flags:
+--spiderman (= "")
+    option-doc
+
+Command Flags:
+--five (= "")
+    (required) option-doc
+
+Numbered Flags:
+--one (= "")
+    option-doc
+--three (= "")
+    option-doc
+
+Details:
+command details
+`[1:])
+}
+
+type requiredFlagsCommand struct {
+	cmd.CommandBase
+	name, stream string
+	required     []string
+}
+
+func (c *requiredFlagsCommand) Info() *cmd.Info {
+	required := c.required
+	if required == nil {
+		required = []string{"name", "stream"}
+	}
+	return &cmd.Info{
+		Name:          "publish",
+		RequiredFlags: required,
+	}
+}
+
+func (c *requiredFlagsCommand) SetFlags(f *gnuflag.FlagSet) {
+	f.StringVar(&c.name, "n", "", "the name")
+	f.StringVar(&c.name, "name", "", "")
+	f.StringVar(&c.stream, "stream", "", "the stream")
+}
+
+func (c *requiredFlagsCommand) Run(ctx *cmd.Context) error {
+	return nil
+}
+
+func (s *CmdSuite) TestMainRequiredFlags(c *gc.C) {
+	for i, test := range []struct {
+		args   []string
+		code   int
+		stderr string
+	}{{
+		args:   nil,
+		code:   2,
+		stderr: "ERROR missing required flags: --name, --stream\n",
+	}, {
+		args:   []string{"--stream", "released"},
+		code:   2,
+		stderr: "ERROR missing required flag: --name\n",
+	}, {
+		args:   []string{"-n", "foo", "--stream", "released"},
+		code:   0,
+		stderr: "",
+	}, {
+		args:   []string{"--help"},
+		code:   0,
+		stderr: "",
+	}} {
+		c.Logf("test %d: %v", i, test.args)
+		ctx := cmdtesting.Context(c)
+		code := cmd.Main(&requiredFlagsCommand{}, ctx, test.args)
+		c.Check(code, gc.Equals, test.code)
+		c.Check(cmdtesting.Stderr(ctx), gc.Equals, test.stderr)
+	}
+}
+
+func (s *CmdSuite) TestMainRequiredShortFlag(c *gc.C) {
+	ctx := cmdtesting.Context(c)
+	code := cmd.Main(&requiredFlagsCommand{required: []string{"n", "stream"}}, ctx, nil)
+	c.Check(code, gc.Equals, 2)
+	c.Check(cmdtesting.Stderr(ctx), gc.Equals, "ERROR missing required flags: -n, --stream\n")
+}
+
+func (s *CmdSuite) TestRequiredFlagsInSuperCommand(c *gc.C) {
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "jujutest"})
+	sc.Register(&requiredFlagsCommand{})
+	err := cmdtesting.InitCommand(sc, []string{"publish", "--name", "foo"})
+	c.Assert(err, gc.ErrorMatches, "missing required flag: --stream")
+
+	sc = cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "jujutest"})
+	sc.Register(&requiredFlagsCommand{})
+	err = cmdtesting.InitCommand(sc, []string{"publish", "--help"})
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *CmdSuite) TestRequiredGlobalFlagBeforeCommand(c *gc.C) {
+	for _, args := range [][]string{
+		{"--model=cli", "publish"},
+		{"publish", "--model=cli"},
+	} {
+		model := ""
+		sc := cmd.NewSuperCommand(cmd.SuperCommandParams{
+			Name: "jujutest",
+			GlobalFlags: flagAdderFunc(func(f *gnuflag.FlagSet) {
+				f.StringVar(&model, "model", "", "the model")
+			}),
+		})
+		sc.Register(&requiredFlagsCommand{required: []string{"model"}})
+		ctx := cmdtesting.Context(c)
+		code := cmd.Main(sc, ctx, args)
+		c.Check(code, gc.Equals, 0, gc.Commentf("%v: %s", args, cmdtesting.Stderr(ctx)))
+		c.Check(model, gc.Equals, "cli")
+	}
+}
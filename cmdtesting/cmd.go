@@ -28,7 +28,16 @@ func InitCommand(c cmd.Command, args []string) error {
 	f := gnuflag.NewFlagSetWithFlagKnownAs(c.Info().Name, gnuflag.ContinueOnError, cmd.FlagAlias(c, "flag"))
 	f.SetOutput(ioutil.Discard)
 	c.SetFlags(f)
-	if err := f.Parse(c.AllowInterspersedFlags(), args); err != nil {
+	if err := cmd.ApplyFlagEnv(f); err != nil {
+		return err
+	}
+	if err := cmd.ParseFlags(f, c.Info().PosixFlags, c.AllowInterspersedFlags(), args); err != nil {
+		return err
+	}
+	if err := cmd.CheckRequiredFlags(c.Info(), f); err != nil {
+		return err
+	}
+	if err := cmd.CheckArgs(c.Info(), f.Args()); err != nil {
 		return err
 	}
 	return c.Init(f.Args())
@@ -87,6 +96,22 @@ func RunCommandInDir(c *gc.C, com cmd.Command, args []string, dir string) (*cmd.
 	return runCommand(context, com, args)
 }
 
+// RunCommandWithInput works like RunCommand, but the command reads input
+// from its Stdin.
+func RunCommandWithInput(c *gc.C, com cmd.Command, input string, args ...string) (*cmd.Context, error) {
+	var context = Context(c)
+	context.Stdin = bytes.NewBufferString(input)
+	return runCommand(context, com, args)
+}
+
+// RunMain runs a command with the specified args using cmd.Main, as it is
+// run from the command line, and returns the exit code. Errors are written
+// to the Stderr of the returned context rather than returned.
+func RunMain(c *gc.C, com cmd.Command, args ...string) (*cmd.Context, int) {
+	var context = Context(c)
+	return context, cmd.Main(com, context, args)
+}
+
 func runCommand(ctx *cmd.Context, com cmd.Command, args []string) (*cmd.Context, error) {
 	if err := InitCommand(com, args); err != nil {
 		cmd.WriteError(ctx.Stderr, err)
@@ -0,0 +1,68 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmdtesting_test
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/juju/errors"
+	"github.com/juju/gnuflag"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type cmdSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&cmdSuite{})
+
+// echoCommand writes its input, prefixed with --prefix, to Stdout.
+type echoCommand struct {
+	cmd.CommandBase
+	prefix string
+}
+
+func (c *echoCommand) Info() *cmd.Info {
+	return &cmd.Info{Name: "echo"}
+}
+
+func (c *echoCommand) SetFlags(f *gnuflag.FlagSet) {
+	f.StringVar(&c.prefix, "prefix", "", "")
+}
+
+func (c *echoCommand) Run(ctx *cmd.Context) error {
+	input, err := ioutil.ReadAll(ctx.Stdin)
+	if err != nil {
+		return err
+	}
+	if len(input) == 0 {
+		return errors.New("no input")
+	}
+	fmt.Fprintf(ctx.Stdout, "%s%s", c.prefix, input)
+	return nil
+}
+
+func (*cmdSuite) TestRunCommandWithInput(c *gc.C) {
+	ctx, err := cmdtesting.RunCommandWithInput(c, &echoCommand{}, "hello\n", "--prefix", "> ")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(cmdtesting.Stdout(ctx), gc.Equals, "> hello\n")
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "")
+}
+
+func (*cmdSuite) TestRunMain(c *gc.C) {
+	ctx, code := cmdtesting.RunMain(c, &echoCommand{})
+	c.Assert(code, gc.Equals, 1)
+	c.Assert(cmdtesting.Stdout(ctx), gc.Equals, "")
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "ERROR no input\n")
+
+	ctx, code = cmdtesting.RunMain(c, &echoCommand{}, "--verbose")
+	c.Assert(code, gc.Equals, 2)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Matches, "(?s)ERROR flag provided but not defined: --verbose\n.*")
+}
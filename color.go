@@ -0,0 +1,64 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// TextStyle is a style in which text written to a terminal is shown.
+type TextStyle int
+
+const (
+	// StyleError shows text in red, as for error messages.
+	StyleError TextStyle = iota
+
+	// StyleWarning shows text in yellow, as for warnings.
+	StyleWarning
+
+	// StyleHeading shows text in bold, as for the headings of sections of
+	// output.
+	StyleHeading
+)
+
+// sgr holds the ANSI Select Graphic Rendition sequences that start and end
+// each style.
+var sgr = map[TextStyle][2]string{
+	StyleError:   {"\x1b[91m", "\x1b[39m"},
+	StyleWarning: {"\x1b[93m", "\x1b[39m"},
+	StyleHeading: {"\x1b[1m", "\x1b[22m"},
+}
+
+// ColorEnabled reports whether text written to w may be colored. Color is
+// only used when w is a terminal, and is disabled by the --no-color flag
+// (see Log) or by setting the NO_COLOR environment variable to any
+// non-empty value.
+func (ctx *Context) ColorEnabled(w io.Writer) bool {
+	noColor, ok := ctx.Env["NO_COLOR"]
+	if !ok {
+		noColor = os.Getenv("NO_COLOR")
+	}
+	if ctx.noColor || noColor != "" {
+		return false
+	}
+	return isTerminal(w)
+}
+
+// Colorize returns text in the given style for writing to w, or text
+// unchanged if color is not enabled for w; see ColorEnabled.
+func (ctx *Context) Colorize(w io.Writer, style TextStyle, text string) string {
+	codes, ok := sgr[style]
+	if !ok || text == "" || !ctx.ColorEnabled(w) {
+		return text
+	}
+	return codes[0] + text + codes[1]
+}
+
+// writeError writes err to ctx.Stderr as WriteError does, coloring the
+// ERROR prefix only if color is enabled.
+func (ctx *Context) writeError(err error) {
+	fmt.Fprintf(ctx.Stderr, "%s %s\n", ctx.Colorize(ctx.Stderr, StyleError, "ERROR"), err.Error())
+}
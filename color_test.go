@@ -0,0 +1,96 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"errors"
+	"io"
+
+	"github.com/juju/gnuflag"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type ColorSuite struct {
+	testing.LoggingCleanupSuite
+	ctx *cmd.Context
+}
+
+var _ = gc.Suite(&ColorSuite{})
+
+func (s *ColorSuite) SetUpTest(c *gc.C) {
+	s.LoggingCleanupSuite.SetUpTest(c)
+	s.PatchEnvironment("NO_COLOR", "")
+	s.ctx = cmdtesting.Context(c)
+	s.PatchValue(cmd.IsTerminal, func(io.Writer) bool { return true })
+}
+
+func (s *ColorSuite) TestColorize(c *gc.C) {
+	c.Assert(s.ctx.ColorEnabled(s.ctx.Stdout), jc.IsTrue)
+	c.Check(s.ctx.Colorize(s.ctx.Stderr, cmd.StyleError, "failed"), gc.Equals, "\x1b[91mfailed\x1b[39m")
+	c.Check(s.ctx.Colorize(s.ctx.Stderr, cmd.StyleWarning, "stale"), gc.Equals, "\x1b[93mstale\x1b[39m")
+	c.Check(s.ctx.Colorize(s.ctx.Stdout, cmd.StyleHeading, "Tools"), gc.Equals, "\x1b[1mTools\x1b[22m")
+	c.Check(s.ctx.Colorize(s.ctx.Stdout, cmd.StyleHeading, ""), gc.Equals, "")
+}
+
+func (s *ColorSuite) TestNotTerminal(c *gc.C) {
+	s.PatchValue(cmd.IsTerminal, func(io.Writer) bool { return false })
+	c.Assert(s.ctx.ColorEnabled(s.ctx.Stdout), jc.IsFalse)
+	c.Check(s.ctx.Colorize(s.ctx.Stdout, cmd.StyleHeading, "Tools"), gc.Equals, "Tools")
+}
+
+func (s *ColorSuite) TestNoColorEnv(c *gc.C) {
+	s.PatchEnvironment("NO_COLOR", "1")
+	c.Assert(s.ctx.ColorEnabled(s.ctx.Stdout), jc.IsFalse)
+	c.Check(s.ctx.Colorize(s.ctx.Stdout, cmd.StyleHeading, "Tools"), gc.Equals, "Tools")
+}
+
+func (s *ColorSuite) TestNoColorContextEnv(c *gc.C) {
+	err := s.ctx.Setenv("NO_COLOR", "1")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.ctx.ColorEnabled(s.ctx.Stdout), jc.IsFalse)
+}
+
+func (s *ColorSuite) TestNoColorFlag(c *gc.C) {
+	log := &cmd.Log{}
+	f := cmdtesting.NewFlagSet()
+	log.AddFlags(f)
+	err := f.Parse(false, []string{"--no-color"})
+	c.Assert(err, jc.ErrorIsNil)
+	err = log.Start(s.ctx)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.ctx.ColorEnabled(s.ctx.Stdout), jc.IsFalse)
+	c.Check(s.ctx.Colorize(s.ctx.Stdout, cmd.StyleHeading, "Tools"), gc.Equals, "Tools")
+}
+
+type failingCommand struct {
+	cmd.CommandBase
+}
+
+func (c *failingCommand) Info() *cmd.Info {
+	return &cmd.Info{Name: "fail"}
+}
+
+func (c *failingCommand) SetFlags(f *gnuflag.FlagSet) {}
+
+func (c *failingCommand) Run(ctx *cmd.Context) error {
+	return errors.New("it failed")
+}
+
+func (s *ColorSuite) TestErrors(c *gc.C) {
+	code := cmd.Main(&failingCommand{}, s.ctx, nil)
+	c.Assert(code, gc.Equals, 1)
+	c.Assert(cmdtesting.Stderr(s.ctx), gc.Equals, "\x1b[91mERROR\x1b[39m it failed\n")
+}
+
+func (s *ColorSuite) TestErrorsNoColor(c *gc.C) {
+	s.PatchEnvironment("NO_COLOR", "1")
+	code := cmd.Main(&failingCommand{}, s.ctx, nil)
+	c.Assert(code, gc.Equals, 1)
+	c.Assert(cmdtesting.Stderr(s.ctx), gc.Equals, "ERROR it failed\n")
+}
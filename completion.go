@@ -0,0 +1,286 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/gnuflag"
+)
+
+const completionDoc = `
+Generate a script that enables tab completion of this command's subcommands
+and flags in the given shell, which may be bash or zsh.
+
+To enable completion for the current bash session:
+
+    source <(%[1]s completion bash)
+
+To enable it for every zsh session, write the script to a file on your
+$fpath named _%[1]s.
+`
+
+type completionCommand struct {
+	CommandBase
+	super *SuperCommand
+	shell string
+}
+
+func (c *completionCommand) Info() *Info {
+	return &Info{
+		Name:    "completion",
+		Args:    "bash|zsh",
+		Purpose: "Generate a shell completion script",
+		Doc:     fmt.Sprintf(completionDoc, c.super.Name),
+	}
+}
+
+func (c *completionCommand) Init(args []string) error {
+	if len(args) == 0 {
+		return errors.New("no shell specified")
+	}
+	switch args[0] {
+	case "bash", "zsh":
+		c.shell = args[0]
+	default:
+		return errors.Errorf("unsupported shell %q, expected bash or zsh", args[0])
+	}
+	return CheckEmpty(args[1:])
+}
+
+func (c *completionCommand) Run(ctx *Context) error {
+	global := gnuflag.NewFlagSetWithFlagKnownAs(c.super.Name, gnuflag.ContinueOnError, c.super.FlagKnownAs)
+	if c.super.commonflags != nil {
+		c.super.commonflags.VisitAll(func(flag *gnuflag.Flag) {
+			global.Var(flag.Value, flag.Name, flag.Usage)
+		})
+	}
+	entries := completionEntries(c.super, "", flagNames(global))
+	if c.shell == "zsh" {
+		fmt.Fprintf(ctx.Stdout, "#compdef %s\n\nautoload -U +X bashcompinit && bashcompinit\n\n", c.super.Name)
+	}
+	return writeBashCompletion(ctx.Stdout, c.super.Name, entries)
+}
+
+// completionEntry holds the words that can be completed after a command
+// path, such as "" for the top level command, or "model add".
+type completionEntry struct {
+	path     string
+	commands []string
+	flags    completionFlags
+	// args holds the values that can be completed for each of the
+	// command's positional arguments; arguments without values are
+	// completed as file names. If variadic is set, the last argument
+	// may be repeated.
+	args     [][]string
+	variadic bool
+}
+
+// completionFlags holds the sorted names of flags, as they are given on the
+// command line, and the names of those among them that take a value.
+type completionFlags struct {
+	names  []string
+	values []string
+}
+
+// merge returns the flags that are in either f or other.
+func (f completionFlags) merge(other completionFlags) completionFlags {
+	return completionFlags{
+		names:  mergeFlagNames(f.names, other.names),
+		values: mergeFlagNames(f.values, other.values),
+	}
+}
+
+// completionEntries returns the completion entries for super, found at the
+// given path, and for all of its subcommands. The global flags are
+// accepted by every subcommand, along with the common flags of any nested
+// SuperCommand they are run by.
+func completionEntries(super *SuperCommand, path string, global completionFlags) []completionEntry {
+	names := make([]string, 0, len(super.subcmds))
+	for name, ref := range super.subcmds {
+		if ref.hidden {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	entries := []completionEntry{{path: path, commands: names, flags: global}}
+	for _, name := range names {
+		subpath := strings.TrimSpace(path + " " + name)
+		command := super.subcmds[name].command
+		if sub, ok := command.(*SuperCommand); ok {
+			entries = append(entries, completionEntries(sub, subpath, global.merge(commonFlagNames(sub)))...)
+			continue
+		}
+		if _, ok := command.(*helpCommand); ok {
+			entries = append(entries, completionEntry{
+				path:     subpath,
+				commands: names,
+				flags:    global,
+			})
+			continue
+		}
+		if _, ok := command.(*completionCommand); ok {
+			entries = append(entries, completionEntry{
+				path:     subpath,
+				commands: []string{"bash", "zsh"},
+				flags:    global,
+			})
+			continue
+		}
+		entry := completionEntry{
+			path:  subpath,
+			flags: flagNames(commandFlagSet(super, name, command)).merge(global),
+		}
+		positional := command.Info().Positional
+		for _, arg := range positional {
+			entry.args = append(entry.args, arg.Values)
+		}
+		entry.variadic = len(positional) > 0 && positional[len(positional)-1].Variadic
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// flagNames returns the names of the flags in f, as they are given on the
+// command line.
+func flagNames(f *gnuflag.FlagSet) completionFlags {
+	var names, values []string
+	f.VisitAll(func(flag *gnuflag.Flag) {
+		if isDeprecatedFlag(flag) {
+			return
+		}
+		name := flagWithDashes(flag.Name)
+		names = append(names, name)
+		if !isBoolValue(flag.Value) {
+			values = append(values, name)
+		}
+	})
+	sort.Strings(names)
+	sort.Strings(values)
+	return completionFlags{names: names, values: values}
+}
+
+// commonFlagNames returns the names of the common flags of a nested
+// SuperCommand, which are accepted by all of its subcommands.
+func commonFlagNames(super *SuperCommand) completionFlags {
+	// Setting the flags of a SuperCommand replaces its flag sets, which
+	// may be in use.
+	commonflags := super.commonflags
+	defer func() {
+		super.commonflags = commonflags
+	}()
+	f := gnuflag.NewFlagSetWithFlagKnownAs(super.Name, gnuflag.ContinueOnError, super.FlagKnownAs)
+	super.SetCommonFlags(f)
+	return flagNames(f)
+}
+
+// mergeFlagNames returns the sorted names that are in either a or b.
+func mergeFlagNames(a, b []string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, name := range append(append([]string(nil), a...), b...) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// bashQuote single quotes s for bash, so that nothing in it is expanded.
+func bashQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+var notIdentifier = regexp.MustCompile("[^a-zA-Z0-9_]")
+
+// writeBashCompletion writes a bash completion script for the named
+// program. The script finds the command path from the words typed so far,
+// and completes the words of the matching entry: its subcommands, its
+// flags, or the values of its positional arguments. Positional arguments
+// without values, and the values of flags, fall back to the shell's
+// default completion of file names.
+func writeBashCompletion(w io.Writer, name string, entries []completionEntry) error {
+	function := "_" + notIdentifier.ReplaceAllString(name, "_") + "_complete"
+	var paths []string
+	for _, entry := range entries {
+		if entry.path != "" {
+			paths = append(paths, bashQuote(entry.path))
+		}
+	}
+	var cases []string
+	for _, entry := range entries {
+		lines := []string{
+			"commands=" + bashQuote(strings.Join(entry.commands, " ")),
+			"flags=" + bashQuote(strings.Join(entry.flags.names, " ")),
+		}
+		if len(entry.args) > 0 {
+			lines = append(lines, "valueflags="+bashQuote(strings.Join(entry.flags.values, " ")))
+			args := make([]string, len(entry.args))
+			for i, values := range entry.args {
+				args[i] = bashQuote(strings.Join(values, " "))
+			}
+			lines = append(lines, "args=("+strings.Join(args, " ")+")")
+			if entry.variadic {
+				lines = append(lines, "variadic=1")
+			}
+		}
+		cases = append(cases, fmt.Sprintf("        %s)\n            %s\n            ;;",
+			bashQuote(entry.path), strings.Join(lines, "\n            ")))
+	}
+	_, err := fmt.Fprintf(w, `# bash completion for %[1]s
+%[2]s() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local path="" candidate commands flags valueflags variadic word value="" n=0 i
+    local -a words=() args=()
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        candidate="${path:+$path }${COMP_WORDS[i]}"
+        case "$candidate" in
+        %[3]s)
+            path="$candidate"
+            words=()
+            ;;
+        *)
+            words+=("${COMP_WORDS[i]}")
+            ;;
+        esac
+    done
+    case "$path" in
+%[4]s
+    esac
+    # Count the positional arguments given after the command path,
+    # skipping the values of flags.
+    for word in "${words[@]}"; do
+        if [[ -n "$value" ]]; then
+            value=""
+        elif [[ "$word" == -*=* ]]; then
+            :
+        elif [[ "$word" == -* ]]; then
+            [[ " $valueflags " == *" $word "* ]] && value=1
+        else
+            n=$((n + 1))
+        fi
+    done
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+    elif [[ -n "$commands" ]]; then
+        COMPREPLY=($(compgen -W "$commands" -- "$cur"))
+    elif [[ -z "$value" && ${#args[@]} -gt 0 ]]; then
+        if [[ -n "$variadic" && $n -ge ${#args[@]} ]]; then
+            n=$((${#args[@]} - 1))
+        fi
+        COMPREPLY=($(compgen -W "${args[n]}" -- "$cur"))
+    fi
+}
+complete -o default -F %[2]s %[1]s
+`, name, function, strings.Join(paths, "|"), strings.Join(cases, "\n"))
+	return err
+}
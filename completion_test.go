@@ -0,0 +1,196 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/juju/gnuflag"
+	"github.com/juju/loggo"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type CompletionSuite struct {
+	testing.LoggingCleanupSuite
+	ctx *cmd.Context
+}
+
+var _ = gc.Suite(&CompletionSuite{})
+
+func (s *CompletionSuite) SetUpTest(c *gc.C) {
+	s.LoggingCleanupSuite.SetUpTest(c)
+	s.ctx = cmdtesting.Context(c)
+	loggo.ReplaceDefaultWriter(cmd.NewWarningWriter(s.ctx.Stderr))
+}
+
+type completionTestCommand struct {
+	cmd.CommandBase
+	out cmd.Output
+}
+
+func (c *completionTestCommand) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:    "generate",
+		Aliases: []string{"gen"},
+		Positional: []cmd.Arg{
+			{Name: "file"},
+			{Name: "arch", Values: []string{"amd64", "arm64"}, Variadic: true},
+		},
+	}
+}
+
+func (c *completionTestCommand) SetFlags(f *gnuflag.FlagSet) {
+	c.out.AddFlags(f, "smart", cmd.DefaultFormatters.Formatters())
+}
+
+func (c *completionTestCommand) Run(ctx *cmd.Context) error {
+	return nil
+}
+
+func (s *CompletionSuite) run(c *gc.C, args ...string) int {
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "juju-metadata", Log: &cmd.Log{}})
+	sc.Register(&completionTestCommand{})
+	sub := cmd.NewSuperCommand(cmd.SuperCommandParams{
+		Name:        "model",
+		UsagePrefix: "juju-metadata",
+		GlobalFlags: flagAdderFunc(func(f *gnuflag.FlagSet) {
+			var model string
+			f.StringVar(&model, "m", "", "the model")
+			f.StringVar(&model, "model", "", "")
+		}),
+	})
+	sub.Register(&completionTestCommand{})
+	sc.Register(sub)
+	return cmd.Main(sc, s.ctx, args)
+}
+
+func (s *CompletionSuite) TestBash(c *gc.C) {
+	code := s.run(c, "completion", "bash")
+	c.Assert(code, gc.Equals, 0)
+	script := cmdtesting.Stdout(s.ctx)
+	c.Check(script, gc.Matches, `(?s)# bash completion for juju-metadata\n_juju_metadata_complete\(\) {\n.*`)
+	c.Check(script, jc.Contains, `
+        '')
+            commands='completion documentation gen generate help model'
+            flags='--debug --description --file-mode --help --log-file --logging-config --no-color --quiet --show-log --verbose -h -q -v'
+            ;;`)
+	c.Check(script, jc.Contains, `
+        'model')
+            commands='documentation gen generate help'
+            flags='--debug --description --file-mode --help --log-file --logging-config --model --no-color --quiet --show-log --verbose -h -m -q -v'
+            ;;`)
+	c.Check(script, jc.Contains, `
+        'model generate')
+            commands=''
+            flags='--debug --description --file-mode --format --help --log-file --logging-config --model --no-color --output --quiet --show-log --verbose -h -m -o -q -v'
+            valueflags='--file-mode --format --log-file --logging-config --model --output -m -o'
+            args=('' 'amd64 arm64')
+            variadic=1
+            ;;`)
+	c.Check(script, gc.Not(jc.Contains), "'model completion'")
+	c.Check(script, gc.Matches, `(?s).*\ncomplete -o default -F _juju_metadata_complete juju-metadata\n$`)
+}
+
+func (s *CompletionSuite) TestNestedHasNoCompletion(c *gc.C) {
+	code := s.run(c, "model", "completion", "bash")
+	c.Assert(code, gc.Equals, 2)
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Matches, `ERROR unrecognized command: juju-metadata model completion\n`)
+}
+
+func (s *CompletionSuite) TestBashQuoting(c *gc.C) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		c.Skip("bash not available")
+	}
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "juju-metadata"})
+	sc.Register(&quotedCompletionCommand{})
+	code := cmd.Main(sc, s.ctx, []string{"completion", "bash"})
+	c.Assert(code, gc.Equals, 0)
+	script := cmdtesting.Stdout(s.ctx)
+	c.Check(script, jc.Contains, `flags='--description --help --price-$HOME -h'`)
+	out, err := exec.Command(bash, "-n", "-c", script).CombinedOutput()
+	c.Assert(err, jc.ErrorIsNil, gc.Commentf("%s", out))
+}
+
+// quotedCompletionCommand has a flag whose name bash would expand if it
+// were double quoted.
+type quotedCompletionCommand struct {
+	cmd.CommandBase
+	price string
+}
+
+func (c *quotedCompletionCommand) Info() *cmd.Info {
+	return &cmd.Info{Name: "price"}
+}
+
+func (c *quotedCompletionCommand) SetFlags(f *gnuflag.FlagSet) {
+	f.StringVar(&c.price, "price-$HOME", "", "")
+}
+
+func (c *quotedCompletionCommand) Run(ctx *cmd.Context) error {
+	return nil
+}
+
+func (s *CompletionSuite) TestZsh(c *gc.C) {
+	code := s.run(c, "completion", "zsh")
+	c.Assert(code, gc.Equals, 0)
+	c.Check(cmdtesting.Stdout(s.ctx), gc.Matches, `(?s)#compdef juju-metadata\n\nautoload -U \+X bashcompinit && bashcompinit\n\n# bash completion for juju-metadata\n.*`)
+}
+
+func (s *CompletionSuite) TestInitErrors(c *gc.C) {
+	command := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "juju-metadata"})
+	err := cmdtesting.InitCommand(command, []string{"completion"})
+	c.Check(err, gc.ErrorMatches, "no shell specified")
+	command = cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "juju-metadata"})
+	err = cmdtesting.InitCommand(command, []string{"completion", "fish"})
+	c.Check(err, gc.ErrorMatches, `unsupported shell "fish", expected bash or zsh`)
+	command = cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "juju-metadata"})
+	err = cmdtesting.InitCommand(command, []string{"completion", "bash", "zsh"})
+	c.Check(err, gc.ErrorMatches, `unrecognized args: \["zsh"\]`)
+}
+
+func (s *CompletionSuite) TestBashCompletes(c *gc.C) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		c.Skip("bash not available")
+	}
+	code := s.run(c, "completion", "bash")
+	c.Assert(code, gc.Equals, 0)
+	script := filepath.Join(c.MkDir(), "completion.sh")
+	err = ioutil.WriteFile(script, []byte(cmdtesting.Stdout(s.ctx)), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+
+	complete := func(words ...string) string {
+		args := append([]string{"-c", `
+source "$0"
+COMP_WORDS=("$@")
+COMP_CWORD=$((${#COMP_WORDS[@]} - 1))
+_juju_metadata_complete
+echo "${COMPREPLY[*]}"
+`, script}, words...)
+		out, err := exec.Command(bash, args...).CombinedOutput()
+		c.Assert(err, jc.ErrorIsNil, gc.Commentf("%s", out))
+		return strings.TrimSpace(string(out))
+	}
+	c.Check(complete("juju-metadata", "ge"), gc.Equals, "gen generate")
+	c.Check(complete("juju-metadata", "generate", "--fo"), gc.Equals, "--format")
+	c.Check(complete("juju-metadata", "--debug", "model", "g"), gc.Equals, "gen generate")
+	c.Check(complete("juju-metadata", "help", "mo"), gc.Equals, "model")
+	c.Check(complete("juju-metadata", "completion", ""), gc.Equals, "bash zsh")
+	c.Check(complete("juju-metadata", "generate", ""), gc.Equals, "")
+	c.Check(complete("juju-metadata", "generate", "out.json", ""), gc.Equals, "amd64 arm64")
+	c.Check(complete("juju-metadata", "generate", "out.json", "amd64", "a"), gc.Equals, "amd64 arm64")
+	c.Check(complete("juju-metadata", "generate", "-o", "out.json", "x"), gc.Equals, "")
+	c.Check(complete("juju-metadata", "generate", "-o", "out.json", "x", ""), gc.Equals, "amd64 arm64")
+	c.Check(complete("juju-metadata", "generate", "--format=json", "x", ""), gc.Equals, "amd64 arm64")
+	c.Check(complete("juju-metadata", "model", "generate", "-m", "foo", "x", "arm"), gc.Equals, "arm64")
+}
@@ -0,0 +1,182 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/gnuflag"
+)
+
+// Confirm asks the user to confirm an action, by writing prompt to stderr
+// and reading a yes or no answer from stdin, e.g.
+//
+//	Overwrite products.json? (y/N):
+//
+// Only "y" or "yes" confirm the action; any other answer, or stdin being
+// closed, declines it. If the command is interrupted, or ctx is cancelled,
+// an error is returned. Commands that may also be run unattended should
+// use a Confirmation, so that the prompt can be skipped with --yes.
+func (ctx *Context) Confirm(prompt string) (bool, error) {
+	return ctx.confirm(prompt, 0, false)
+}
+
+// ConfirmTimeout asks the user to confirm an action, by writing prompt to
+// stderr and reading a yes or no answer from stdin. If no answer is given
+// within timeout, defaultAnswer is used, so that semi-attended pipelines
+// give a human a window in which to veto the action without blocking
+// forever, e.g.
+//
+//	Publish to released? (Y/n, proceeding in 10s unless interrupted):
+//
+// An empty answer also selects defaultAnswer. If stdin is closed the
+// prompt waits for the timeout, so that the action can still be vetoed by
+// interrupting the command. If the command is interrupted, or ctx is
+// cancelled, an error is returned. A timeout of zero waits for an answer
+// indefinitely.
+func (ctx *Context) ConfirmTimeout(prompt string, timeout time.Duration, defaultAnswer bool) (bool, error) {
+	return ctx.confirm(prompt, timeout, defaultAnswer)
+}
+
+// Confirmation supplies the --yes flag for commands that ask the user to
+// confirm destructive actions, so that they can still be run unattended,
+// for example in CI.
+type Confirmation struct {
+	// AssumeYes is set by the --yes flag, and causes all actions to be
+	// confirmed without prompting.
+	AssumeYes bool
+}
+
+// AddFlags adds the --yes flag, and its -y and --assume-yes aliases, to f.
+func (c *Confirmation) AddFlags(f *gnuflag.FlagSet) {
+	const usage = "Do not ask for confirmation, confirming all actions"
+	f.BoolVar(&c.AssumeYes, "y", false, usage)
+	f.BoolVar(&c.AssumeYes, "yes", false, usage)
+	f.BoolVar(&c.AssumeYes, "assume-yes", false, usage)
+}
+
+// Confirm asks the user to confirm an action, as Context.Confirm does,
+// unless the --yes flag was given, in which case the action is confirmed
+// without prompting.
+func (c *Confirmation) Confirm(ctx *Context, prompt string) (bool, error) {
+	if c.AssumeYes {
+		logger.Debugf("%s: confirmed by --yes", prompt)
+		return true, nil
+	}
+	return ctx.Confirm(prompt)
+}
+
+// confirmPrompt returns the full text of a confirmation prompt.
+func confirmPrompt(prompt string, timeout time.Duration, defaultAnswer bool) string {
+	choices := "y/N"
+	if defaultAnswer {
+		choices = "Y/n"
+	}
+	if timeout <= 0 {
+		return fmt.Sprintf("%s (%s): ", prompt, choices)
+	}
+	if defaultAnswer {
+		return fmt.Sprintf("%s (%s, proceeding in %v unless interrupted): ", prompt, choices, timeout)
+	}
+	return fmt.Sprintf("%s (%s, cancelling in %v): ", prompt, choices, timeout)
+}
+
+type confirmAnswer struct {
+	line string
+	err  error
+}
+
+func (ctx *Context) confirm(prompt string, timeout time.Duration, defaultAnswer bool) (bool, error) {
+	fmt.Fprint(ctx.Stderr, confirmPrompt(prompt, timeout, defaultAnswer))
+
+	// The answer is read in a separate goroutine so that the wait can be
+	// abandoned. If it is, the goroutine remains blocked on stdin until
+	// some input arrives or stdin is closed.
+	answers := make(chan confirmAnswer, 1)
+	go func() {
+		line, err := readLine(ctx.Stdin)
+		answers <- confirmAnswer{line, err}
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	var done <-chan struct{}
+	if ctx.Context != nil {
+		done = ctx.Done()
+	}
+	interrupted := make(chan os.Signal, 1)
+	ctx.InterruptNotify(interrupted)
+	defer ctx.StopInterruptNotify(interrupted)
+
+	for {
+		select {
+		case answer := <-answers:
+			if answer.err == io.EOF && expired != nil {
+				// Keep waiting, so the action can still be vetoed.
+				answers = nil
+				continue
+			}
+			if answer.err != nil && answer.err != io.EOF {
+				return false, errors.Annotate(answer.err, "reading confirmation")
+			}
+			return parseConfirmation(answer.line, defaultAnswer), nil
+		case <-expired:
+			fmt.Fprintln(ctx.Stderr)
+			return defaultAnswer, nil
+		case <-interrupted:
+			fmt.Fprintln(ctx.Stderr)
+			return false, errors.New("confirmation interrupted")
+		case <-done:
+			fmt.Fprintln(ctx.Stderr)
+			return false, errors.Annotate(ctx.Err(), "waiting for confirmation")
+		}
+	}
+}
+
+// parseConfirmation interprets an answer to a confirmation prompt. Only
+// "y" or "yes" are taken as agreement, so that a mistyped answer
+// never confirms an action.
+func parseConfirmation(line string, defaultAnswer bool) bool {
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "":
+		return defaultAnswer
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// readLine reads a single line from r, without reading beyond its end so
+// that later prompts can read the following lines.
+func readLine(r io.Reader) (string, error) {
+	if r == nil {
+		return "", io.EOF
+	}
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				return string(line), nil
+			}
+			line = append(line, buf[0])
+		}
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				return string(line), nil
+			}
+			return string(line), err
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type ConfirmSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&ConfirmSuite{})
+
+func (s *ConfirmSuite) TestAnswers(c *gc.C) {
+	for i, test := range []struct {
+		input         string
+		defaultAnswer bool
+		expect        bool
+	}{
+		{"y\n", false, true},
+		{"Yes\n", false, true},
+		{"n\n", true, false},
+		{"\n", true, true},
+		{"\n", false, false},
+		{"sure\n", true, false},
+		{"y", false, true},
+	} {
+		c.Logf("test %d: %q", i, test.input)
+		ctx := cmdtesting.Context(c)
+		ctx.Stdin = strings.NewReader(test.input)
+		ok, err := ctx.ConfirmTimeout("Publish?", time.Minute, test.defaultAnswer)
+		c.Check(err, jc.ErrorIsNil)
+		c.Check(ok, gc.Equals, test.expect)
+	}
+}
+
+func (s *ConfirmSuite) TestReadsOneLine(c *gc.C) {
+	ctx := cmdtesting.Context(c)
+	ctx.Stdin = strings.NewReader("n\ny\n")
+	ok, err := ctx.ConfirmTimeout("First?", 0, true)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ok, jc.IsFalse)
+	ok, err = ctx.ConfirmTimeout("Second?", 0, false)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "First? (Y/n): Second? (y/N): ")
+}
+
+func (s *ConfirmSuite) TestTimeoutProceeds(c *gc.C) {
+	ctx := cmdtesting.Context(c)
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	ctx.Stdin = reader
+	ok, err := ctx.ConfirmTimeout("Publish?", 10*time.Millisecond, true)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "Publish? (Y/n, proceeding in 10ms unless interrupted): \n")
+}
+
+func (s *ConfirmSuite) TestTimeoutCancels(c *gc.C) {
+	ctx := cmdtesting.Context(c)
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	ctx.Stdin = reader
+	ok, err := ctx.ConfirmTimeout("Prune?", 10*time.Millisecond, false)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ok, jc.IsFalse)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "Prune? (y/N, cancelling in 10ms): \n")
+}
+
+func (s *ConfirmSuite) TestClosedStdinWaitsForTimeout(c *gc.C) {
+	ctx := cmdtesting.Context(c)
+	start := time.Now()
+	ok, err := ctx.ConfirmTimeout("Publish?", 20*time.Millisecond, true)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(time.Since(start) >= 20*time.Millisecond, jc.IsTrue)
+}
+
+func (s *ConfirmSuite) TestClosedStdinWithoutTimeout(c *gc.C) {
+	ctx := cmdtesting.Context(c)
+	ok, err := ctx.ConfirmTimeout("Publish?", 0, true)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ok, jc.IsTrue)
+}
+
+func (s *ConfirmSuite) TestCancelled(c *gc.C) {
+	stdctx, cancel := context.WithCancel(context.Background())
+	ctx := cmdtesting.Context(c).With(stdctx)
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	ctx.Stdin = reader
+	cancel()
+	ok, err := ctx.ConfirmTimeout("Publish?", time.Minute, true)
+	c.Assert(err, gc.ErrorMatches, "waiting for confirmation: context canceled")
+	c.Assert(ok, jc.IsFalse)
+}
+
+func (s *ConfirmSuite) TestConfirm(c *gc.C) {
+	ctx := cmdtesting.Context(c)
+	ctx.Stdin = strings.NewReader("yes\n")
+	ok, err := ctx.Confirm("Overwrite products.json?")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "Overwrite products.json? (y/N): ")
+}
+
+func (s *ConfirmSuite) TestConfirmClosedStdinDeclines(c *gc.C) {
+	ctx := cmdtesting.Context(c)
+	ok, err := ctx.Confirm("Overwrite products.json?")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ok, jc.IsFalse)
+}
+
+func (s *ConfirmSuite) TestConfirmationFlags(c *gc.C) {
+	for _, args := range [][]string{{"-y"}, {"--yes"}, {"--assume-yes"}} {
+		c.Logf("args %v", args)
+		var confirmation cmd.Confirmation
+		f := cmdtesting.NewFlagSet()
+		confirmation.AddFlags(f)
+		err := f.Parse(false, args)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(confirmation.AssumeYes, jc.IsTrue)
+
+		ctx := cmdtesting.Context(c)
+		ok, err := confirmation.Confirm(ctx, "Prune metadata?")
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(ok, jc.IsTrue)
+		c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "")
+	}
+}
+
+func (s *ConfirmSuite) TestConfirmationPrompts(c *gc.C) {
+	var confirmation cmd.Confirmation
+	ctx := cmdtesting.Context(c)
+	ctx.Stdin = strings.NewReader("n\n")
+	ok, err := confirmation.Confirm(ctx, "Prune metadata?")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ok, jc.IsFalse)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "Prune metadata? (y/N): ")
+}
@@ -0,0 +1,57 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+// Package ctxutil provides helpers for honouring the cancellation of a
+// context.Context, such as the one embedded in a cmd.Context, which is
+// cancelled when a command is interrupted.
+package ctxutil
+
+import (
+	"context"
+)
+
+// Checker checks for the cancellation of a context every so many calls,
+// so that tight loops, such as those hashing data or listing thousands of
+// objects, can stop promptly when cancelled without the cost of checking
+// on every iteration, or a select statement in each loop.
+//
+//	check := ctxutil.CheckEvery(ctx, 100)
+//	for _, object := range objects {
+//		if err := check.Check(); err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// A Checker must not be used from multiple goroutines.
+type Checker struct {
+	ctx   context.Context
+	every int
+	calls int
+}
+
+// CheckEvery returns a Checker whose Check method checks ctx every n
+// calls, starting with the first. If n is less than one, every call
+// checks ctx.
+func CheckEvery(ctx context.Context, n int) *Checker {
+	if n < 1 {
+		n = 1
+	}
+	return &Checker{ctx: ctx, every: n}
+}
+
+// Check returns the error of the context if it has been cancelled, or
+// its deadline has passed, when this is one of the calls on which the
+// context is checked. Otherwise it returns nil. Once an error has been
+// returned, every later call returns it.
+func (c *Checker) Check() error {
+	if c.calls%c.every == 0 {
+		if err := c.ctx.Err(); err != nil {
+			// Ensure every later call checks, and so also fails.
+			c.every = 1
+			return err
+		}
+	}
+	c.calls++
+	return nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package ctxutil_test
+
+import (
+	"context"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3/ctxutil"
+)
+
+type checkSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&checkSuite{})
+
+func (*checkSuite) TestNotCancelled(c *gc.C) {
+	check := ctxutil.CheckEvery(context.Background(), 3)
+	for i := 0; i < 10; i++ {
+		c.Assert(check.Check(), jc.ErrorIsNil)
+	}
+}
+
+func (*checkSuite) TestChecksEveryN(c *gc.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	check := ctxutil.CheckEvery(ctx, 3)
+	c.Assert(check.Check(), jc.ErrorIsNil)
+	cancel()
+	// The cancellation is noticed on the fourth call.
+	c.Assert(check.Check(), jc.ErrorIsNil)
+	c.Assert(check.Check(), jc.ErrorIsNil)
+	c.Assert(check.Check(), gc.Equals, context.Canceled)
+	c.Assert(check.Check(), gc.Equals, context.Canceled)
+}
+
+func (*checkSuite) TestChecksFirstCall(c *gc.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	check := ctxutil.CheckEvery(ctx, 1000)
+	c.Assert(check.Check(), gc.Equals, context.Canceled)
+}
+
+func (*checkSuite) TestNonPositive(c *gc.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	check := ctxutil.CheckEvery(ctx, 0)
+	c.Assert(check.Check(), jc.ErrorIsNil)
+	cancel()
+	c.Assert(check.Check(), gc.Equals, context.Canceled)
+}
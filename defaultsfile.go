@@ -0,0 +1,81 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/juju/gnuflag"
+	goyaml "gopkg.in/yaml.v2"
+)
+
+// ParseDefaultsFile will read the specified YAML file and convert the
+// content to a map of command names to the default values for their flags,
+// for example:
+//
+//	generate-agents:
+//	  d: /srv/metadata
+//	  stream: proposed
+//	validate-tools:
+//	  format: json
+//
+// Flags are named without dashes, and may be given by any of their
+// aliases.
+// The function will always return a valid map, even if it is empty.
+func ParseDefaultsFile(defaultsFilename string) map[string]map[string]string {
+	result := map[string]map[string]string{}
+	if defaultsFilename == "" {
+		return result
+	}
+
+	content, err := ioutil.ReadFile(defaultsFilename)
+	if err != nil {
+		logger.Tracef("unable to read defaults file %q: %s", defaultsFilename, err)
+		return result
+	}
+	if err := goyaml.Unmarshal(content, &result); err != nil {
+		logger.Warningf("ignoring bad defaults file %q: %s", defaultsFilename, err)
+		return map[string]map[string]string{}
+	}
+	for name, values := range result {
+		if values == nil {
+			delete(result, name)
+		}
+	}
+	return result
+}
+
+// applyDefaults sets the values of flags in f from the defaults for the
+// named command. Defaults for flags the command does not define are
+// ignored, as they may be meant for another version of the command. It
+// must be called after f is parsed: flags whose unwrapped values are in
+// given, having already been set by the command line or the environment
+// (see ApplyFlagEnv) by any of their names, take precedence and are left
+// alone, so that flags which accumulate values, such as counting flags,
+// are only set once.
+func applyDefaults(f *gnuflag.FlagSet, given map[gnuflag.Value]bool, defaults map[string]map[string]string, name string) error {
+	values := defaults[name]
+	flagNames := make([]string, 0, len(values))
+	for flagName := range values {
+		flagNames = append(flagNames, flagName)
+	}
+	sort.Strings(flagNames)
+	for _, flagName := range flagNames {
+		value := values[flagName]
+		flag := f.Lookup(flagName)
+		if flag == nil {
+			logger.Debugf("ignoring default for unknown %s %q on %q", f.FlagKnownAs, flagName, name)
+			continue
+		}
+		if given[unwrapFlagValue(flag.Value)] {
+			continue
+		}
+		if err := f.Set(flagName, value); err != nil {
+			return fmt.Errorf("invalid default value %q for %s %s on %q: %v", value, f.FlagKnownAs, flagWithDashes(flagName), name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/juju/gnuflag"
+	"github.com/juju/testing"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type ParseDefaultsFileSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&ParseDefaultsFileSuite{})
+
+func writeDefaultsFile(c *gc.C, content string) string {
+	filename := filepath.Join(c.MkDir(), "defaults.yaml")
+	err := ioutil.WriteFile(filename, []byte(content), 0644)
+	c.Assert(err, gc.IsNil)
+	return filename
+}
+
+func (*ParseDefaultsFileSuite) TestMissing(c *gc.C) {
+	filename := filepath.Join(c.MkDir(), "missing")
+	defaults := cmd.ParseDefaultsFile(filename)
+	c.Assert(defaults, gc.NotNil)
+	c.Assert(defaults, gc.HasLen, 0)
+}
+
+func (*ParseDefaultsFileSuite) TestParse(c *gc.C) {
+	filename := writeDefaultsFile(c, `
+# comments are skipped
+list-tools:
+  format: tabular
+validate-tools:
+  format: json
+empty:
+`)
+	defaults := cmd.ParseDefaultsFile(filename)
+	c.Assert(defaults, gc.DeepEquals, map[string]map[string]string{
+		"list-tools":     {"format": "tabular"},
+		"validate-tools": {"format": "json"},
+	})
+}
+
+func (*ParseDefaultsFileSuite) TestParseBadFile(c *gc.C) {
+	filename := writeDefaultsFile(c, "- not\n- a map\n")
+	defaults := cmd.ParseDefaultsFile(filename)
+	c.Assert(defaults, gc.NotNil)
+	c.Assert(defaults, gc.HasLen, 0)
+}
+
+func (*ParseDefaultsFileSuite) runOutput(c *gc.C, filename string, args ...string) (*cmd.Context, int) {
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{
+		Name:                 "jujutest",
+		UserDefaultsFilename: filename,
+	})
+	sc.Register(&OutputCommand{value: []string{"a", "b"}})
+	ctx := cmdtesting.Context(c)
+	code := cmd.Main(sc, ctx, append([]string{"output"}, args...))
+	return ctx, code
+}
+
+func (s *ParseDefaultsFileSuite) TestDefaultFormat(c *gc.C) {
+	filename := writeDefaultsFile(c, "output:\n  format: json\n")
+	ctx, code := s.runOutput(c, filename)
+	c.Assert(code, gc.Equals, 0)
+	c.Check(cmdtesting.Stdout(ctx), gc.Equals, `["a","b"]`+"\n")
+	c.Check(ctx.IsSerial(), gc.Equals, true)
+}
+
+func (s *ParseDefaultsFileSuite) TestDefaultFormatOverridden(c *gc.C) {
+	filename := writeDefaultsFile(c, "output:\n  format: json\n")
+	ctx, code := s.runOutput(c, filename, "--format", "yaml")
+	c.Assert(code, gc.Equals, 0)
+	c.Check(cmdtesting.Stdout(ctx), gc.Equals, "- a\n- b\n")
+}
+
+func (s *ParseDefaultsFileSuite) TestDefaultFormatOtherCommand(c *gc.C) {
+	filename := writeDefaultsFile(c, "other:\n  format: json\n")
+	ctx, code := s.runOutput(c, filename)
+	c.Assert(code, gc.Equals, 0)
+	c.Check(cmdtesting.Stdout(ctx), gc.Equals, "a\nb\n")
+}
+
+func (s *ParseDefaultsFileSuite) TestDefaultFormatInvalid(c *gc.C) {
+	filename := writeDefaultsFile(c, "output:\n  format: cuneiform\n")
+	ctx, code := s.runOutput(c, filename)
+	c.Assert(code, gc.Equals, 2)
+	c.Check(cmdtesting.Stderr(ctx), gc.Equals,
+		`ERROR invalid default value "cuneiform" for flag --format on "output": unknown format "cuneiform"`+"\n")
+}
+
+func (*ParseDefaultsFileSuite) runGenerate(c *gc.C, filename string, args ...string) (*envCommand, *cmd.Context, int) {
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{
+		Name:                 "juju-metadata",
+		UserDefaultsFilename: filename,
+	})
+	command := &envCommand{}
+	sc.Register(command)
+	ctx := cmdtesting.Context(c)
+	code := cmd.Main(sc, ctx, append([]string{"generate"}, args...))
+	return command, ctx, code
+}
+
+func (s *ParseDefaultsFileSuite) TestDefaultAnyFlag(c *gc.C) {
+	filename := writeDefaultsFile(c, "generate:\n  metadata-dir: /srv/metadata\n  agent-version: 2.9.1\n  unknown: value\n")
+	command, _, code := s.runGenerate(c, filename)
+	c.Assert(code, gc.Equals, 0)
+	c.Check(command.dir, gc.Equals, "/srv/metadata")
+	c.Check(command.version, gc.Equals, "2.9.1")
+}
+
+func (s *ParseDefaultsFileSuite) TestDefaultOverridden(c *gc.C) {
+	filename := writeDefaultsFile(c, "generate:\n  d: /srv/metadata\n")
+	command, _, code := s.runGenerate(c, filename, "-d", "here")
+	c.Assert(code, gc.Equals, 0)
+	c.Check(command.dir, gc.Equals, "here")
+}
+
+func (s *ParseDefaultsFileSuite) TestDefaultOverriddenByEnv(c *gc.C) {
+	s.PatchEnvironment("JUJU_METADATA_DIR", "/tmp/metadata")
+	filename := writeDefaultsFile(c, "generate:\n  d: /srv/metadata\n")
+	command, _, code := s.runGenerate(c, filename)
+	c.Assert(code, gc.Equals, 0)
+	c.Check(command.dir, gc.Equals, "/tmp/metadata")
+}
+
+func (s *ParseDefaultsFileSuite) TestDefaultGlobalFlagBeforeCommand(c *gc.C) {
+	filename := writeDefaultsFile(c, "generate:\n  model: from-defaults\n")
+	for _, args := range [][]string{
+		{"--model=cli", "generate"},
+		{"generate", "--model=cli"},
+		{"generate"},
+	} {
+		model := ""
+		sc := cmd.NewSuperCommand(cmd.SuperCommandParams{
+			Name:                 "juju-metadata",
+			UserDefaultsFilename: filename,
+			GlobalFlags: flagAdderFunc(func(f *gnuflag.FlagSet) {
+				f.StringVar(&model, "model", "", "the model")
+			}),
+		})
+		sc.Register(&envCommand{})
+		ctx := cmdtesting.Context(c)
+		code := cmd.Main(sc, ctx, args)
+		c.Check(code, gc.Equals, 0, gc.Commentf("%v", args))
+		if len(args) == 1 {
+			c.Check(model, gc.Equals, "from-defaults")
+		} else {
+			c.Check(model, gc.Equals, "cli", gc.Commentf("%v", args))
+		}
+	}
+}
+
+func (s *ParseDefaultsFileSuite) TestDefaultNested(c *gc.C) {
+	filename := writeDefaultsFile(c, "generate:\n  d: /srv/metadata\n")
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{
+		Name:                 "juju",
+		UserDefaultsFilename: filename,
+	})
+	metadata := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "metadata"})
+	sc.Register(metadata)
+	command := &envCommand{}
+	metadata.Register(command)
+	ctx := cmdtesting.Context(c)
+	code := cmd.Main(sc, ctx, []string{"metadata", "generate"})
+	c.Assert(code, gc.Equals, 0, gc.Commentf("%s", cmdtesting.Stderr(ctx)))
+	c.Check(command.dir, gc.Equals, "/srv/metadata")
+}
+
+func (s *ParseDefaultsFileSuite) TestDefaultInvalid(c *gc.C) {
+	filename := writeDefaultsFile(c, "generate:\n  agent-version: \"2.9\"\n")
+	_, ctx, code := s.runGenerate(c, filename)
+	c.Assert(code, gc.Equals, 2)
+	c.Check(cmdtesting.Stderr(ctx), gc.Equals,
+		`ERROR invalid default value "2.9" for flag --agent-version on "generate": expected major.minor.patch`+"\n")
+}
+
+func (s *ParseDefaultsFileSuite) TestDefaultCountingFlag(c *gc.C) {
+	// The default is not applied as well as the flag given on the
+	// command line, which would count twice.
+	filename := writeDefaultsFile(c, "generate:\n  verbose: \"true\"\n")
+	log := &cmd.Log{}
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{
+		Name:                 "juju-metadata",
+		UserDefaultsFilename: filename,
+		Log:                  log,
+	})
+	sc.Register(&envCommand{})
+	ctx := cmdtesting.Context(c)
+	code := cmd.Main(sc, ctx, []string{"generate", "-v"})
+	c.Assert(code, gc.Equals, 0, gc.Commentf("%s", cmdtesting.Stderr(ctx)))
+	c.Check(log.Verbosity, gc.Equals, cmd.VerbosityProgress)
+}
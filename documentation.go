@@ -4,10 +4,11 @@
 package cmd
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 
@@ -20,7 +21,7 @@ const (
 )
 
 var doc string = `
-This command generates a markdown formatted document with all the commands, their descriptions, arguments, and examples.
+This command generates a markdown formatted document with all the commands, including those of nested commands, their descriptions, arguments, and examples.
 `
 
 type documentationCommand struct {
@@ -30,6 +31,7 @@ type documentationCommand struct {
 	noIndex bool
 	split   bool
 	url     string
+	man     bool
 }
 
 func newDocumentationCommand(s *SuperCommand) *documentationCommand {
@@ -51,61 +53,78 @@ func (c *documentationCommand) SetFlags(f *gnuflag.FlagSet) {
 	f.BoolVar(&c.noIndex, "no-index", false, "Do not generate the commands index")
 	f.BoolVar(&c.split, "split", false, "Generate one file per command")
 	f.StringVar(&c.url, "url", "", "Documentation host URL")
+	f.BoolVar(&c.man, "man", false, "Generate man pages into the output folder instead")
 }
 
 func (c *documentationCommand) Run(ctx *Context) error {
+	if c.man {
+		if c.out == "" {
+			return errors.New("set the output folder when using the man option")
+		}
+		return GenerateManPages(ctx, c.super, c.out)
+	}
 	if c.split {
 		if c.out == "" {
 			return errors.New("set the output folder when using the split option")
 		}
-		return c.dumpSeveralFiles()
+		return c.dumpSeveralFiles(ctx)
 	}
 	return c.dumpOneFile(ctx)
 }
 
 // dumpeOneFile is invoked when the output is contained in a single output
 func (c *documentationCommand) dumpOneFile(ctx *Context) error {
-	var writer *bufio.Writer
-	if c.out != "" {
-		_, err := os.Stat(c.out)
-		if err != nil {
-			return err
-		}
-
-		target := fmt.Sprintf("%s/%s", c.out, DocumentationFileName)
-
-		f, err := os.Create(target)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-		writer = bufio.NewWriter(f)
-	} else {
-		writer = bufio.NewWriter(ctx.Stdout)
+	if c.out == "" {
+		return c.dumpEntries(ctx.Stdout)
 	}
-
-	return c.dumpEntries(writer)
+	if _, err := os.Stat(ctx.AbsPath(c.out)); err != nil {
+		return err
+	}
+	target := filepath.Join(c.out, DocumentationFileName)
+	return ctx.WriteFileFunc(target, DefaultFileMode, c.dumpEntries)
 }
 
 // getSortedListCommands returns an array with the sorted list of
-// command names
-func (c *documentationCommand) getSortedListCommands() []string {
+// command names, excluding hidden commands, and the commands they name.
+// The commands of nested SuperCommands are included, named by their path
+// below the documented SuperCommand, e.g. "model generate".
+func (c *documentationCommand) getSortedListCommands() ([]string, map[string]commandReference) {
+	commands := make(map[string]commandReference)
+	var add func(super *SuperCommand, prefix string)
+	add = func(super *SuperCommand, prefix string) {
+		for name, ref := range super.subcmds {
+			if ref.hidden {
+				continue
+			}
+			ref.name = prefix + name
+			commands[ref.name] = ref
+			if sub, ok := ref.command.(*SuperCommand); ok && ref.alias == "" {
+				add(sub, ref.name+" ")
+			}
+		}
+	}
+	add(c.super, "")
+
 	// sort the commands
-	sorted := make([]string, len(c.super.subcmds))
-	i := 0
-	for k := range c.super.subcmds {
-		sorted[i] = k
-		i++
+	sorted := make([]string, 0, len(commands))
+	for name := range commands {
+		sorted = append(sorted, name)
 	}
 	sort.Strings(sorted)
-	return sorted
+	return sorted, commands
+}
+
+// docFileName returns the name, without an extension, of the document for
+// the named command when the commands are split into several files. It is
+// also the anchor of the command's section when they are not.
+func docFileName(name string) string {
+	return strings.Replace(name, " ", "-", -1)
 }
 
 // dumpSeveralFiles is invoked when every command is dumped into
 // a separated entity
-func (c *documentationCommand) dumpSeveralFiles() error {
-	_, err := os.Stat(c.out)
-	if err != nil {
+func (c *documentationCommand) dumpSeveralFiles(ctx *Context) error {
+	if _, err := os.Stat(ctx.AbsPath(c.out)); err != nil {
 		return err
 	}
 
@@ -114,54 +133,36 @@ func (c *documentationCommand) dumpSeveralFiles() error {
 		return nil
 	}
 
-	sorted := c.getSortedListCommands()
+	sorted, commands := c.getSortedListCommands()
 
 	// create index if indicated
 	if !c.noIndex {
-		target := fmt.Sprintf("%s/%s", c.out, DocumentationIndexFileName)
-		f, err := os.Create(target)
-		if err != nil {
-			return err
-		}
-
-		writer := bufio.NewWriter(f)
-		_, err = writer.WriteString(c.commandsIndex(sorted))
-		if err != nil {
+		target := filepath.Join(c.out, DocumentationIndexFileName)
+		if err := ctx.WriteFile(target, []byte(c.commandsIndex(sorted)), DefaultFileMode); err != nil {
 			return err
 		}
-		f.Close()
 	}
 
-	folder := c.out + "/%s.md"
 	for _, command := range sorted {
-		target := fmt.Sprintf(folder, command)
-		f, err := os.Create(target)
-		if err != nil {
-			return err
-		}
-		writer := bufio.NewWriter(f)
-		formatted := c.formatCommand(c.super.subcmds[command], false)
-		_, err = writer.WriteString(formatted)
-		if err != nil {
+		target := filepath.Join(c.out, docFileName(command)+".md")
+		formatted := c.formatCommand(commands[command], false)
+		if err := ctx.WriteFile(target, []byte(formatted), DefaultFileMode); err != nil {
 			return err
 		}
-		writer.Flush()
-		f.Close()
 	}
-
-	return err
+	return nil
 }
 
-func (c *documentationCommand) dumpEntries(writer *bufio.Writer) error {
+func (c *documentationCommand) dumpEntries(writer io.Writer) error {
 	if len(c.super.subcmds) == 0 {
 		fmt.Printf("No commands found for %s", c.super.Name)
 		return nil
 	}
 
-	sorted := c.getSortedListCommands()
+	sorted, commands := c.getSortedListCommands()
 
 	if !c.noIndex {
-		_, err := writer.WriteString(c.commandsIndex(sorted))
+		_, err := io.WriteString(writer, c.commandsIndex(sorted))
 		if err != nil {
 			return err
 		}
@@ -169,7 +170,7 @@ func (c *documentationCommand) dumpEntries(writer *bufio.Writer) error {
 
 	var err error
 	for _, nameCmd := range sorted {
-		_, err = writer.WriteString(c.formatCommand(c.super.subcmds[nameCmd], true))
+		_, err = io.WriteString(writer, c.formatCommand(commands[nameCmd], true))
 		if err != nil {
 			return err
 		}
@@ -184,7 +185,7 @@ func (c *documentationCommand) commandsIndex(listCommands []string) string {
 		prefix = c.url + "/"
 	}
 	for id, name := range listCommands {
-		index += fmt.Sprintf("%d. [%s](%s%s)\n", id, name, prefix, name)
+		index += fmt.Sprintf("%d. [%s](%s%s)\n", id, name, prefix, docFileName(name))
 	}
 	index += "---\n\n"
 	return index
@@ -214,8 +215,10 @@ func (c *documentationCommand) formatCommand(ref commandReference, title bool) s
 		if c.url != "" {
 			prefix = c.url + "/"
 		}
+		// The commands to see are registered alongside this one.
+		parent := ref.name[:strings.LastIndex(ref.name, " ")+1]
 		for _, s := range info.SeeAlso {
-			formatted += fmt.Sprintf("[%s](%s%s)\n", s, prefix, s)
+			formatted += fmt.Sprintf("[%s](%s%s)\n", s, prefix, docFileName(parent+s))
 		}
 		formatted += "\n"
 	}
@@ -232,8 +235,8 @@ func (c *documentationCommand) formatCommand(ref commandReference, title bool) s
 	formatted += "## Summary\n" + info.Purpose + "\n\n"
 
 	// Usage
-	if strings.TrimSpace(info.Args) != "" {
-		formatted += "## Usage\n```" + info.Args + "```\n\n"
+	if args := info.argsUsage(); strings.TrimSpace(args) != "" {
+		formatted += "## Usage\n```" + args + "```\n\n"
 	}
 
 	// Description
@@ -265,50 +268,132 @@ func (c *documentationCommand) formatCommand(ref commandReference, title bool) s
 // to permit additional formatting without modifying the
 // gnuflag package.
 func (d *documentationCommand) formatFlags(c Command, info *Info) string {
-	flagsAlias := FlagAlias(c, "")
+	byName := groupFlags(commandFlagSet(d.super, info.Name, c))
+
+	formatted := "| Flag | Default | Usage |\n"
+	formatted += "| --- | --- | --- |\n"
+	for _, fs := range byName {
+		theFlags := ""
+		for i, f := range fs {
+			if i > 0 {
+				theFlags += ", "
+			}
+			theFlags += fmt.Sprintf("`--%s`", f.Name)
+		}
+		formatted += fmt.Sprintf("| %s | %s | %s |\n", theFlags, fs[0].DefValue, fs[0].Usage)
+	}
+	return formatted
+}
+
+// commandFlagSet returns a new flag set holding the flags of command. The
+// documentation command is given a new instance, so that the flags of the
+// running documentation command are not overwritten.
+func commandFlagSet(super *SuperCommand, name string, command Command) *gnuflag.FlagSet {
+	flagsAlias := FlagAlias(command, "")
 	if flagsAlias == "" {
 		// For backward compatibility, the default is 'flag'.
 		flagsAlias = "flag"
 	}
-	f := gnuflag.NewFlagSetWithFlagKnownAs(info.Name, gnuflag.ContinueOnError, flagsAlias)
+	f := gnuflag.NewFlagSetWithFlagKnownAs(name, gnuflag.ContinueOnError, flagsAlias)
+	switch c := command.(type) {
+	case *documentationCommand:
+		command = newDocumentationCommand(super)
+	case *SuperCommand:
+		// Setting the flags of a SuperCommand replaces its flag sets,
+		// which may be in use.
+		commonflags, flags := c.commonflags, c.flags
+		defer func() {
+			c.commonflags, c.flags = commonflags, flags
+		}()
+	}
+	command.SetFlags(f)
+	return f
+}
+
+// commandPage describes one command of a SuperCommand tree, for writing
+// per-command documentation such as man pages.
+type commandPage struct {
+	// super is the SuperCommand that the command is registered with, or
+	// the command itself for the root of the tree.
+	super *SuperCommand
+	// path holds the names leading to the command, starting with the name
+	// of the root SuperCommand.
+	path []string
+	// info describes the command, with its Name set to the full command
+	// path joined with spaces.
+	info    *Info
+	command Command
+	// aliases holds the names registered as aliases for the command.
+	aliases []string
+	// subcommands holds the names of the commands registered with the
+	// command, if it is a SuperCommand, excluding aliases.
+	subcommands []string
+}
+
+// walkCommands calls visit for super and for each command registered with
+// it, recursing into nested SuperCommands. Commands are visited in name
+// order; hidden commands are not visited, and nor are aliases, which are
+// listed in the page of the command they refer to.
+func walkCommands(super *SuperCommand, path []string, visit func(page *commandPage) error) error {
+	aliases := make(map[string][]string)
+	var names []string
+	for name, ref := range super.subcmds {
+		if ref.hidden {
+			continue
+		}
+		if ref.alias != "" {
+			aliases[ref.alias] = append(aliases[ref.alias], name)
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-	// if we are working with the documentation command,
-	// we have to set flags on a new instance, otherwise
-	// we will overwrite the current flag values
-	if info.Name != "documentation" {
-		c.SetFlags(f)
-	} else {
-		c = newDocumentationCommand(d.super)
-		c.SetFlags(f)
+	info := super.superInfo()
+	info.Name = strings.Join(path, " ")
+	page := &commandPage{super: super, path: path, info: info, command: super, subcommands: names}
+	if err := visit(page); err != nil {
+		return err
+	}
+	for _, name := range names {
+		command := super.subcmds[name].command
+		subpath := append(append([]string(nil), path...), name)
+		if sub, ok := command.(*SuperCommand); ok {
+			if err := walkCommands(sub, subpath, visit); err != nil {
+				return err
+			}
+			continue
+		}
+		info := *command.Info()
+		info.Name = strings.Join(subpath, " ")
+		sort.Strings(aliases[name])
+		page := &commandPage{super: super, path: subpath, info: &info, command: command, aliases: aliases[name]}
+		if err := visit(page); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	// group together all flags for a given value
+// groupFlags groups together the flags of f that share a value, as
+// gnuflag.PrintDefaults does. Each group is sorted by the length of the
+// flag names, and the groups are sorted by the name of their first flag.
+func groupFlags(f *gnuflag.FlagSet) flagsByName {
 	flags := make(map[interface{}]flagsByLength)
 	f.VisitAll(func(f *gnuflag.Flag) {
+		if isDeprecatedFlag(f) {
+			return
+		}
 		flags[f.Value] = append(flags[f.Value], f)
 	})
 
-	// sort the output flags by shortest name for each group.
 	var byName flagsByName
 	for _, fl := range flags {
 		sort.Sort(fl)
 		byName = append(byName, fl)
 	}
 	sort.Sort(byName)
-
-	formatted := "| Flag | Default | Usage |\n"
-	formatted += "| --- | --- | --- |\n"
-	for _, fs := range byName {
-		theFlags := ""
-		for i, f := range fs {
-			if i > 0 {
-				theFlags += ", "
-			}
-			theFlags += fmt.Sprintf("`--%s`", f.Name)
-		}
-		formatted += fmt.Sprintf("| %s | %s | %s |\n", theFlags, fs[0].DefValue, fs[0].Usage)
-	}
-	return formatted
+	return byName
 }
 
 // flagsByLength is a slice of flags implementing sort.Interface,
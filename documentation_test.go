@@ -0,0 +1,109 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type DocumentationSuite struct {
+	testing.LoggingCleanupSuite
+
+	ctx *cmd.Context
+}
+
+var _ = gc.Suite(&DocumentationSuite{})
+
+func (s *DocumentationSuite) SetUpTest(c *gc.C) {
+	s.LoggingCleanupSuite.SetUpTest(c)
+	s.ctx = cmdtesting.Context(c)
+	err := os.Mkdir(filepath.Join(s.ctx.Dir, "docs"), 0755)
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *DocumentationSuite) newSuperCommand() *cmd.SuperCommand {
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "juju-metadata", Log: &cmd.Log{}})
+	sc.Register(&manTestCommand{})
+	return sc
+}
+
+func (s *DocumentationSuite) TestOneFile(c *gc.C) {
+	code := cmd.Main(s.newSuperCommand(), s.ctx, []string{"documentation", "--out", "docs"})
+	c.Assert(code, gc.Equals, 0, gc.Commentf("%s", cmdtesting.Stderr(s.ctx)))
+	dir := filepath.Join(s.ctx.Dir, "docs")
+	c.Assert(listDir(c, dir), jc.DeepEquals, []string{cmd.DocumentationFileName})
+	data, err := ioutil.ReadFile(filepath.Join(dir, cmd.DocumentationFileName))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(data), gc.Matches, "# Index\n(.|\n)*# GENERATE\n(.|\n)*")
+}
+
+func (s *DocumentationSuite) TestSplitFileMode(c *gc.C) {
+	code := cmd.Main(s.newSuperCommand(), s.ctx, []string{"--file-mode", "0600", "documentation", "--split", "--out", "docs"})
+	c.Assert(code, gc.Equals, 0, gc.Commentf("%s", cmdtesting.Stderr(s.ctx)))
+	dir := filepath.Join(s.ctx.Dir, "docs")
+	c.Assert(listDir(c, dir), jc.DeepEquals, []string{
+		"completion.md",
+		"documentation.md",
+		"gen.md",
+		"generate.md",
+		"help.md",
+		cmd.DocumentationIndexFileName,
+	})
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(filepath.Join(dir, "generate.md"))
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(info.Mode().Perm(), gc.Equals, os.FileMode(0600))
+	}
+}
+
+func (s *DocumentationSuite) TestMissingOut(c *gc.C) {
+	code := cmd.Main(s.newSuperCommand(), s.ctx, []string{"documentation", "--out", "missing"})
+	c.Assert(code, gc.Equals, 1)
+	c.Assert(cmdtesting.Stderr(s.ctx), gc.Matches, "ERROR stat .*missing: no such file or directory\n")
+}
+
+func (s *DocumentationSuite) TestNested(c *gc.C) {
+	code := cmd.Main(newDocTreeCommand(), s.ctx, []string{"documentation", "--split", "--out", "docs"})
+	c.Assert(code, gc.Equals, 0, gc.Commentf("%s", cmdtesting.Stderr(s.ctx)))
+	dir := filepath.Join(s.ctx.Dir, "docs")
+	c.Assert(listDir(c, dir), jc.DeepEquals, []string{
+		"completion.md",
+		"documentation.md",
+		"gen.md",
+		"generate.md",
+		"help.md",
+		cmd.DocumentationIndexFileName,
+		"model-documentation.md",
+		"model-gen.md",
+		"model-generate.md",
+		"model-help.md",
+		"model.md",
+	})
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "model-generate.md"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(data), gc.Matches, "## See Also\n\\[validate\\]\\(#model-validate\\)\n"+
+		"(?s).*## Summary\nGenerate tools metadata.\n.*"+
+		"\\| `--s`, `--stream` \\| released \\| The stream to generate \\|\n.*")
+
+	data, err = ioutil.ReadFile(filepath.Join(dir, cmd.DocumentationIndexFileName))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(data), gc.Matches, "(?s)# Index\n.*\\. \\[model generate\\]\\(#model-generate\\)\n.*")
+}
+
+func (s *DocumentationSuite) TestNestedOneFile(c *gc.C) {
+	code := cmd.Main(newDocTreeCommand(), s.ctx, []string{"documentation", "--no-index"})
+	c.Assert(code, gc.Equals, 0, gc.Commentf("%s", cmdtesting.Stderr(s.ctx)))
+	c.Assert(cmdtesting.Stdout(s.ctx), gc.Matches, "(?s)# COMPLETION\n.*# MODEL\n.*# MODEL GENERATE\n.*")
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// ErrorGroup collects the errors encountered while processing the items of
+// a batch command, each labelled with the item it relates to, so that all
+// of them can be reported rather than only the first. It is safe for
+// concurrent use.
+//
+// A non-empty ErrorGroup is returned from Run with Err. When written
+// to stderr it lists each error on its own line; when a serialisable
+// format such as json was selected, and no other output was written, the
+// errors are also written to stdout as an array, e.g.
+//
+//	{"errors":[{"label":"juju-2.9.0-amd64.tgz","error":"checksum mismatch"}]}
+type ErrorGroup struct {
+	mu     sync.Mutex
+	errors []LabelledError
+}
+
+// LabelledError is an error recorded in an ErrorGroup.
+type LabelledError struct {
+	Label string
+	Err   error
+}
+
+// Add records err against label. A nil err is ignored, so that the result
+// of processing an item can be added directly.
+func (g *ErrorGroup) Add(label string, err error) {
+	if err == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.errors = append(g.errors, LabelledError{Label: label, Err: err})
+}
+
+// Len returns the number of errors recorded.
+func (g *ErrorGroup) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.errors)
+}
+
+// Errors returns the errors recorded, in the order in which they were
+// added.
+func (g *ErrorGroup) Errors() []LabelledError {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	result := make([]LabelledError, len(g.errors))
+	copy(result, g.errors)
+	return result
+}
+
+// Err returns g if any errors have been recorded, and nil otherwise.
+func (g *ErrorGroup) Err() error {
+	if g.Len() == 0 {
+		return nil
+	}
+	return g
+}
+
+// Error implements error.
+func (g *ErrorGroup) Error() string {
+	errs := g.Errors()
+	if len(errs) == 1 {
+		return errs[0].String()
+	}
+	lines := make([]string, len(errs)+1)
+	lines[0] = fmt.Sprintf("%d errors occurred:", len(errs))
+	for i, err := range errs {
+		lines[i+1] = "- " + err.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// String returns the error message prefixed with its label.
+func (e LabelledError) String() string {
+	if e.Label == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", e.Label, e.Err)
+}
+
+type labelledErrorDoc struct {
+	Label string `yaml:"label,omitempty" json:"label,omitempty"`
+	Error string `yaml:"error" json:"error"`
+}
+
+func (g *ErrorGroup) docs() []labelledErrorDoc {
+	errs := g.Errors()
+	docs := make([]labelledErrorDoc, len(errs))
+	for i, err := range errs {
+		docs[i] = labelledErrorDoc{Label: err.Label, Error: err.Err.Error()}
+	}
+	return docs
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (g *ErrorGroup) MarshalYAML() (interface{}, error) {
+	return g.docs(), nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (g *ErrorGroup) MarshalJSON() ([]byte, error) {
+	return json.Marshal(g.docs())
+}
+
+// errorGroupResult is written in serialisable formats when a command
+// fails with an ErrorGroup.
+type errorGroupResult struct {
+	Errors *ErrorGroup `yaml:"errors" json:"errors"`
+}
+
+// machineErrorValue returns the value to be written to stdout, in a
+// serialisable format, when a command fails with err.
+func machineErrorValue(err error) interface{} {
+	if group, ok := errors.Cause(err).(*ErrorGroup); ok {
+		return errorGroupResult{Errors: group}
+	}
+	return struct{}{}
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"bytes"
+	"errors"
+
+	jujuerrors "github.com/juju/errors"
+	"github.com/juju/gnuflag"
+	"github.com/juju/loggo"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type ErrorGroupSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&ErrorGroupSuite{})
+
+func (s *ErrorGroupSuite) newGroup() *cmd.ErrorGroup {
+	group := &cmd.ErrorGroup{}
+	group.Add("juju-2.9.0-amd64.tgz", errors.New("checksum mismatch"))
+	group.Add("juju-2.9.0-arm64.tgz", nil)
+	group.Add("juju-2.9.1-amd64.tgz", errors.New("not found"))
+	return group
+}
+
+func (s *ErrorGroupSuite) TestEmpty(c *gc.C) {
+	group := &cmd.ErrorGroup{}
+	group.Add("juju-2.9.0-amd64.tgz", nil)
+	c.Assert(group.Len(), gc.Equals, 0)
+	c.Assert(group.Err(), jc.ErrorIsNil)
+}
+
+func (s *ErrorGroupSuite) TestSingle(c *gc.C) {
+	group := &cmd.ErrorGroup{}
+	group.Add("juju-2.9.0-amd64.tgz", errors.New("checksum mismatch"))
+	c.Assert(group.Err(), gc.ErrorMatches, "juju-2.9.0-amd64.tgz: checksum mismatch")
+}
+
+func (s *ErrorGroupSuite) TestMultiple(c *gc.C) {
+	group := s.newGroup()
+	c.Assert(group.Len(), gc.Equals, 2)
+	c.Assert(group.Errors()[1].Label, gc.Equals, "juju-2.9.1-amd64.tgz")
+	c.Assert(group.Err().Error(), gc.Equals, `
+2 errors occurred:
+- juju-2.9.0-amd64.tgz: checksum mismatch
+- juju-2.9.1-amd64.tgz: not found`[1:])
+}
+
+func (s *ErrorGroupSuite) TestFormatted(c *gc.C) {
+	var buf bytes.Buffer
+	err := cmd.FormatJson(&buf, s.newGroup())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(buf.String(), gc.Equals, `[{"label":"juju-2.9.0-amd64.tgz","error":"checksum mismatch"},`+
+		`{"label":"juju-2.9.1-amd64.tgz","error":"not found"}]`+"\n")
+
+	buf.Reset()
+	err = cmd.FormatYaml(&buf, s.newGroup())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(buf.String(), gc.Equals, `
+- label: juju-2.9.0-amd64.tgz
+  error: checksum mismatch
+- label: juju-2.9.1-amd64.tgz
+  error: not found
+`[1:])
+}
+
+func (s *ErrorGroupSuite) run(c *gc.C, format string) *cmd.Context {
+	ctx := cmdtesting.Context(c)
+	loggo.ReplaceDefaultWriter(cmd.NewWarningWriter(ctx.Stderr))
+	var output cmd.Output
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{
+		Name: "jujutest",
+		Log:  &cmd.Log{},
+		GlobalFlags: flagAdderFunc(func(fset *gnuflag.FlagSet) {
+			output.AddFlags(fset, "smart", cmd.DefaultFormatters.Formatters())
+		}),
+	})
+	sc.Register(&TestCommand{
+		Name: "verify",
+		CustomRun: func(ctx *cmd.Context) error {
+			return jujuerrors.Trace(s.newGroup().Err())
+		},
+	})
+	code := cmd.Main(sc, ctx, []string{"verify", "--format", format})
+	c.Assert(code, gc.Equals, 1)
+	return ctx
+}
+
+func (s *ErrorGroupSuite) TestRunReportsAllErrors(c *gc.C) {
+	ctx := s.run(c, "smart")
+	c.Check(cmdtesting.Stderr(ctx), gc.Equals, `
+ERROR 2 errors occurred:
+- juju-2.9.0-amd64.tgz: checksum mismatch
+- juju-2.9.1-amd64.tgz: not found
+`[1:])
+	c.Check(cmdtesting.Stdout(ctx), gc.Equals, "")
+}
+
+func (s *ErrorGroupSuite) TestRunMachineFormat(c *gc.C) {
+	ctx := s.run(c, "json")
+	c.Check(cmdtesting.Stdout(ctx), gc.Equals, `{"errors":[{"label":"juju-2.9.0-amd64.tgz","error":"checksum mismatch"},`+
+		`{"label":"juju-2.9.1-amd64.tgz","error":"not found"}]}`+"\n")
+}
@@ -0,0 +1,155 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/loggo"
+)
+
+const (
+	// defaultEventLogMaxRuns is the number of runs for which event logs
+	// are kept if Log.EventLogMaxRuns is not set.
+	defaultEventLogMaxRuns = 10
+
+	eventLogTimeLayout = "20060102-150405.000000"
+	eventLogPlainExt   = ".log"
+	eventLogJSONExt    = ".json"
+)
+
+// eventLog writes the per-run plain text and JSON logs.
+type eventLog struct {
+	mu    sync.Mutex
+	plain io.Writer
+	json  *json.Encoder
+}
+
+// eventLogEntry is the JSON representation of both log messages and
+// events.
+type eventLogEntry struct {
+	Time     time.Time              `json:"time"`
+	Level    string                 `json:"level,omitempty"`
+	Module   string                 `json:"module,omitempty"`
+	Location string                 `json:"location,omitempty"`
+	Message  string                 `json:"message,omitempty"`
+	Event    string                 `json:"event,omitempty"`
+	Fields   map[string]interface{} `json:"fields,omitempty"`
+}
+
+// openEventLog creates the log files for a new run in dir, with
+// permissions perm, first removing the logs of older runs so that no more
+// than maxRuns are kept.
+func openEventLog(dir string, maxRuns int, now time.Time, perm os.FileMode) (*eventLog, error) {
+	if maxRuns <= 0 {
+		maxRuns = defaultEventLogMaxRuns
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := pruneEventLogs(dir, maxRuns-1); err != nil {
+		return nil, errors.Trace(err)
+	}
+	base := filepath.Join(dir, now.UTC().Format(eventLogTimeLayout))
+	plain, err := createFile(base+eventLogPlainExt, perm)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	jsonFile, err := createFile(base+eventLogJSONExt, perm)
+	if err != nil {
+		plain.Close()
+		return nil, errors.Trace(err)
+	}
+	return &eventLog{
+		plain: plain,
+		json:  json.NewEncoder(jsonFile),
+	}, nil
+}
+
+// pruneEventLogs removes the logs of all but the most recent keep runs
+// from dir.
+func pruneEventLogs(dir string, keep int) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	files := make(map[string][]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		if entry.IsDir() || (ext != eventLogPlainExt && ext != eventLogJSONExt) {
+			continue
+		}
+		run := strings.TrimSuffix(name, ext)
+		if _, err := time.Parse(eventLogTimeLayout, run); err != nil {
+			continue
+		}
+		files[run] = append(files[run], name)
+	}
+	runs := make([]string, 0, len(files))
+	for run := range files {
+		runs = append(runs, run)
+	}
+	// The time layout sorts lexically in time order.
+	sort.Strings(runs)
+	for len(runs) > keep {
+		for _, name := range files[runs[0]] {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		runs = runs[1:]
+	}
+	return nil
+}
+
+// Write implements loggo.Writer.
+func (l *eventLog) Write(entry loggo.Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	io.WriteString(l.plain, loggo.DefaultFormatter(entry)+"\n")
+	l.json.Encode(eventLogEntry{
+		Time:     entry.Timestamp.UTC(),
+		Level:    entry.Level.String(),
+		Module:   entry.Module,
+		Location: fmt.Sprintf("%s:%d", filepath.Base(entry.Filename), entry.Line),
+		Message:  entry.Message,
+	})
+}
+
+// event records a named event in the JSON log, and a summary of it in the
+// plain text log. Registered secrets are masked in string fields.
+func (l *eventLog) event(now time.Time, name string, fields map[string]interface{}) {
+	fields = redactFields(fields)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	summary, _ := json.Marshal(fields)
+	fmt.Fprintf(l.plain, "%s EVENT %s %s\n", now.UTC().Format("2006-01-02 15:04:05"), name, summary)
+	l.json.Encode(eventLogEntry{
+		Time:   now.UTC(),
+		Event:  name,
+		Fields: fields,
+	})
+}
+
+// Event records that a significant action took place, such as a file being
+// uploaded, with any details of the action in fields. If an event log
+// directory was configured (see Log.EventLogDir), the event is written to
+// the run's event log; otherwise it is only logged at debug level.
+func (ctx *Context) Event(name string, fields map[string]interface{}) {
+	logger.Debugf("event %s: %v", name, fields)
+	if ctx.eventLog != nil {
+		ctx.eventLog.event(time.Now(), name, fields)
+	}
+}
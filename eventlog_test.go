@@ -0,0 +1,111 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type EventLogSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&EventLogSuite{})
+
+func (s *EventLogSuite) logFiles(c *gc.C, dir string) []string {
+	entries, err := ioutil.ReadDir(dir)
+	c.Assert(err, gc.IsNil)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *EventLogSuite) TestEventLog(c *gc.C) {
+	l := &cmd.Log{EventLogDir: "logs"}
+	ctx := cmdtesting.Context(c)
+	err := l.Start(ctx)
+	c.Assert(err, gc.IsNil)
+
+	logger.Infof("fetching tools")
+	ctx.Event("upload", map[string]interface{}{"path": "tools/juju-2.9.0.tgz", "size": 1024})
+	logger.Debugf("not written")
+
+	// Informational messages are not shown on the console.
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "")
+
+	dir := filepath.Join(ctx.Dir, "logs")
+	files := s.logFiles(c, dir)
+	c.Assert(files, gc.HasLen, 2)
+	c.Assert(files[0], gc.Matches, `\d{8}-\d{6}\.\d{6}\.json`)
+	c.Assert(files[1], gc.Equals, strings.TrimSuffix(files[0], ".json")+".log")
+
+	plain, err := ioutil.ReadFile(filepath.Join(dir, files[1]))
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(plain), gc.Matches, `(?m)^.* INFO juju.test .* fetching tools
+.* EVENT upload {"path":"tools/juju-2.9.0.tgz","size":1024}
+$`)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, files[0]))
+	c.Assert(err, gc.IsNil)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	c.Assert(lines, gc.HasLen, 2)
+	c.Assert(lines[0], gc.Matches, `{"time":".*","level":"INFO","module":"juju.test","location":"eventlog_test.go:\d+","message":"fetching tools"}`)
+	c.Assert(lines[1], gc.Matches, `{"time":".*","event":"upload","fields":{"path":"tools/juju-2.9.0.tgz","size":1024}}`)
+}
+
+func (s *EventLogSuite) TestEventLogShowLog(c *gc.C) {
+	l := &cmd.Log{EventLogDir: "logs", ShowLog: true}
+	ctx := cmdtesting.Context(c)
+	err := l.Start(ctx)
+	c.Assert(err, gc.IsNil)
+	logger.Infof("hello")
+	c.Assert(cmdtesting.Stderr(ctx), gc.Matches, `^.* INFO .* hello\n`)
+}
+
+func (s *EventLogSuite) TestEventWithoutEventLog(c *gc.C) {
+	ctx := cmdtesting.Context(c)
+	ctx.Event("upload", nil)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "")
+}
+
+func (s *EventLogSuite) TestEventLogRotation(c *gc.C) {
+	dir := c.MkDir()
+	for i := 0; i < 5; i++ {
+		for _, ext := range []string{".log", ".json"} {
+			name := fmt.Sprintf("20220101-00000%d.000000%s", i, ext)
+			err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644)
+			c.Assert(err, gc.IsNil)
+		}
+	}
+	err := ioutil.WriteFile(filepath.Join(dir, "unrelated.log"), nil, 0644)
+	c.Assert(err, gc.IsNil)
+
+	l := &cmd.Log{EventLogDir: dir, EventLogMaxRuns: 3}
+	err = l.Start(cmdtesting.Context(c))
+	c.Assert(err, gc.IsNil)
+
+	files := s.logFiles(c, dir)
+	c.Assert(files, gc.HasLen, 7)
+	c.Assert(files[:4], jc.DeepEquals, []string{
+		"20220101-000003.000000.json",
+		"20220101-000003.000000.log",
+		"20220101-000004.000000.json",
+		"20220101-000004.000000.log",
+	})
+	c.Assert(files[6], gc.Equals, "unrelated.log")
+}
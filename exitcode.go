@@ -0,0 +1,109 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"github.com/juju/errors"
+)
+
+// The exit codes with which Main exits, so that scripts can distinguish
+// between kinds of failure. Commands that need other codes can return an
+// RcPassthroughError.
+const (
+	// ExitFailure is the exit code for errors of any kind not listed
+	// below.
+	ExitFailure = 1
+
+	// ExitUsage is the exit code for errors in the use of a command, such
+	// as unknown flags or missing arguments, and for errors created with
+	// UsageErrorf.
+	ExitUsage = 2
+
+	// ExitNotFound is the exit code for errors satisfying
+	// errors.IsNotFound, such as when nothing matches what was asked for.
+	ExitNotFound = 3
+
+	// ExitConflict is the exit code for errors satisfying
+	// errors.IsAlreadyExists, such as when the command would overwrite
+	// something that it should not.
+	ExitConflict = 4
+
+	// ExitRemote is the exit code for errors created with NewRemoteError,
+	// for failures of a remote service or storage that the command uses.
+	ExitRemote = 5
+)
+
+// ExitCode returns the code with which Main exits when a command fails
+// with err. An error wrapped with errors.Annotate, errors.Trace or similar
+// has the code of the error it wraps.
+func ExitCode(err error) int {
+	if rcErr, ok := err.(*RcPassthroughError); ok {
+		return rcErr.Code
+	}
+	switch {
+	case IsUsageError(err):
+		return ExitUsage
+	case errors.IsNotFound(err):
+		return ExitNotFound
+	case errors.IsAlreadyExists(err):
+		return ExitConflict
+	case IsRemoteError(err):
+		return ExitRemote
+	}
+	return ExitFailure
+}
+
+// usageError is an error in the use of a command.
+type usageError struct {
+	errors.Err
+}
+
+// UsageErrorf returns an error in the use of a command, for commands that
+// detect such errors only when they are run. Main exits with ExitUsage for
+// such errors.
+func UsageErrorf(format string, args ...interface{}) error {
+	err := &usageError{errors.NewErr(format, args...)}
+	err.SetLocation(1)
+	return err
+}
+
+// IsUsageError reports whether err, or the error it wraps, was created
+// with UsageErrorf.
+func IsUsageError(err error) bool {
+	_, ok := errors.Cause(err).(*usageError)
+	return ok
+}
+
+// remoteError is a failure of a remote service or storage.
+type remoteError struct {
+	err error
+}
+
+// NewRemoteError returns an error recording that err was caused by a
+// failure of a remote service or storage, such as a failed upload, rather
+// than by the command or its input. Main exits with ExitRemote for such
+// errors.
+func NewRemoteError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &remoteError{err: err}
+}
+
+// Error implements error.
+func (e *remoteError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *remoteError) Unwrap() error {
+	return e.err
+}
+
+// IsRemoteError reports whether err, or the error it wraps, was created
+// with NewRemoteError.
+func IsRemoteError(err error) bool {
+	_, ok := errors.Cause(err).(*remoteError)
+	return ok
+}
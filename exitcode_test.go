@@ -0,0 +1,79 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type ExitCodeSuite struct {
+	testing.LoggingSuite
+}
+
+var _ = gc.Suite(&ExitCodeSuite{})
+
+var exitCodeTests = []struct {
+	err  error
+	code int
+}{
+	{errors.New("failed"), cmd.ExitFailure},
+	{cmd.UsageErrorf("expected %d arguments", 2), cmd.ExitUsage},
+	{errors.NotFoundf("agent binaries"), cmd.ExitNotFound},
+	{errors.AlreadyExistsf("products file"), cmd.ExitConflict},
+	{cmd.NewRemoteError(errors.New("upload failed")), cmd.ExitRemote},
+	{errors.Annotate(errors.NotFoundf("agent binaries"), "generating metadata"), cmd.ExitNotFound},
+	{errors.Trace(cmd.NewRemoteError(errors.New("upload failed"))), cmd.ExitRemote},
+	{cmd.NewRcPassthroughError(7), 7},
+}
+
+func (*ExitCodeSuite) TestExitCode(c *gc.C) {
+	for _, test := range exitCodeTests {
+		c.Check(cmd.ExitCode(test.err), gc.Equals, test.code, gc.Commentf("%v", test.err))
+	}
+}
+
+func (*ExitCodeSuite) TestMain(c *gc.C) {
+	for _, test := range exitCodeTests {
+		ctx := cmdtesting.Context(c)
+		err := test.err
+		command := &TestCommand{Name: "verb", CustomRun: func(*cmd.Context) error { return err }}
+		code := cmd.Main(command, ctx, []string{"--option", "value"})
+		c.Check(code, gc.Equals, test.code, gc.Commentf("%v", test.err))
+	}
+}
+
+func (*ExitCodeSuite) TestSuperCommand(c *gc.C) {
+	for _, test := range exitCodeTests {
+		ctx := cmdtesting.Context(c)
+		err := test.err
+		sc := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "juju-metadata"})
+		sc.Register(&TestCommand{Name: "verb", CustomRun: func(*cmd.Context) error { return err }})
+		code := cmd.Main(sc, ctx, []string{"verb", "--option", "value"})
+		c.Check(code, gc.Equals, test.code, gc.Commentf("%v", test.err))
+		if !cmd.IsRcPassthroughError(err) {
+			c.Check(cmdtesting.Stderr(ctx), gc.Equals, "ERROR "+err.Error()+"\n")
+		}
+	}
+}
+
+func (*ExitCodeSuite) TestRemoteError(c *gc.C) {
+	c.Assert(cmd.NewRemoteError(nil), jc.ErrorIsNil)
+	err := cmd.NewRemoteError(errors.New("upload failed"))
+	c.Assert(err, gc.ErrorMatches, "upload failed")
+	c.Assert(cmd.IsRemoteError(err), jc.IsTrue)
+	c.Assert(cmd.IsRemoteError(errors.New("upload failed")), jc.IsFalse)
+}
+
+func (*ExitCodeSuite) TestUsageError(c *gc.C) {
+	err := cmd.UsageErrorf("expected %d arguments", 2)
+	c.Assert(err, gc.ErrorMatches, "expected 2 arguments")
+	c.Assert(cmd.IsUsageError(err), jc.IsTrue)
+	c.Assert(cmd.IsUsageError(errors.New("expected 2 arguments")), jc.IsFalse)
+}
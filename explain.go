@@ -0,0 +1,93 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/juju/gnuflag"
+)
+
+// Explainer is implemented by commands that can describe the operations
+// they would perform, without performing them. When a SuperCommand selects
+// a subcommand that implements Explainer, it accepts an --explain flag which
+// causes Explain to be called instead of Run.
+type Explainer interface {
+	// Explain returns the plan of operations the command would perform
+	// with its current flags and arguments. It is called after Init, and
+	// must not have any side effects.
+	Explain(ctx *Context) (*Plan, error)
+}
+
+// Plan describes the operations a command would perform.
+type Plan struct {
+	// Summary is a short, human readable, description of the plan.
+	Summary string `yaml:"summary,omitempty" json:"summary,omitempty"`
+
+	// Steps holds the individual operations, in the order in which they
+	// would be performed.
+	Steps []PlanStep `yaml:"steps" json:"steps"`
+}
+
+// PlanStep describes a single operation within a Plan.
+type PlanStep struct {
+	// Action names the operation, e.g. "upload" or "delete".
+	Action string `yaml:"action" json:"action"`
+
+	// Source is where the operation reads from, if anywhere.
+	Source string `yaml:"source,omitempty" json:"source,omitempty"`
+
+	// Destination is where the operation writes to, if anywhere.
+	Destination string `yaml:"destination,omitempty" json:"destination,omitempty"`
+
+	// Count is the number of items the operation affects, if known.
+	Count int `yaml:"count,omitempty" json:"count,omitempty"`
+}
+
+// AddStep appends a new step to the plan.
+func (p *Plan) AddStep(step PlanStep) {
+	p.Steps = append(p.Steps, step)
+}
+
+const explainDoc = "Describe the operations the command would perform, without performing them"
+
+// addExplainFlag adds the --explain flag to f if command implements
+// Explainer.
+func addExplainFlag(f *gnuflag.FlagSet, command Command, target *bool) {
+	if _, ok := command.(Explainer); ok {
+		f.BoolVar(target, "explain", false, explainDoc)
+	}
+}
+
+// FormatPlan writes a human readable rendering of the plan to writer.
+func FormatPlan(writer io.Writer, plan *Plan) error {
+	if plan.Summary != "" {
+		if _, err := fmt.Fprintf(writer, "%s\n", plan.Summary); err != nil {
+			return err
+		}
+	}
+	if len(plan.Steps) == 0 {
+		_, err := fmt.Fprintf(writer, "Nothing to do.\n")
+		return err
+	}
+	for i, step := range plan.Steps {
+		line := fmt.Sprintf("%d. %s", i+1, step.Action)
+		switch {
+		case step.Source != "" && step.Destination != "":
+			line += fmt.Sprintf(" %s -> %s", step.Source, step.Destination)
+		case step.Source != "":
+			line += " " + step.Source
+		case step.Destination != "":
+			line += " " + step.Destination
+		}
+		if step.Count > 0 {
+			line += fmt.Sprintf(" (count: %d)", step.Count)
+		}
+		if _, err := fmt.Fprintf(writer, "%s\n", line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"github.com/juju/gnuflag"
+	"github.com/juju/loggo"
+	gitjujutesting "github.com/juju/testing"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type ExplainSuite struct {
+	gitjujutesting.IsolationSuite
+
+	ctx *cmd.Context
+}
+
+var _ = gc.Suite(&ExplainSuite{})
+
+func (s *ExplainSuite) SetUpTest(c *gc.C) {
+	s.IsolationSuite.SetUpTest(c)
+	s.ctx = cmdtesting.Context(c)
+	loggo.ReplaceDefaultWriter(cmd.NewWarningWriter(s.ctx.Stderr))
+}
+
+type explainCommand struct {
+	cmd.CommandBase
+	out cmd.Output
+	ran bool
+}
+
+func (c *explainCommand) Info() *cmd.Info {
+	return &cmd.Info{Name: "publish", Purpose: "publish things"}
+}
+
+func (c *explainCommand) SetFlags(f *gnuflag.FlagSet) {
+	c.out.AddFlags(f, "smart", cmd.DefaultFormatters.Formatters())
+}
+
+func (c *explainCommand) Run(ctx *cmd.Context) error {
+	c.ran = true
+	return nil
+}
+
+func (c *explainCommand) Explain(ctx *cmd.Context) (*cmd.Plan, error) {
+	plan := &cmd.Plan{Summary: "Publish 2 streams"}
+	plan.AddStep(cmd.PlanStep{Action: "upload", Source: "/tmp/tools", Destination: "s3://bucket", Count: 12})
+	plan.AddStep(cmd.PlanStep{Action: "sign", Destination: "s3://bucket/index.sjson"})
+	return plan, nil
+}
+
+func (s *ExplainSuite) newSuperCommand(command cmd.Command) *cmd.SuperCommand {
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "jujutest"})
+	sc.Register(command)
+	return sc
+}
+
+func (s *ExplainSuite) TestRunWithoutExplain(c *gc.C) {
+	command := &explainCommand{}
+	code := cmd.Main(s.newSuperCommand(command), s.ctx, []string{"publish"})
+	c.Assert(code, gc.Equals, 0)
+	c.Check(command.ran, gc.Equals, true)
+	c.Check(cmdtesting.Stdout(s.ctx), gc.Equals, "")
+}
+
+func (s *ExplainSuite) TestExplain(c *gc.C) {
+	command := &explainCommand{}
+	code := cmd.Main(s.newSuperCommand(command), s.ctx, []string{"publish", "--explain"})
+	c.Assert(code, gc.Equals, 0)
+	c.Check(command.ran, gc.Equals, false)
+	c.Check(cmdtesting.Stdout(s.ctx), gc.Equals, `
+Publish 2 streams
+1. upload /tmp/tools -> s3://bucket (count: 12)
+2. sign s3://bucket/index.sjson
+`[1:])
+}
+
+func (s *ExplainSuite) TestExplainSerialised(c *gc.C) {
+	command := &explainCommand{}
+	code := cmd.Main(s.newSuperCommand(command), s.ctx, []string{"publish", "--explain", "--format", "json"})
+	c.Assert(code, gc.Equals, 0)
+	c.Check(command.ran, gc.Equals, false)
+	c.Check(cmdtesting.Stdout(s.ctx), gc.Equals, `{"summary":"Publish 2 streams","steps":[`+
+		`{"action":"upload","source":"/tmp/tools","destination":"s3://bucket","count":12},`+
+		`{"action":"sign","destination":"s3://bucket/index.sjson"}]}`+"\n")
+}
+
+func (s *ExplainSuite) TestExplainNotSupported(c *gc.C) {
+	code := cmd.Main(s.newSuperCommand(&TestCommand{Name: "blah"}), s.ctx, []string{"blah", "--explain"})
+	c.Assert(code, gc.Equals, 2)
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, `
+ERROR flag provided but not defined: --explain
+Usage: jujutest blah [flags] <something>
+See "jujutest help blah" for details.
+`[1:])
+}
+
+func (s *ExplainSuite) TestExplainInHelp(c *gc.C) {
+	code := cmd.Main(s.newSuperCommand(&explainCommand{}), s.ctx, []string{"help", "publish"})
+	c.Assert(code, gc.Equals, 0)
+	c.Check(cmdtesting.Stdout(s.ctx), gc.Matches, `(?s).*--explain  \(= false\)\n    Describe the operations .*`)
+}
@@ -6,3 +6,25 @@ package cmd
 func NewVersionCommand(version string, versionDetail interface{}) Command {
 	return newVersionCommand(version, versionDetail)
 }
+
+var SetProcessTitle = &setProcessTitle
+
+var Geteuid = &geteuid
+
+func ResetSecrets() {
+	secrets.reset()
+}
+
+var (
+	NotifySignals = &notifySignals
+	StopSignals   = &stopSignals
+	OsExit        = &osExit
+)
+
+var (
+	ProgressNow = &progressNow
+	IsTerminal  = &isTerminal
+	DurationNow = &durationNow
+)
+
+var TableSampleRows = &tableSampleRows
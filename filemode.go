@@ -0,0 +1,85 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/juju/errors"
+)
+
+const (
+	// DefaultFileMode is the permissions of the files written by the
+	// framework, such as command output, logs and generated documentation,
+	// unless they are overridden with the --file-mode flag (see Log).
+	DefaultFileMode os.FileMode = 0644
+
+	// PrivateFileMode is the permissions to use for files that only their
+	// owner may read, such as signing keys. The --file-mode flag never
+	// makes such files accessible to other users.
+	PrivateFileMode os.FileMode = 0600
+)
+
+// FileMode returns the permissions with which a file that would otherwise
+// have permissions perm should be written. Files are written with perm
+// unless another mode was chosen with the --file-mode flag. Files with
+// private permissions, which give no access to the group or other users,
+// have any such access removed from the chosen mode.
+//
+// Files created with WriteFile, WriteFileFunc and Output, and log files,
+// are given these permissions exactly, whatever the process umask.
+func (ctx *Context) FileMode(perm os.FileMode) os.FileMode {
+	if ctx.fileMode == 0 {
+		return perm
+	}
+	mode := ctx.fileMode
+	if perm&0077 == 0 {
+		mode &^= 0077
+	}
+	return mode
+}
+
+// fileModeValue implements gnuflag.Value for a file mode given in octal.
+type fileModeValue struct {
+	mode *os.FileMode
+}
+
+// Set implements gnuflag.Value.
+func (v fileModeValue) Set(s string) error {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0777 {
+		return errors.Errorf("expected octal permissions such as 0644")
+	}
+	*v.mode = os.FileMode(mode)
+	return nil
+}
+
+// String implements gnuflag.Value.
+func (v fileModeValue) String() string {
+	if v.mode == nil || *v.mode == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%04o", uint32(*v.mode))
+}
+
+// createFile opens the named file for appending, creating it with
+// permissions perm if it does not exist. As for WriteFile, a new file is
+// given those permissions whatever the process umask.
+func createFile(path string, perm os.FileMode) (*os.File, error) {
+	_, err := os.Lstat(path)
+	created := os.IsNotExist(err)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, perm)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		if err := file.Chmod(perm); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return file, nil
+}
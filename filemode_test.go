@@ -0,0 +1,96 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type FileModeSuite struct {
+	testing.LoggingCleanupSuite
+	ctx *cmd.Context
+}
+
+var _ = gc.Suite(&FileModeSuite{})
+
+func (s *FileModeSuite) SetUpTest(c *gc.C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("file permissions are not supported on windows")
+	}
+	s.LoggingCleanupSuite.SetUpTest(c)
+	s.ctx = cmdtesting.Context(c)
+}
+
+func (s *FileModeSuite) startLog(c *gc.C, flags ...string) {
+	log := newLogWithFlags(c, "", flags...)
+	err := log.Start(s.ctx)
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *FileModeSuite) assertMode(c *gc.C, name string, mode os.FileMode) {
+	info, err := os.Stat(filepath.Join(s.ctx.Dir, name))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(info.Mode().Perm(), gc.Equals, mode)
+}
+
+func (s *FileModeSuite) TestDefault(c *gc.C) {
+	s.startLog(c, "--log-file", "cmd.log")
+	c.Assert(s.ctx.FileMode(cmd.DefaultFileMode), gc.Equals, cmd.DefaultFileMode)
+	c.Assert(s.ctx.FileMode(cmd.PrivateFileMode), gc.Equals, cmd.PrivateFileMode)
+
+	err := s.ctx.WriteFile("index.json", nil, cmd.DefaultFileMode)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertMode(c, "index.json", 0644)
+	s.assertMode(c, "cmd.log", 0644)
+}
+
+func (s *FileModeSuite) TestOverride(c *gc.C) {
+	s.startLog(c, "--file-mode", "0664", "--log-file", "cmd.log")
+	c.Assert(s.ctx.FileMode(cmd.DefaultFileMode), gc.Equals, os.FileMode(0664))
+
+	err := s.ctx.WriteFile("index.json", nil, cmd.DefaultFileMode)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertMode(c, "index.json", 0664)
+	s.assertMode(c, "cmd.log", 0664)
+}
+
+func (s *FileModeSuite) TestOverrideKeepsPrivateFilesPrivate(c *gc.C) {
+	s.startLog(c, "--file-mode", "0664")
+	c.Assert(s.ctx.FileMode(cmd.PrivateFileMode), gc.Equals, os.FileMode(0600))
+}
+
+func (s *FileModeSuite) TestOverrideRestrictsPrivateFiles(c *gc.C) {
+	s.startLog(c, "--file-mode", "0400")
+	c.Assert(s.ctx.FileMode(cmd.PrivateFileMode), gc.Equals, os.FileMode(0400))
+	err := s.ctx.WriteFile("signing.key", nil, cmd.PrivateFileMode)
+	c.Assert(err, jc.ErrorIsNil)
+	s.assertMode(c, "signing.key", 0400)
+}
+
+func (s *FileModeSuite) TestExistingLogFileUnchanged(c *gc.C) {
+	err := s.ctx.WriteFile("cmd.log", nil, 0600)
+	c.Assert(err, jc.ErrorIsNil)
+	s.startLog(c, "--file-mode", "0644", "--log-file", "cmd.log")
+	s.assertMode(c, "cmd.log", 0600)
+}
+
+func (s *FileModeSuite) TestInvalid(c *gc.C) {
+	for _, value := range []string{"rw-r--r--", "644a", "1777"} {
+		c.Logf("value %q", value)
+		log := &cmd.Log{}
+		f := cmdtesting.NewFlagSet()
+		log.AddFlags(f)
+		err := f.Parse(false, []string{"--file-mode", value})
+		c.Check(err, gc.ErrorMatches, `invalid value ".*" for flag --file-mode: expected octal permissions such as 0644`)
+	}
+}
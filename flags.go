@@ -0,0 +1,555 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/juju/errors"
+	"github.com/juju/gnuflag"
+)
+
+// FlagGroup names a set of related flags, so that they are shown together
+// in help output.
+type FlagGroup struct {
+	// Name is the group's name, shown as a heading in help output.
+	Name string
+
+	// Flags holds the names of the flags in the group, without the
+	// leading dashes.
+	Flags []string
+}
+
+// flagValues returns the set of values of the named flags in f. As aliased
+// flags share a value, this allows all names of a flag to be matched from
+// any one of them.
+func flagValues(f *gnuflag.FlagSet, names []string) map[gnuflag.Value]bool {
+	values := make(map[gnuflag.Value]bool)
+	for _, name := range names {
+		if flag := f.Lookup(name); flag != nil {
+			values[flag.Value] = true
+		}
+	}
+	return values
+}
+
+// wrapFlagValue replaces the value of the named flag in f, and of any flags
+// aliased to it, with the result of calling wrap with that value.
+func wrapFlagValue(f *gnuflag.FlagSet, name string, wrap func(gnuflag.Value) gnuflag.Value) error {
+	flag := f.Lookup(name)
+	if flag == nil {
+		return errors.NotFoundf("%s %q", f.FlagKnownAs, name)
+	}
+	original := flag.Value
+	wrapped := wrap(original)
+	f.VisitAll(func(flag *gnuflag.Flag) {
+		if flag.Value == original {
+			flag.Value = wrapped
+		}
+	})
+	return nil
+}
+
+// wrappedValue is implemented by the flag values created by wrapFlagValue.
+type wrappedValue interface {
+	unwrap() gnuflag.Value
+}
+
+// unwrapFlagValue returns the original value of a flag wrapped by
+// wrapFlagValue. As gnuflag quotes the defaults of string flags in help
+// output by looking at the type of their values, help output must be
+// written using the original values.
+func unwrapFlagValue(value gnuflag.Value) gnuflag.Value {
+	for {
+		wrapped, ok := value.(wrappedValue)
+		if !ok {
+			return value
+		}
+		value = wrapped.unwrap()
+	}
+}
+
+// isBoolValue reports whether value is parsed as a boolean flag.
+func isBoolValue(value gnuflag.Value) bool {
+	b, ok := value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// FlagValidator checks a value given for a flag, returning an error that
+// describes why it is invalid, if it is.
+type FlagValidator func(value string) error
+
+// ValidateFlag arranges for the named flag of f, and any flags aliased to
+// it, to be checked by validate whenever they are set. The check happens as
+// the arguments are parsed, before the command's Init method is called, and
+// an invalid value is reported with the name of the flag, e.g.
+//
+//	invalid value "2.9" for flag --agent-version: expected major.minor.patch
+//
+// It must be called after the flag is defined, and before f is parsed; it
+// is typically called at the end of a command's SetFlags method.
+func ValidateFlag(f *gnuflag.FlagSet, name string, validate FlagValidator) error {
+	return wrapFlagValue(f, name, func(value gnuflag.Value) gnuflag.Value {
+		return &validatedValue{Value: value, validate: validate}
+	})
+}
+
+// validatedValue wraps a flag value, checking each value before it is set.
+type validatedValue struct {
+	gnuflag.Value
+	validate FlagValidator
+}
+
+// Set implements gnuflag.Value.
+func (v *validatedValue) Set(s string) error {
+	if err := v.validate(s); err != nil {
+		return err
+	}
+	return v.Value.Set(s)
+}
+
+// IsBoolFlag implements the optional gnuflag boolFlag interface, so that
+// wrapping a boolean flag does not change how it is parsed.
+func (v *validatedValue) IsBoolFlag() bool {
+	return isBoolValue(v.Value)
+}
+
+func (v *validatedValue) unwrap() gnuflag.Value {
+	return v.Value
+}
+
+// FlagFromEnv arranges for the named flag of f, and any flags aliased to
+// it, to take its value from the environment variable envVar when it is
+// not given on the command line, so that commands can be configured
+// without editing their invocations. A value given on the command line
+// takes precedence over one from the environment, which takes precedence
+// over the flag's default. An empty variable is treated as unset. The help
+// for the flag names the variable.
+//
+// Like ValidateFlag, it must be called after the flag is defined, and
+// before f is parsed. Main and SuperCommand apply the environment before
+// parsing the command line; others parsing f must call ApplyFlagEnv.
+func FlagFromEnv(f *gnuflag.FlagSet, name, envVar string) error {
+	err := wrapFlagValue(f, name, func(value gnuflag.Value) gnuflag.Value {
+		return &envValue{Value: value, envVar: envVar}
+	})
+	if err != nil {
+		return err
+	}
+	value := f.Lookup(name).Value
+	f.VisitAll(func(flag *gnuflag.Flag) {
+		if flag.Value == value && flag.Usage != "" {
+			flag.Usage += " [$" + envVar + "]"
+		}
+	})
+	return nil
+}
+
+// envValue wraps a flag value that may be set from an environment variable.
+type envValue struct {
+	gnuflag.Value
+	envVar string
+}
+
+// IsBoolFlag implements the optional gnuflag boolFlag interface.
+func (v *envValue) IsBoolFlag() bool {
+	return isBoolValue(v.Value)
+}
+
+func (v *envValue) unwrap() gnuflag.Value {
+	return v.Value
+}
+
+// flagEnvVar returns the environment variable from which a flag with the
+// given value may be set, if any.
+func flagEnvVar(value gnuflag.Value) (string, bool) {
+	for {
+		if env, ok := value.(*envValue); ok {
+			return env.envVar, true
+		}
+		wrapped, ok := value.(wrappedValue)
+		if !ok {
+			return "", false
+		}
+		value = wrapped.unwrap()
+	}
+}
+
+// ApplyFlagEnv sets the flags of f declared with FlagFromEnv from their
+// environment variables, if they are set. It must be called before f is
+// parsed, so that values given on the command line take precedence.
+func ApplyFlagEnv(f *gnuflag.FlagSet) error {
+	return applyFlagEnv(f, nil)
+}
+
+// applyFlagEnv is ApplyFlagEnv, except that flags whose unwrapped values
+// are in given are left alone, as they have already been set.
+func applyFlagEnv(f *gnuflag.FlagSet, given map[gnuflag.Value]bool) error {
+	var err error
+	applied := make(map[gnuflag.Value]bool)
+	f.VisitAll(func(flag *gnuflag.Flag) {
+		// Each value is set once, by a name that is not deprecated, so
+		// that no deprecation warning is given for a flag that was not
+		// used.
+		envVar, ok := flagEnvVar(flag.Value)
+		if err != nil || !ok || isDeprecatedFlag(flag) {
+			return
+		}
+		target := unwrapFlagValue(flag.Value)
+		if applied[target] || given[target] {
+			return
+		}
+		applied[target] = true
+		value := os.Getenv(envVar)
+		if value == "" {
+			return
+		}
+		if setErr := f.Set(flag.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s %s from $%s: %v",
+				value, f.FlagKnownAs, flagWithDashes(flag.Name), envVar, setErr)
+		}
+	})
+	return err
+}
+
+// DeprecateFlag makes old a deprecated name for the flag new of f, so that
+// existing invocations keep working while the flag is renamed. The old name
+// sets the same value as new, but is hidden from help and documentation,
+// and a warning naming the replacement is shown the first time it is used.
+// If old is already defined, it is redefined as a name for new.
+//
+// DeprecateFlag must be called after new is defined, and after any calls to
+// ValidateFlag or FlagFromEnv for it.
+func DeprecateFlag(f *gnuflag.FlagSet, old, new string) error {
+	newFlag := f.Lookup(new)
+	if newFlag == nil {
+		return errors.NotFoundf("%s %q", f.FlagKnownAs, new)
+	}
+	value := &deprecatedValue{Value: newFlag.Value, old: old, new: new}
+	if oldFlag := f.Lookup(old); oldFlag != nil {
+		oldFlag.Value = value
+		oldFlag.Usage = ""
+	} else {
+		f.Var(value, old, "")
+	}
+	return nil
+}
+
+// deprecatedValue wraps the value of a flag set by a deprecated name.
+type deprecatedValue struct {
+	gnuflag.Value
+	old, new string
+	used     bool
+	warned   bool
+}
+
+// Set implements gnuflag.Value.
+func (v *deprecatedValue) Set(s string) error {
+	v.used = true
+	return v.Value.Set(s)
+}
+
+// IsBoolFlag implements the optional gnuflag boolFlag interface.
+func (v *deprecatedValue) IsBoolFlag() bool {
+	return isBoolValue(v.Value)
+}
+
+func (v *deprecatedValue) unwrap() gnuflag.Value {
+	return v.Value
+}
+
+// isDeprecatedFlag reports whether flag is a deprecated name for another
+// flag, and so should not be shown in help.
+func isDeprecatedFlag(flag *gnuflag.Flag) bool {
+	_, ok := flag.Value.(*deprecatedValue)
+	return ok
+}
+
+// warnDeprecatedFlags warns about each deprecated flag name in f that was
+// used, if it has not already been warned about.
+func warnDeprecatedFlags(ctx *Context, f *gnuflag.FlagSet) {
+	f.VisitAll(func(flag *gnuflag.Flag) {
+		value, ok := flag.Value.(*deprecatedValue)
+		if !ok || !value.used || value.warned {
+			return
+		}
+		value.warned = true
+		ctx.Warningf("%s %s is deprecated, please use %s",
+			f.FlagKnownAs, flagWithDashes(value.old), flagWithDashes(value.new))
+	})
+}
+
+// CheckRequiredFlags returns an error naming all of the flags listed in
+// info.RequiredFlags that have not been set in f. It should be called after
+// the flags have been parsed.
+func CheckRequiredFlags(info *Info, f *gnuflag.FlagSet) error {
+	// Values are compared unwrapped so that a flag set by another name,
+	// such as a deprecated one, satisfies the requirement.
+	given := make(map[gnuflag.Value]bool)
+	f.Visit(func(flag *gnuflag.Flag) {
+		given[unwrapFlagValue(flag.Value)] = true
+	})
+	return checkRequiredFlags(info, f, given)
+}
+
+// checkRequiredFlags is CheckRequiredFlags, with the flags that have been
+// set given by their unwrapped values, as they may have been set on
+// another flag set sharing them.
+func checkRequiredFlags(info *Info, f *gnuflag.FlagSet, given map[gnuflag.Value]bool) error {
+	if len(info.RequiredFlags) == 0 {
+		return nil
+	}
+	var missing []string
+	for _, name := range info.RequiredFlags {
+		flag := f.Lookup(name)
+		if flag == nil || !given[unwrapFlagValue(flag.Value)] {
+			missing = append(missing, flagWithDashes(name))
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	knownAs := f.FlagKnownAs
+	if knownAs == "" {
+		knownAs = "flag"
+	}
+	if len(missing) == 1 {
+		return fmt.Errorf("missing required %v: %s", knownAs, missing[0])
+	}
+	return fmt.Errorf("missing required %vs: %s", knownAs, strings.Join(missing, ", "))
+}
+
+// printFlags writes the documentation for the flags in f to out. Flags that
+// are not in any of the info's flag groups are written first, under the
+// given heading, followed by each of the groups in turn. Required flags
+// are marked as such.
+func (i *Info) printFlags(out io.Writer, f *gnuflag.FlagSet, heading string) {
+	required := flagValues(f, i.RequiredFlags)
+	printSubset := func(heading string, include func(*gnuflag.Flag) bool) {
+		subset := gnuflag.NewFlagSetWithFlagKnownAs("", gnuflag.ContinueOnError, f.FlagKnownAs)
+		found := false
+		f.VisitAll(func(flag *gnuflag.Flag) {
+			if isDeprecatedFlag(flag) || !include(flag) {
+				return
+			}
+			found = true
+			usage := flag.Usage
+			if required[flag.Value] && usage != "" {
+				usage = "(required) " + usage
+			}
+			subset.Var(unwrapFlagValue(flag.Value), flag.Name, usage)
+		})
+		if !found {
+			return
+		}
+		fmt.Fprintf(out, "\n%s:\n", heading)
+		subset.SetOutput(out)
+		subset.PrintDefaults()
+	}
+
+	grouped := make(map[gnuflag.Value]bool)
+	for _, group := range i.FlagGroups {
+		for value := range flagValues(f, group.Flags) {
+			grouped[value] = true
+		}
+	}
+	printSubset(heading, func(flag *gnuflag.Flag) bool {
+		return !grouped[flag.Value]
+	})
+	for _, group := range i.FlagGroups {
+		values := flagValues(f, group.Flags)
+		printSubset(fmt.Sprintf("%s %vs", group.Name, strings.Title(f.FlagKnownAs)), func(flag *gnuflag.Flag) bool {
+			return values[flag.Value]
+		})
+	}
+}
+
+// flagParseError is an error from parsing a command's flags, along with
+// context to help the user correct it: the help for the flag concerned,
+// the usage line of the command, and how to get the command's full help.
+type flagParseError struct {
+	err     error
+	context string
+}
+
+// Error implements error.
+func (e *flagParseError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error returned by the flag set.
+func (e *flagParseError) Unwrap() error {
+	return e.err
+}
+
+// flagErrorPatterns match the errors returned by gnuflag when a flag
+// cannot be parsed, capturing the name of the flag concerned. Each
+// pattern is formatted with the name by which flags are known.
+var flagErrorPatterns = []string{
+	`^%s provided but not defined: --?(.+)$`,
+	`^%s needs an argument: --?(.+)$`,
+	`^invalid boolean %s (.+?): `,
+	`^invalid value "(?:[^"\\]|\\.)*" for %s --?(.+?): `,
+}
+
+// failedFlagName returns the name of the flag reported in err, an error
+// returned by parsing f.
+func failedFlagName(f *gnuflag.FlagSet, err error) (string, bool) {
+	for _, pattern := range flagErrorPatterns {
+		re := regexp.MustCompile(fmt.Sprintf(pattern, regexp.QuoteMeta(f.FlagKnownAs)))
+		if m := re.FindStringSubmatch(err.Error()); m != nil {
+			return m[1], true
+		}
+	}
+	return "", false
+}
+
+// withFlagContext returns err, an error returned by parsing f, the flags
+// of the command described by info, with context that helps the user to
+// correct it. Rather than the full help for the command, the context shows
+// the help for the flag concerned, if it is defined, and the usage line of
+// the command, with a pointer to helpCommand for the full help. Other
+// errors are returned unchanged.
+func withFlagContext(err error, f *gnuflag.FlagSet, info *Info, helpCommand string) error {
+	if err == nil || err == gnuflag.ErrHelp {
+		return err
+	}
+	if _, ok := err.(*flagParseError); ok {
+		return err
+	}
+	name, ok := failedFlagName(f, err)
+	if !ok {
+		return err
+	}
+	var buf bytes.Buffer
+	if flag := f.Lookup(name); flag != nil {
+		// Errors for a deprecated name describe the flag replacing it.
+		value := flag.Value
+		if deprecated, ok := value.(*deprecatedValue); ok {
+			value = deprecated.Value
+		}
+		subset := gnuflag.NewFlagSetWithFlagKnownAs("", gnuflag.ContinueOnError, f.FlagKnownAs)
+		f.VisitAll(func(other *gnuflag.Flag) {
+			if other.Value == value {
+				subset.Var(unwrapFlagValue(other.Value), other.Name, other.Usage)
+			}
+		})
+		subset.SetOutput(&buf)
+		subset.PrintDefaults()
+	}
+	buf.WriteString(info.usageLine(f))
+	fmt.Fprintf(&buf, "See %q for details.\n", helpCommand)
+	return &flagParseError{err: err, context: buf.String()}
+}
+
+// ParsePosixFlags parses args with f as f.Parse does, but treats combined
+// short flags as POSIX getopt does, so that the final argument is handled
+// like any other: "-vq" is the same as "-v -q", and "-ofile" is the same as
+// "-o file" when -o takes a value. Commands opt in to this with
+// Info.PosixFlags.
+func ParsePosixFlags(f *gnuflag.FlagSet, allowIntersperse bool, args []string) error {
+	return f.Parse(allowIntersperse, expandShortFlags(f, allowIntersperse, args, true))
+}
+
+// ParseFlags parses args with f as the commands of this package are
+// parsed: with ParsePosixFlags if posix is set, and otherwise with f.Parse,
+// except that repeated counting flags such as -vv are always counted fully,
+// even in the final argument.
+func ParseFlags(f *gnuflag.FlagSet, posix, allowIntersperse bool, args []string) error {
+	if posix {
+		return ParsePosixFlags(f, allowIntersperse, args)
+	}
+	return f.Parse(allowIntersperse, expandShortFlags(f, allowIntersperse, args, false))
+}
+
+// isCountingValue reports whether value counts the number of times its
+// flag is given, as the verbose flags do.
+func isCountingValue(value gnuflag.Value) bool {
+	_, ok := unwrapFlagValue(value).(*verbosityValue)
+	return ok
+}
+
+// expandShortFlags returns args with each group of combined short flags
+// split into separate arguments, and any value attached to the last of
+// them made an argument of its own. If all is not set, only groups made
+// up entirely of counting flags (see isCountingValue) are split, as gnuflag
+// ignores all but the first flag of a group in the final argument.
+// Arguments that are values of flags, or that follow the "--" terminator
+// or (if allowIntersperse is not set) the first non-flag argument, are
+// left alone. Unknown flags are passed on unchanged, for f to report.
+func expandShortFlags(f *gnuflag.FlagSet, allowIntersperse bool, args []string, all bool) []string {
+	takesValue := func(name string) bool {
+		flag := f.Lookup(name)
+		return flag != nil && !isBoolValue(flag.Value)
+	}
+	counting := func(group string) bool {
+		for _, r := range group {
+			flag := f.Lookup(string(r))
+			if flag == nil || !isCountingValue(flag.Value) {
+				return false
+			}
+		}
+		return true
+	}
+	result := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			return append(result, args[i:]...)
+		case arg == "-" || !strings.HasPrefix(arg, "-"):
+			if !allowIntersperse {
+				return append(result, args[i:]...)
+			}
+			result = append(result, arg)
+			continue
+		case strings.HasPrefix(arg, "--"):
+			result = append(result, arg)
+			if !strings.Contains(arg, "=") && takesValue(arg[2:]) && i+1 < len(args) {
+				i++
+				result = append(result, args[i])
+			}
+			continue
+		}
+		group := arg[1:]
+		if !all && !counting(group) {
+			// Leave the group to gnuflag, but skip over the value of
+			// its flag if that is the following argument.
+			result = append(result, arg)
+			for n, r := range group {
+				if takesValue(string(r)) {
+					if n+utf8.RuneLen(r) == len(group) && i+1 < len(args) {
+						i++
+						result = append(result, args[i])
+					}
+					break
+				}
+			}
+			continue
+		}
+		for group != "" {
+			_, n := utf8.DecodeRuneInString(group)
+			name := group[:n]
+			group = group[n:]
+			result = append(result, "-"+name)
+			if !takesValue(name) {
+				continue
+			}
+			if group != "" {
+				result = append(result, group)
+			} else if i+1 < len(args) {
+				i++
+				result = append(result, args[i])
+			}
+			break
+		}
+	}
+	return result
+}
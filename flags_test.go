@@ -0,0 +1,591 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"github.com/juju/gnuflag"
+	"github.com/juju/loggo"
+	gitjujutesting "github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type FlagValidatorSuite struct {
+	gitjujutesting.IsolationSuite
+}
+
+var _ = gc.Suite(&FlagValidatorSuite{})
+
+func validateVersion(value string) error {
+	if strings.Count(value, ".") != 2 {
+		return errors.New("expected major.minor.patch")
+	}
+	return nil
+}
+
+type validatedCommand struct {
+	cmd.CommandBase
+	version string
+	initted bool
+}
+
+func (c *validatedCommand) Info() *cmd.Info {
+	return &cmd.Info{Name: "sync", Purpose: "sync things"}
+}
+
+func (c *validatedCommand) SetFlags(f *gnuflag.FlagSet) {
+	f.StringVar(&c.version, "agent-version", "", "the agent version")
+	f.StringVar(&c.version, "a", "", "the agent version")
+	if err := cmd.ValidateFlag(f, "agent-version", validateVersion); err != nil {
+		panic(err)
+	}
+}
+
+func (c *validatedCommand) Init(args []string) error {
+	c.initted = true
+	return cmd.CheckEmpty(args)
+}
+
+func (c *validatedCommand) Run(ctx *cmd.Context) error {
+	return nil
+}
+
+func (s *FlagValidatorSuite) TestValid(c *gc.C) {
+	command := &validatedCommand{}
+	err := cmdtesting.InitCommand(command, []string{"--agent-version", "2.9.1"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(command.version, gc.Equals, "2.9.1")
+}
+
+func (s *FlagValidatorSuite) TestInvalid(c *gc.C) {
+	command := &validatedCommand{}
+	err := cmdtesting.InitCommand(command, []string{"--agent-version", "2.9"})
+	c.Assert(err, gc.ErrorMatches, `invalid value "2.9" for flag --agent-version: expected major.minor.patch`)
+	c.Assert(command.version, gc.Equals, "")
+	c.Assert(command.initted, jc.IsFalse)
+}
+
+func (s *FlagValidatorSuite) TestInvalidAlias(c *gc.C) {
+	command := &validatedCommand{}
+	err := cmdtesting.InitCommand(command, []string{"-a", "2"})
+	c.Assert(err, gc.ErrorMatches, `invalid value "2" for flag -a: expected major.minor.patch`)
+}
+
+func (s *FlagValidatorSuite) TestInvalidInSuperCommand(c *gc.C) {
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "jujutest"})
+	command := &validatedCommand{}
+	sc.Register(command)
+	ctx := cmdtesting.Context(c)
+	code := cmd.Main(sc, ctx, []string{"sync", "--agent-version", "latest"})
+	c.Assert(code, gc.Equals, 2)
+	c.Assert(command.initted, jc.IsFalse)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, `
+ERROR invalid value "latest" for flag --agent-version: expected major.minor.patch
+-a, --agent-version (= "")
+    the agent version
+Usage: jujutest sync [flags]
+See "jujutest help sync" for details.
+`[1:])
+}
+
+func (s *FlagValidatorSuite) TestHelpUnchanged(c *gc.C) {
+	c.Assert(cmdtesting.HelpText(&validatedCommand{}, "sync"), gc.Equals, `
+Usage: sync [flags]
+
+Summary:
+sync things
+
+Flags:
+-a, --agent-version (= "")
+    the agent version
+`[1:])
+}
+
+func (s *FlagValidatorSuite) TestBoolFlag(c *gc.C) {
+	var force bool
+	f := cmdtesting.NewFlagSet()
+	f.BoolVar(&force, "force", false, "")
+	err := cmd.ValidateFlag(f, "force", func(string) error { return nil })
+	c.Assert(err, jc.ErrorIsNil)
+	err = f.Parse(false, []string{"--force", "arg"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(force, jc.IsTrue)
+	c.Assert(f.Args(), gc.DeepEquals, []string{"arg"})
+}
+
+func (s *FlagValidatorSuite) TestUnknownFlag(c *gc.C) {
+	f := cmdtesting.NewFlagSet()
+	f.SetOutput(&bytes.Buffer{})
+	err := cmd.ValidateFlag(f, "missing", validateVersion)
+	c.Assert(err, gc.ErrorMatches, `flag "missing" not found`)
+}
+
+type FlagErrorSuite struct {
+	gitjujutesting.IsolationSuite
+}
+
+var _ = gc.Suite(&FlagErrorSuite{})
+
+func (s *FlagErrorSuite) TestContext(c *gc.C) {
+	for i, test := range []struct {
+		args   []string
+		stderr string
+	}{{
+		args: []string{"--agent-version"},
+		stderr: `
+ERROR flag needs an argument: --agent-version
+-a, --agent-version (= "")
+    the agent version
+Usage: sync [flags]
+See "sync --help" for details.
+`[1:],
+	}, {
+		args: []string{"-a", "latest"},
+		stderr: `
+ERROR invalid value "latest" for flag -a: expected major.minor.patch
+-a, --agent-version (= "")
+    the agent version
+Usage: sync [flags]
+See "sync --help" for details.
+`[1:],
+	}, {
+
+		args: []string{"--agent"},
+		stderr: `
+ERROR flag provided but not defined: --agent
+Usage: sync [flags]
+See "sync --help" for details.
+`[1:],
+	}} {
+		c.Logf("test %d: %v", i, test.args)
+		ctx := cmdtesting.Context(c)
+		code := cmd.Main(&validatedCommand{}, ctx, test.args)
+		c.Check(code, gc.Equals, 2)
+		c.Check(cmdtesting.Stdout(ctx), gc.Equals, "")
+		c.Check(cmdtesting.Stderr(ctx), gc.Equals, test.stderr)
+	}
+}
+
+func (s *FlagErrorSuite) TestErrorUnchanged(c *gc.C) {
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "jujutest"})
+	sc.Register(&validatedCommand{})
+	err := cmdtesting.InitCommand(sc, []string{"sync", "--agent-version"})
+	c.Assert(err, gc.ErrorMatches, `flag needs an argument: --agent-version`)
+}
+
+type FlagEnvSuite struct {
+	gitjujutesting.IsolationSuite
+}
+
+var _ = gc.Suite(&FlagEnvSuite{})
+
+type envCommand struct {
+	cmd.CommandBase
+	dir     string
+	version string
+}
+
+func (c *envCommand) Info() *cmd.Info {
+	return &cmd.Info{Name: "generate", Purpose: "generate metadata"}
+}
+
+func (c *envCommand) SetFlags(f *gnuflag.FlagSet) {
+	f.StringVar(&c.dir, "d", "", "the destination directory")
+	f.StringVar(&c.dir, "metadata-dir", "", "")
+	f.StringVar(&c.version, "agent-version", "", "the agent version")
+	if err := cmd.ValidateFlag(f, "agent-version", validateVersion); err != nil {
+		panic(err)
+	}
+	if err := cmd.FlagFromEnv(f, "d", "JUJU_METADATA_DIR"); err != nil {
+		panic(err)
+	}
+	if err := cmd.FlagFromEnv(f, "agent-version", "JUJU_AGENT_VERSION"); err != nil {
+		panic(err)
+	}
+}
+
+func (c *envCommand) Init(args []string) error {
+	return cmd.CheckEmpty(args)
+}
+
+func (c *envCommand) Run(ctx *cmd.Context) error {
+	return nil
+}
+
+func (s *FlagEnvSuite) TestDefault(c *gc.C) {
+	command := &envCommand{}
+	err := cmdtesting.InitCommand(command, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(command.dir, gc.Equals, "")
+}
+
+func (s *FlagEnvSuite) TestFromEnv(c *gc.C) {
+	s.PatchEnvironment("JUJU_METADATA_DIR", "/tmp/metadata")
+	command := &envCommand{}
+	err := cmdtesting.InitCommand(command, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(command.dir, gc.Equals, "/tmp/metadata")
+}
+
+func (s *FlagEnvSuite) TestCommandLineTakesPrecedence(c *gc.C) {
+	s.PatchEnvironment("JUJU_METADATA_DIR", "/tmp/metadata")
+	for _, args := range [][]string{{"-d", "here"}, {"--metadata-dir", "here"}} {
+		command := &envCommand{}
+		err := cmdtesting.InitCommand(command, args)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(command.dir, gc.Equals, "here")
+	}
+}
+
+func (s *FlagEnvSuite) TestEmptyIgnored(c *gc.C) {
+	s.PatchEnvironment("JUJU_AGENT_VERSION", "")
+	command := &envCommand{}
+	err := cmdtesting.InitCommand(command, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(command.version, gc.Equals, "")
+}
+
+func (s *FlagEnvSuite) TestInvalidFromEnv(c *gc.C) {
+	s.PatchEnvironment("JUJU_AGENT_VERSION", "2.9")
+	command := &envCommand{}
+	err := cmdtesting.InitCommand(command, nil)
+	c.Assert(err, gc.ErrorMatches, `invalid value "2.9" for flag --agent-version from \$JUJU_AGENT_VERSION: expected major.minor.patch`)
+}
+
+func (s *FlagEnvSuite) TestInSuperCommand(c *gc.C) {
+	s.PatchEnvironment("JUJU_METADATA_DIR", "/tmp/metadata")
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "juju-metadata"})
+	command := &envCommand{}
+	sc.Register(command)
+	ctx := cmdtesting.Context(c)
+	code := cmd.Main(sc, ctx, []string{"generate"})
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(command.dir, gc.Equals, "/tmp/metadata")
+}
+
+func (s *FlagEnvSuite) TestGlobalFlagBeforeCommand(c *gc.C) {
+	s.PatchEnvironment("JUJU_MODEL", "from-env")
+	for _, args := range [][]string{
+		{"--model=cli", "generate"},
+		{"generate", "--model=cli"},
+	} {
+		model := ""
+		sc := cmd.NewSuperCommand(cmd.SuperCommandParams{
+			Name: "juju-metadata",
+			GlobalFlags: flagAdderFunc(func(f *gnuflag.FlagSet) {
+				f.StringVar(&model, "model", "", "the model")
+				if err := cmd.FlagFromEnv(f, "model", "JUJU_MODEL"); err != nil {
+					panic(err)
+				}
+			}),
+		})
+		sc.Register(&envCommand{})
+		ctx := cmdtesting.Context(c)
+		code := cmd.Main(sc, ctx, args)
+		c.Check(code, gc.Equals, 0, gc.Commentf("%v", args))
+		c.Check(model, gc.Equals, "cli", gc.Commentf("%v", args))
+	}
+}
+
+func (s *FlagEnvSuite) TestHelp(c *gc.C) {
+	c.Assert(cmdtesting.HelpText(&envCommand{}, "generate"), gc.Equals, `
+Usage: generate [flags]
+
+Summary:
+generate metadata
+
+Flags:
+--agent-version (= "")
+    the agent version [$JUJU_AGENT_VERSION]
+-d, --metadata-dir (= "")
+    the destination directory [$JUJU_METADATA_DIR]
+`[1:])
+}
+
+func (s *FlagEnvSuite) TestUnknownFlag(c *gc.C) {
+	f := cmdtesting.NewFlagSet()
+	err := cmd.FlagFromEnv(f, "missing", "MISSING")
+	c.Assert(err, gc.ErrorMatches, `flag "missing" not found`)
+}
+
+type FlagDeprecationSuite struct {
+	gitjujutesting.IsolationSuite
+
+	ctx *cmd.Context
+}
+
+var _ = gc.Suite(&FlagDeprecationSuite{})
+
+func (s *FlagDeprecationSuite) SetUpTest(c *gc.C) {
+	s.IsolationSuite.SetUpTest(c)
+	s.ctx = cmdtesting.Context(c)
+	loggo.ReplaceDefaultWriter(cmd.NewWarningWriter(s.ctx.Stderr))
+}
+
+type renamedCommand struct {
+	cmd.CommandBase
+	dir      string
+	version  string
+	force    bool
+	required []string
+	envVar   string
+}
+
+func (c *renamedCommand) Info() *cmd.Info {
+	return &cmd.Info{Name: "generate", Purpose: "generate metadata", RequiredFlags: c.required}
+}
+
+func (c *renamedCommand) SetFlags(f *gnuflag.FlagSet) {
+	f.StringVar(&c.dir, "d", "", "the destination directory")
+	f.StringVar(&c.dir, "metadata-dir", "", "")
+	f.StringVar(&c.version, "agent-version", "", "the agent version")
+	f.StringVar(&c.version, "version", "", "the agent version")
+	f.BoolVar(&c.force, "force", false, "overwrite existing metadata")
+	if err := cmd.ValidateFlag(f, "agent-version", validateVersion); err != nil {
+		panic(err)
+	}
+	if c.envVar != "" {
+		if err := cmd.FlagFromEnv(f, "metadata-dir", c.envVar); err != nil {
+			panic(err)
+		}
+	}
+	for _, names := range [][2]string{
+		{"directory", "metadata-dir"},
+		{"version", "agent-version"},
+		{"overwrite", "force"},
+	} {
+		if err := cmd.DeprecateFlag(f, names[0], names[1]); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (c *renamedCommand) Init(args []string) error {
+	return cmd.CheckEmpty(args)
+}
+
+func (c *renamedCommand) Run(ctx *cmd.Context) error {
+	return nil
+}
+
+func (s *FlagDeprecationSuite) TestDeprecatedName(c *gc.C) {
+	command := &renamedCommand{}
+	code := cmd.Main(command, s.ctx, []string{"--directory", "here", "--version", "2.9.1", "--overwrite"})
+	c.Assert(code, gc.Equals, 0)
+	c.Check(command.dir, gc.Equals, "here")
+	c.Check(command.version, gc.Equals, "2.9.1")
+	c.Check(command.force, jc.IsTrue)
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, `
+WARNING flag --directory is deprecated, please use --metadata-dir
+WARNING flag --overwrite is deprecated, please use --force
+WARNING flag --version is deprecated, please use --agent-version
+`[1:])
+}
+
+func (s *FlagDeprecationSuite) TestWarnedOnce(c *gc.C) {
+	command := &renamedCommand{}
+	code := cmd.Main(command, s.ctx, []string{"--directory", "here", "--directory", "there"})
+	c.Assert(code, gc.Equals, 0)
+	c.Check(command.dir, gc.Equals, "there")
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, "WARNING flag --directory is deprecated, please use --metadata-dir\n")
+}
+
+func (s *FlagDeprecationSuite) TestNewName(c *gc.C) {
+	command := &renamedCommand{}
+	code := cmd.Main(command, s.ctx, []string{"-d", "here", "--force"})
+	c.Assert(code, gc.Equals, 0)
+	c.Check(command.dir, gc.Equals, "here")
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, "")
+}
+
+func (s *FlagDeprecationSuite) TestRequiredFlag(c *gc.C) {
+	command := &renamedCommand{required: []string{"metadata-dir", "agent-version"}}
+	code := cmd.Main(command, s.ctx, []string{"--directory", "here", "--version", "2.9.1"})
+	c.Assert(code, gc.Equals, 0)
+	c.Check(command.dir, gc.Equals, "here")
+	c.Check(command.version, gc.Equals, "2.9.1")
+}
+
+func (s *FlagDeprecationSuite) TestFromEnv(c *gc.C) {
+	s.PatchEnvironment("JUJU_METADATA_DIR", "/tmp/metadata")
+	command := &renamedCommand{envVar: "JUJU_METADATA_DIR"}
+	code := cmd.Main(command, s.ctx, nil)
+	c.Assert(code, gc.Equals, 0)
+	c.Check(command.dir, gc.Equals, "/tmp/metadata")
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, "")
+
+	command = &renamedCommand{envVar: "JUJU_METADATA_DIR"}
+	code = cmd.Main(command, s.ctx, []string{"--directory", "here"})
+	c.Assert(code, gc.Equals, 0)
+	c.Check(command.dir, gc.Equals, "here")
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, "WARNING flag --directory is deprecated, please use --metadata-dir\n")
+}
+
+func (s *FlagDeprecationSuite) TestInSuperCommand(c *gc.C) {
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "juju-metadata", Log: &cmd.Log{}})
+	command := &renamedCommand{}
+	sc.Register(command)
+	code := cmd.Main(sc, s.ctx, []string{"generate", "--directory", "here"})
+	c.Assert(code, gc.Equals, 0)
+	c.Check(command.dir, gc.Equals, "here")
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, "WARNING flag --directory is deprecated, please use --metadata-dir\n")
+}
+
+func (s *FlagDeprecationSuite) TestInvalidValue(c *gc.C) {
+	command := &renamedCommand{}
+	code := cmd.Main(command, s.ctx, []string{"--version", "2.9"})
+	c.Assert(code, gc.Equals, 2)
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, `
+ERROR invalid value "2.9" for flag --version: expected major.minor.patch
+--agent-version (= "")
+    the agent version
+Usage: generate [flags]
+See "generate --help" for details.
+`[1:])
+}
+
+func (s *FlagDeprecationSuite) TestHidden(c *gc.C) {
+	c.Assert(cmdtesting.HelpText(&renamedCommand{}, "generate"), gc.Equals, `
+Usage: generate [flags]
+
+Summary:
+generate metadata
+
+Flags:
+--agent-version (= "")
+    the agent version
+-d, --metadata-dir (= "")
+    the destination directory
+--force  (= false)
+    overwrite existing metadata
+`[1:])
+}
+
+func (s *FlagDeprecationSuite) TestUnknownFlag(c *gc.C) {
+	f := cmdtesting.NewFlagSet()
+	err := cmd.DeprecateFlag(f, "old", "missing")
+	c.Assert(err, gc.ErrorMatches, `flag "missing" not found`)
+}
+
+type PosixFlagsSuite struct {
+	gitjujutesting.IsolationSuite
+}
+
+var _ = gc.Suite(&PosixFlagsSuite{})
+
+type posixCommand struct {
+	cmd.CommandBase
+	posix  bool
+	all    bool
+	force  bool
+	dryRun bool
+	output string
+	args   []string
+}
+
+func (c *posixCommand) Info() *cmd.Info {
+	return &cmd.Info{Name: "list", PosixFlags: c.posix}
+}
+
+func (c *posixCommand) SetFlags(f *gnuflag.FlagSet) {
+	f.BoolVar(&c.all, "a", false, "")
+	f.BoolVar(&c.all, "all", false, "")
+	f.BoolVar(&c.force, "f", false, "")
+	f.BoolVar(&c.dryRun, "n", false, "")
+	f.StringVar(&c.output, "o", "", "")
+	f.StringVar(&c.output, "output", "", "")
+}
+
+func (c *posixCommand) Init(args []string) error {
+	c.args = args
+	return nil
+}
+
+func (c *posixCommand) Run(ctx *cmd.Context) error {
+	return nil
+}
+
+func (s *PosixFlagsSuite) TestParse(c *gc.C) {
+	for _, test := range []struct {
+		args   []string
+		all    bool
+		force  bool
+		dryRun bool
+		output string
+		rest   []string
+	}{
+		{args: []string{"-af"}, all: true, force: true},
+		{args: []string{"-afn", "x"}, all: true, force: true, dryRun: true, rest: []string{"x"}},
+		{args: []string{"-afofile"}, all: true, force: true, output: "file"},
+		{args: []string{"-ao", "file"}, all: true, output: "file"},
+		{args: []string{"-o", "-af"}, output: "-af"},
+		{args: []string{"--output", "-af"}, output: "-af"},
+		{args: []string{"--output=-af", "-n"}, dryRun: true, output: "-af"},
+		{args: []string{"x", "-af"}, all: true, force: true, rest: []string{"x"}},
+		{args: []string{"-n", "--", "-af"}, dryRun: true, rest: []string{"-af"}},
+		{args: []string{"-", "-n"}, dryRun: true, rest: []string{"-"}},
+	} {
+		command := &posixCommand{posix: true}
+		err := cmdtesting.InitCommand(command, test.args)
+		c.Check(err, jc.ErrorIsNil, gc.Commentf("%q", test.args))
+		c.Check(command.all, gc.Equals, test.all, gc.Commentf("%q", test.args))
+		c.Check(command.force, gc.Equals, test.force, gc.Commentf("%q", test.args))
+		c.Check(command.dryRun, gc.Equals, test.dryRun, gc.Commentf("%q", test.args))
+		c.Check(command.output, gc.Equals, test.output, gc.Commentf("%q", test.args))
+		c.Check(command.args, gc.DeepEquals, test.rest, gc.Commentf("%q", test.args))
+	}
+}
+
+func (s *PosixFlagsSuite) TestErrors(c *gc.C) {
+	for _, test := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-all"}, "flag provided but not defined: -l"},
+		{[]string{"-ao"}, "flag needs an argument: -o"},
+	} {
+		err := cmdtesting.InitCommand(&posixCommand{posix: true}, test.args)
+		c.Check(err, gc.ErrorMatches, test.err)
+	}
+}
+
+func (s *PosixFlagsSuite) TestNotOptedIn(c *gc.C) {
+	// Without PosixFlags, only the first of the flags combined in the
+	// final argument is seen.
+	command := &posixCommand{}
+	err := cmdtesting.InitCommand(command, []string{"-af"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(command.all, jc.IsTrue)
+	c.Assert(command.force, jc.IsFalse)
+}
+
+func (s *PosixFlagsSuite) TestMain(c *gc.C) {
+	command := &posixCommand{posix: true}
+	code := cmd.Main(command, cmdtesting.Context(c), []string{"-afn"})
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(command.dryRun, jc.IsTrue)
+}
+
+func (s *PosixFlagsSuite) TestSuperCommand(c *gc.C) {
+	log := &cmd.Log{}
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{
+		Name:       "juju-metadata",
+		Log:        log,
+		PosixFlags: true,
+	})
+	command := &posixCommand{}
+	sc.Register(command)
+	code := cmd.Main(sc, cmdtesting.Context(c), []string{"list", "-afq"})
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(command.all, jc.IsTrue)
+	c.Assert(command.force, jc.IsTrue)
+	c.Assert(log.Quiet, jc.IsTrue)
+}
@@ -52,12 +52,12 @@ func (c *helpCommand) addTopic(name, short string, long func() string, aliases .
 	if _, found := c.topics[name]; found {
 		panic(fmt.Sprintf("help topic already added: %s", name))
 	}
-	c.topics[name] = topic{short, long, false}
+	c.topics[name] = topic{short: short, long: long}
 	for _, alias := range aliases {
 		if _, found := c.topics[alias]; found {
 			panic(fmt.Sprintf("help topic already added: %s", alias))
 		}
-		c.topics[alias] = topic{short, long, true}
+		c.topics[alias] = topic{short: short, long: long, alias: true}
 	}
 }
 
@@ -168,9 +168,9 @@ func (c *helpCommand) getCommandHelp(super *SuperCommand, command Command, alias
 			info.Name = fmt.Sprintf("%s %s", super.Name, alias)
 		}
 	}
-	if super.usagePrefix != "" {
+	if prefix := super.prefix(); prefix != "" {
 		logger.Tracef("adding super prefix")
-		info.Name = fmt.Sprintf("%s %s", super.usagePrefix, info.Name)
+		info.Name = fmt.Sprintf("%s %s", prefix, info.Name)
 	}
 
 	flagsAKA := FlagAlias(command, "")
@@ -194,6 +194,9 @@ func (c *helpCommand) getCommandHelp(super *SuperCommand, command Command, alias
 		flagsAKA = "flag"
 	}
 	f := gnuflag.NewFlagSetWithFlagKnownAs(info.Name, gnuflag.ContinueOnError, flagsAKA)
+	var explain, allowRoot bool
+	addExplainFlag(f, command, &explain)
+	addAllowRootFlag(f, info, &allowRoot)
 	command.SetFlags(f)
 
 	superf := gnuflag.NewFlagSetWithFlagKnownAs(super.Info().Name, gnuflag.ContinueOnError, flagsAKA)
@@ -227,6 +230,9 @@ func (c *helpCommand) Run(ctx *Context) error {
 
 	// Look to see if the topic is a registered topic.
 	topic, ok := c.topics[c.topic]
+	if ok && topic.write != nil {
+		return topic.write(ctx)
+	}
 	if ok {
 		fmt.Fprintf(ctx.Stdout, "%s\n", strings.TrimSpace(topic.long()))
 		return nil
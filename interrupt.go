@@ -0,0 +1,77 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// interruptSignals are the signals that interrupt a running command.
+var interruptSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+var (
+	notifySignals = signal.Notify
+	stopSignals   = signal.Stop
+	osExit        = os.Exit
+)
+
+// Interrupted returns a channel that is closed when the running command is
+// interrupted by SIGINT or SIGTERM. When that happens, the context.Context
+// embedded in ctx is also cancelled, so that operations using ctx are
+// abandoned. Commands doing long-running work should watch for either, and
+// stop cleanly, for example by not committing partially written output;
+// if a second signal is received before Run returns, the process exits
+// immediately.
+//
+// Signals are only handled while Main is running the command: outside of
+// Main, the returned channel is never closed.
+func (ctx *Context) Interrupted() <-chan struct{} {
+	return ctx.interrupted
+}
+
+// handleInterrupts starts handling interrupt signals for the command run
+// with ctx, as described for Interrupted, until the returned function is
+// called.
+func (ctx *Context) handleInterrupts() (stop func()) {
+	parent := ctx.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	cancelCtx, cancel := context.WithCancel(parent)
+	interrupted := make(chan struct{})
+	ctx.Context, ctx.interrupted = cancelCtx, interrupted
+
+	signals := make(chan os.Signal, 2)
+	notifySignals(signals, interruptSignals...)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		select {
+		case sig := <-signals:
+			logger.Debugf("received %v, cancelling command", sig)
+			fmt.Fprintln(ctx.Stderr, "Interrupted; stopping. Interrupt again to exit immediately.")
+			close(interrupted)
+			cancel()
+		case <-done:
+			return
+		}
+		select {
+		case <-signals:
+			osExit(1)
+		case <-done:
+		}
+	}()
+	return func() {
+		stopSignals(signals)
+		close(done)
+		<-finished
+		cancel()
+		ctx.Context, ctx.interrupted = parent, nil
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/juju/testing"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type InterruptSuite struct {
+	testing.LoggingCleanupSuite
+	ctx     *cmd.Context
+	signals chan chan<- os.Signal
+	exited  chan int
+}
+
+var _ = gc.Suite(&InterruptSuite{})
+
+func (s *InterruptSuite) SetUpTest(c *gc.C) {
+	s.LoggingCleanupSuite.SetUpTest(c)
+	s.ctx = cmdtesting.Context(c)
+	s.signals = make(chan chan<- os.Signal, 1)
+	s.exited = make(chan int, 1)
+	s.PatchValue(cmd.NotifySignals, func(ch chan<- os.Signal, sig ...os.Signal) {
+		c.Check(sig, gc.DeepEquals, []os.Signal{os.Interrupt, syscall.SIGTERM})
+		s.signals <- ch
+	})
+	s.PatchValue(cmd.StopSignals, func(chan<- os.Signal) {})
+	s.PatchValue(cmd.OsExit, func(code int) {
+		s.exited <- code
+	})
+}
+
+// interruptibleCommand sends the given signals to itself, then waits to
+// be interrupted.
+type interruptibleCommand struct {
+	cmd.CommandBase
+	suite        *InterruptSuite
+	signals      []os.Signal
+	interrupted  bool
+	contextError error
+}
+
+func (c *interruptibleCommand) Info() *cmd.Info {
+	return &cmd.Info{Name: "fetch"}
+}
+
+func (c *interruptibleCommand) Run(ctx *cmd.Context) error {
+	ch := <-c.suite.signals
+	for _, sig := range c.signals {
+		ch <- sig
+	}
+	select {
+	case <-ctx.Interrupted():
+		c.interrupted = true
+	case <-time.After(testing.LongWait):
+		return nil
+	}
+	<-ctx.Done()
+	c.contextError = ctx.Err()
+	return ctx.Err()
+}
+
+func (s *InterruptSuite) TestInterrupt(c *gc.C) {
+	command := &interruptibleCommand{suite: s, signals: []os.Signal{os.Interrupt}}
+	code := cmd.Main(command, s.ctx, nil)
+	c.Check(code, gc.Equals, 1)
+	c.Check(command.interrupted, gc.Equals, true)
+	c.Check(command.contextError, gc.Equals, context.Canceled)
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, `
+Interrupted; stopping. Interrupt again to exit immediately.
+ERROR context canceled
+`[1:])
+	select {
+	case code := <-s.exited:
+		c.Fatalf("unexpected exit with code %d", code)
+	default:
+	}
+
+	// The context is restored once the command has finished.
+	c.Check(s.ctx.Err(), gc.IsNil)
+	c.Check(s.ctx.Interrupted(), gc.IsNil)
+}
+
+func (s *InterruptSuite) TestSecondSignalExits(c *gc.C) {
+	command := &interruptibleCommand{suite: s, signals: []os.Signal{syscall.SIGTERM, os.Interrupt}}
+	code := cmd.Main(command, s.ctx, nil)
+	c.Check(code, gc.Equals, 1)
+	select {
+	case code := <-s.exited:
+		c.Check(code, gc.Equals, 1)
+	case <-time.After(testing.LongWait):
+		c.Fatalf("process did not exit")
+	}
+}
+
+func (s *InterruptSuite) TestNotInterrupted(c *gc.C) {
+	code := cmd.Main(&TestCommand{Name: "verb"}, s.ctx, []string{"--option", "success!"})
+	c.Check(code, gc.Equals, 0)
+	c.Check(s.ctx.Interrupted(), gc.IsNil)
+}
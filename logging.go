@@ -7,8 +7,11 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/juju/ansiterm"
+	"github.com/juju/errors"
 	"github.com/juju/gnuflag"
 	"github.com/juju/loggo"
 	"github.com/juju/loggo/loggocolor"
@@ -22,13 +25,34 @@ type Log struct {
 	DefaultConfig string
 	Path          string
 	Verbose       bool
-	Quiet         bool
-	Debug         bool
-	ShowLog       bool
-	Config        string
+	// Verbosity is the number of times the verbose flag was given; see
+	// the Verbosity constants for the meaning of each level. Setting
+	// Verbose without Verbosity is equivalent to a Verbosity of 1.
+	Verbosity Verbosity
+	Quiet     bool
+	Debug     bool
+	ShowLog   bool
+	Config    string
+	// FileMode, if set, overrides the permissions of the files written
+	// by the command; see Context.FileMode.
+	FileMode os.FileMode
+	// NoColor disables colored output; see Context.ColorEnabled.
+	NoColor bool
 
 	// NewWriter creates a new logging writer for a specified target.
 	NewWriter func(target io.Writer) loggo.Writer
+
+	// EventLogDir, if set, is the directory in which a pair of log files
+	// is written for each run: a plain text log, and a JSON log holding one
+	// object per line for each log message and for each event recorded with
+	// Context.Event. Messages at INFO level and above are always written
+	// to these logs, whatever the console verbosity.
+	EventLogDir string
+
+	// EventLogMaxRuns is the number of runs for which logs are kept in
+	// EventLogDir; the logs of older runs are removed. If it is zero, the
+	// logs of the last 10 runs are kept.
+	EventLogMaxRuns int
 }
 
 // GetLogWriter returns a logging writer for the specified target.
@@ -42,36 +66,53 @@ func (l *Log) GetLogWriter(target io.Writer) loggo.Writer {
 // AddFlags adds appropriate flags to f.
 func (l *Log) AddFlags(f *gnuflag.FlagSet) {
 	f.StringVar(&l.Path, "log-file", "", "path to write log to")
-	f.BoolVar(&l.Verbose, "v", false, "Show more verbose output")
-	f.BoolVar(&l.Verbose, "verbose", false, "Show more verbose output")
+	verbose := &verbosityValue{verbose: &l.Verbose, verbosity: &l.Verbosity}
+	f.Var(verbose, "v", "Show more verbose output; repeat (-vv, -vvv) for more detail")
+	f.Var(verbose, "verbose", "")
 	f.BoolVar(&l.Quiet, "q", false, "Show no informational output")
 	f.BoolVar(&l.Quiet, "quiet", false, "Show no informational output")
 	f.BoolVar(&l.Debug, "debug", false, "Equivalent to --show-log --logging-config=<root>=DEBUG")
 	f.StringVar(&l.Config, "logging-config", l.DefaultConfig, "Specify log levels for modules")
 	f.BoolVar(&l.ShowLog, "show-log", false, "If set, write the log file to stderr")
+	f.Var(fileModeValue{&l.FileMode}, "file-mode", "Permissions of the files written, in octal; private files are never made accessible to others")
+	f.BoolVar(&l.NoColor, "no-color", false, "Disable colored output")
 }
 
 // Start starts logging using the given Context.
 func (log *Log) Start(ctx *Context) error {
-	if log.Verbose && log.Quiet {
+	verbosity := log.Verbosity
+	if log.Verbose && verbosity == VerbosityNormal {
+		verbosity = VerbosityProgress
+	}
+	if verbosity > VerbosityNormal && log.Quiet {
 		return fmt.Errorf(`"verbose" and "quiet" flags clash, please use one or the other, not both`)
 	}
 	ctx.quiet = log.Quiet
-	ctx.verbose = log.Verbose
+	ctx.verbose = verbosity > VerbosityNormal
+	ctx.verbosity = verbosity
+	ctx.fileMode = log.FileMode
+	ctx.noColor = log.NoColor
 	if log.Path != "" {
 		path := ctx.AbsPath(log.Path)
-		target, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		target, err := createFile(path, ctx.FileMode(DefaultFileMode))
 		if err != nil {
 			return err
 		}
-		writer := log.GetLogWriter(target)
+		writer := newRedactingWriter(log.GetLogWriter(target))
 		err = loggo.RegisterWriter("logfile", writer)
 		if err != nil {
 			return err
 		}
 	}
 	level := loggo.WARNING
-	if log.ShowLog {
+	switch {
+	case verbosity >= VerbosityWire:
+		log.ShowLog = true
+		level = loggo.TRACE
+	case verbosity >= VerbosityDetail:
+		log.ShowLog = true
+		level = loggo.INFO
+	case log.ShowLog:
 		level = loggo.INFO
 	}
 	if log.Debug {
@@ -81,11 +122,33 @@ func (log *Log) Start(ctx *Context) error {
 		// to the log file.
 		ctx.quiet = true
 		ctx.verbose = false
+		ctx.verbosity = VerbosityNormal
+	}
+
+	// When writing an event log, the root logger must emit at least INFO
+	// messages, but the console should still only show the messages it
+	// would have otherwise.
+	rootLevel := level
+	if log.EventLogDir != "" {
+		eventLog, err := openEventLog(ctx.AbsPath(log.EventLogDir), log.EventLogMaxRuns, time.Now(), ctx.FileMode(DefaultFileMode))
+		if err != nil {
+			return errors.Annotate(err, "opening event log")
+		}
+		if err := loggo.RegisterWriter("eventlog", newRedactingWriter(eventLog)); err != nil {
+			return err
+		}
+		ctx.eventLog = eventLog
+		if rootLevel > loggo.INFO {
+			rootLevel = loggo.INFO
+		}
 	}
 
 	if log.ShowLog {
 		// We replace the default writer to use ctx.Stderr rather than os.Stderr.
-		writer := log.GetLogWriter(ctx.Stderr)
+		writer := newRedactingWriter(log.GetLogWriter(ctx.Stderr))
+		if rootLevel != level {
+			writer = loggo.NewMinimumLevelWriter(writer, level)
+		}
 		_, err := loggo.ReplaceDefaultWriter(writer)
 		if err != nil {
 			return err
@@ -94,7 +157,7 @@ func (log *Log) Start(ctx *Context) error {
 		_, _ = loggo.RemoveWriter("default")
 		// Create a simple writer that doesn't show filenames, or timestamps,
 		// and only shows warning or above.
-		writer := NewWarningWriter(ctx.Stderr)
+		writer := newRedactingWriter(newWarningWriter(ctx.Stderr, ctx.ColorEnabled(ctx.Stderr)))
 		err := loggo.RegisterWriter("warning", writer)
 		if err != nil {
 			return err
@@ -102,12 +165,66 @@ func (log *Log) Start(ctx *Context) error {
 	}
 	// Set the level on the root logger.
 	root := loggo.GetLogger("")
-	root.SetLogLevel(level)
+	root.SetLogLevel(rootLevel)
 	// Override the logging config with specified logging config.
 	loggo.ConfigureLoggers(log.Config)
 	return nil
 }
 
+// Verbosity describes how much informational output a command produces.
+type Verbosity int
+
+const (
+	// VerbosityNormal is the default verbosity.
+	VerbosityNormal Verbosity = iota
+
+	// VerbosityProgress (-v) additionally shows progress messages, written
+	// with Context.Verbosef.
+	VerbosityProgress
+
+	// VerbosityDetail (-vv) additionally shows per-item detail, and log
+	// messages at INFO level and above, as if --show-log was given.
+	VerbosityDetail
+
+	// VerbosityWire (-vvv) additionally shows wire-level detail, and log
+	// messages at all levels.
+	VerbosityWire
+)
+
+// verbosityValue implements gnuflag.Value for the verbose flags. It
+// behaves as a boolean flag, but counts the number of times it is set, so
+// that -vv and -vvv increase the verbosity further.
+type verbosityValue struct {
+	verbose   *bool
+	verbosity *Verbosity
+}
+
+// IsBoolFlag implements the optional gnuflag boolFlag interface.
+func (v *verbosityValue) IsBoolFlag() bool {
+	return true
+}
+
+// Set implements gnuflag.Value.
+func (v *verbosityValue) Set(s string) error {
+	on, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	if !on {
+		*v.verbose = false
+		*v.verbosity = VerbosityNormal
+		return nil
+	}
+	*v.verbose = true
+	*v.verbosity++
+	return nil
+}
+
+// String implements gnuflag.Value.
+func (v *verbosityValue) String() string {
+	return strconv.FormatBool(*v.verbose)
+}
+
 // NewCommandLogWriter creates a loggo writer for registration
 // by the callers of a command. This way the logged output can also
 // be displayed otherwise, e.g. on the screen.
@@ -140,7 +257,16 @@ type warningWriter struct {
 // NewWarningWriter will write out colored severity levels if the writer is
 // outputting to a terminal.
 func NewWarningWriter(writer io.Writer) loggo.Writer {
+	return newWarningWriter(writer, true)
+}
+
+// newWarningWriter returns a warning writer that colors severity levels
+// only if color is true and writer is a terminal.
+func newWarningWriter(writer io.Writer, color bool) loggo.Writer {
 	w := &warningWriter{ansiterm.NewWriter(writer)}
+	if !color {
+		w.writer.SetColorCapable(false)
+	}
 	return loggo.NewMinimumLevelWriter(w, loggo.WARNING)
 }
 
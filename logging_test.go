@@ -4,6 +4,7 @@
 package cmd_test
 
 import (
+	"io"
 	"io/ioutil"
 	"path/filepath"
 
@@ -29,7 +30,7 @@ func newLogWithFlags(c *gc.C, defaultConfig string, flags ...string) *cmd.Log {
 	}
 	flagSet := cmdtesting.NewFlagSet()
 	log.AddFlags(flagSet)
-	err := flagSet.Parse(false, flags)
+	err := cmd.ParseFlags(flagSet, false, false, flags)
 	c.Assert(err, gc.IsNil)
 	return log
 }
@@ -53,6 +54,57 @@ func (s *LogSuite) TestFlags(c *gc.C) {
 	c.Assert(log.Config, gc.Equals, "juju.cmd=INFO;juju.worker.deployer=DEBUG")
 }
 
+func (s *LogSuite) TestRepeatedVerboseFlags(c *gc.C) {
+	for i, test := range []struct {
+		flags     []string
+		verbosity cmd.Verbosity
+	}{
+		{nil, cmd.VerbosityNormal},
+		{[]string{"-v"}, cmd.VerbosityProgress},
+		{[]string{"--verbose"}, cmd.VerbosityProgress},
+		{[]string{"-vv"}, cmd.VerbosityDetail},
+		{[]string{"-v", "--verbose"}, cmd.VerbosityDetail},
+		{[]string{"-vvv"}, cmd.VerbosityWire},
+		{[]string{"-vv", "--verbose=false"}, cmd.VerbosityNormal},
+	} {
+		c.Logf("test %d: %v", i, test.flags)
+		for _, args := range [][]string{test.flags, append(test.flags, "arg")} {
+			log := newLogWithFlags(c, "", args...)
+			c.Check(log.Verbosity, gc.Equals, test.verbosity, gc.Commentf("%q", args))
+			c.Check(log.Verbose, gc.Equals, test.verbosity > cmd.VerbosityNormal)
+		}
+	}
+}
+
+func (s *LogSuite) TestRepeatedVerboseFlagsLast(c *gc.C) {
+	for _, test := range []struct {
+		arg       string
+		verbosity cmd.Verbosity
+	}{
+		{"-vv", cmd.VerbosityDetail},
+		{"-vvv", cmd.VerbosityWire},
+	} {
+		log := &cmd.Log{}
+		sc := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "top", Log: log})
+		sc.Register(&TestCommand{Name: "gen"})
+		code := cmd.Main(sc, cmdtesting.Context(c), []string{"gen", test.arg})
+		c.Check(code, gc.Equals, 0)
+		c.Check(log.Verbosity, gc.Equals, test.verbosity, gc.Commentf("%s", test.arg))
+	}
+}
+
+func (s *LogSuite) TestVerboseFlagsAsValue(c *gc.C) {
+	log := &cmd.Log{}
+	var output string
+	f := cmdtesting.NewFlagSet()
+	log.AddFlags(f)
+	f.StringVar(&output, "o", "", "")
+	err := cmd.ParseFlags(f, false, false, []string{"-o", "-vv"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(output, gc.Equals, "-vv")
+	c.Assert(log.Verbosity, gc.Equals, cmd.VerbosityNormal)
+}
+
 func (s *LogSuite) TestLogConfigFromDefault(c *gc.C) {
 	config := "juju.cmd=INFO;juju.worker.deployer=DEBUG"
 	log := newLogWithFlags(c, config)
@@ -177,6 +229,46 @@ func (s *LogSuite) TestOutputVerbose(c *gc.C) {
 	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "Writing info output\nWriting verbose output\n")
 }
 
+func (s *LogSuite) TestOutputVerbosityLevels(c *gc.C) {
+	l := &cmd.Log{Verbosity: cmd.VerbosityDetail, NewWriter: func(target io.Writer) loggo.Writer {
+		return cmd.NewWarningWriter(target)
+	}}
+	ctx := cmdtesting.Context(c)
+	err := l.Start(ctx)
+	c.Assert(err, gc.IsNil)
+	c.Assert(ctx.Verbosity(), gc.Equals, cmd.VerbosityDetail)
+
+	ctx.Verbosef("Writing verbose output")
+	ctx.VerbosefAt(cmd.VerbosityDetail, "Writing detail output")
+	ctx.VerbosefAt(cmd.VerbosityWire, "Writing wire output")
+
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "Writing verbose output\nWriting detail output\n")
+}
+
+func (s *LogSuite) TestOutputDetailShowsLog(c *gc.C) {
+	l := &cmd.Log{Verbosity: cmd.VerbosityDetail}
+	ctx := cmdtesting.Context(c)
+	err := l.Start(ctx)
+	c.Assert(err, gc.IsNil)
+
+	logger.Infof("Writing info log")
+	logger.Debugf("Writing debug log")
+
+	c.Assert(cmdtesting.Stderr(ctx), gc.Matches, `^.*INFO .* Writing info log\n$`)
+}
+
+func (s *LogSuite) TestOutputWireShowsAllLogs(c *gc.C) {
+	l := &cmd.Log{Verbosity: cmd.VerbosityWire}
+	ctx := cmdtesting.Context(c)
+	err := l.Start(ctx)
+	c.Assert(err, gc.IsNil)
+
+	logger.Tracef("Writing trace log")
+	ctx.VerbosefAt(cmd.VerbosityWire, "Writing wire output")
+
+	c.Assert(cmdtesting.Stderr(ctx), gc.Matches, `^.*TRACE .* Writing trace log\nWriting wire output\n$`)
+}
+
 func (s *LogSuite) TestOutputQuiet(c *gc.C) {
 	l := &cmd.Log{Quiet: true}
 	ctx := cmdtesting.Context(c)
@@ -0,0 +1,141 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// ManPageSection is the manual section in which command man pages are
+// written.
+const ManPageSection = "1"
+
+// GenerateManPages writes a roff man page for super, and for each of its
+// registered subcommands, into dir. The page for a subcommand is named
+// after the command path joined with dashes, e.g. juju-metadata-generate.1
+// for the generate subcommand of juju-metadata. Aliases are listed on the
+// page of the command they refer to, rather than given pages of their own.
+// The pages are written with ctx.WriteFile, and dir is relative to ctx.Dir.
+func GenerateManPages(ctx *Context, super *SuperCommand, dir string) error {
+	if info, err := os.Stat(ctx.AbsPath(dir)); err != nil {
+		return errors.Trace(err)
+	} else if !info.IsDir() {
+		return errors.Errorf("%q is not a directory", dir)
+	}
+	return walkCommands(super, []string{super.Name}, func(page *commandPage) error {
+		return writeManPage(ctx, dir, page)
+	})
+}
+
+func writeManPage(ctx *Context, dir string, page *commandPage) error {
+	info, path := page.info, page.path
+	name := strings.Join(path, "-")
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, ".TH %s %s\n", roffQuote(strings.ToUpper(name)), ManPageSection)
+
+	buf.WriteString(".SH NAME\n")
+	if info.Purpose != "" {
+		fmt.Fprintf(&buf, "%s \\- %s\n", roffEscape(name), roffEscape(strings.TrimSpace(info.Purpose)))
+	} else {
+		fmt.Fprintf(&buf, "%s\n", roffEscape(name))
+	}
+
+	buf.WriteString(".SH SYNOPSIS\n")
+	fmt.Fprintf(&buf, "\\fB%s\\fR", roffEscape(info.Name))
+	f := commandFlagSet(page.super, info.Name, page.command)
+	flags := groupFlags(f)
+	if len(flags) > 0 {
+		fmt.Fprintf(&buf, " [%ss]", roffEscape(f.FlagKnownAs))
+	}
+	if args := strings.TrimSpace(info.argsUsage()); args != "" {
+		fmt.Fprintf(&buf, " %s", roffEscape(args))
+	}
+	buf.WriteString("\n")
+
+	if doc := strings.TrimSpace(info.Doc); doc != "" {
+		buf.WriteString(".SH DESCRIPTION\n.nf\n")
+		buf.WriteString(roffLines(doc))
+		buf.WriteString(".fi\n")
+	}
+
+	if len(flags) > 0 {
+		fmt.Fprintf(&buf, ".SH %sS\n", strings.ToUpper(f.FlagKnownAs))
+		for _, group := range flags {
+			names := make([]string, len(group))
+			for i, flag := range group {
+				names[i] = "\\fB" + roffEscape(flagWithDashes(flag.Name)) + "\\fR"
+			}
+			fmt.Fprintf(&buf, ".TP\n%s (= %s)\n", strings.Join(names, ", "), roffEscape(group[0].DefValue))
+			if usage := strings.TrimSpace(group[0].Usage); usage != "" {
+				buf.WriteString(roffLines(usage))
+			}
+		}
+	}
+
+	if examples := strings.TrimSpace(info.Examples); examples != "" {
+		buf.WriteString(".SH EXAMPLES\n.nf\n")
+		buf.WriteString(roffLines(examples))
+		buf.WriteString(".fi\n")
+	}
+
+	if len(page.aliases) > 0 {
+		buf.WriteString(".SH ALIASES\n")
+		buf.WriteString(roffEscape(strings.Join(page.aliases, ", ")) + "\n")
+	}
+
+	if len(info.SeeAlso) > 0 {
+		seeAlso := make([]string, len(info.SeeAlso))
+		for i, other := range info.SeeAlso {
+			page := strings.Join(append(append([]string(nil), path[:len(path)-1]...), other), "-")
+			seeAlso[i] = fmt.Sprintf("\\fB%s\\fR(%s)", roffEscape(page), ManPageSection)
+		}
+		buf.WriteString(".SH SEE ALSO\n")
+		buf.WriteString(strings.Join(seeAlso, ", ") + "\n")
+	}
+
+	target := filepath.Join(dir, name+"."+ManPageSection)
+	if err := ctx.WriteFile(target, buf.Bytes(), DefaultFileMode); err != nil {
+		return errors.Annotatef(err, "writing man page for %q", info.Name)
+	}
+	return nil
+}
+
+func flagWithDashes(name string) string {
+	if len(name) == 1 {
+		return "-" + name
+	}
+	return "--" + name
+}
+
+// roffEscape escapes text so that it is rendered literally by roff.
+func roffEscape(text string) string {
+	text = strings.Replace(text, `\`, `\e`, -1)
+	return strings.Replace(text, "-", `\-`, -1)
+}
+
+// roffQuote escapes text, and quotes it for use as a macro argument.
+func roffQuote(text string) string {
+	return `"` + strings.Replace(roffEscape(text), `"`, `\(dq`, -1) + `"`
+}
+
+// roffLines escapes each line of text, ensuring that lines starting with
+// a period or apostrophe are not taken as requests, and that empty lines
+// are kept.
+func roffLines(text string) string {
+	var buf bytes.Buffer
+	for _, line := range strings.Split(text, "\n") {
+		line = roffEscape(line)
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			line = `\&` + line
+		}
+		buf.WriteString(line + "\n")
+	}
+	return buf.String()
+}
@@ -0,0 +1,194 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+
+	"github.com/juju/gnuflag"
+	"github.com/juju/loggo"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type ManSuite struct {
+	testing.LoggingCleanupSuite
+	ctx *cmd.Context
+}
+
+var _ = gc.Suite(&ManSuite{})
+
+func (s *ManSuite) SetUpTest(c *gc.C) {
+	s.LoggingCleanupSuite.SetUpTest(c)
+	s.ctx = cmdtesting.Context(c)
+	loggo.ReplaceDefaultWriter(cmd.NewWarningWriter(s.ctx.Stderr))
+}
+
+type manTestCommand struct {
+	cmd.CommandBase
+	stream string
+}
+
+func (c *manTestCommand) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:     "generate",
+		Args:     "<dir>",
+		Purpose:  "Generate tools metadata.",
+		Doc:      "Generate simplestreams metadata for the tools in <dir>.\n.hidden is not a request",
+		Examples: "    juju-metadata generate -s devel ./tools",
+		Aliases:  []string{"gen"},
+		SeeAlso:  []string{"validate"},
+	}
+}
+
+func (c *manTestCommand) SetFlags(f *gnuflag.FlagSet) {
+	f.StringVar(&c.stream, "s", "released", "The stream to generate")
+	f.StringVar(&c.stream, "stream", "released", "")
+}
+
+func (c *manTestCommand) Run(ctx *cmd.Context) error {
+	return nil
+}
+
+func newDocTreeCommand() *cmd.SuperCommand {
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{
+		Name:    "juju-metadata",
+		Purpose: "Manage simplestreams metadata.",
+	})
+	sc.Register(&manTestCommand{})
+	sub := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "model", UsagePrefix: "juju-metadata", Purpose: "Model commands."})
+	sub.Register(&manTestCommand{})
+	sc.Register(sub)
+	return sc
+}
+
+func listDir(c *gc.C, dir string) []string {
+	entries, err := ioutil.ReadDir(dir)
+	c.Assert(err, jc.ErrorIsNil)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *ManSuite) TestGenerateManPages(c *gc.C) {
+	dir := c.MkDir()
+	err := cmd.GenerateManPages(s.ctx, newDocTreeCommand(), dir)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(listDir(c, dir), jc.DeepEquals, []string{
+		"juju-metadata-completion.1",
+		"juju-metadata-documentation.1",
+		"juju-metadata-generate.1",
+		"juju-metadata-help.1",
+		"juju-metadata-model-documentation.1",
+		"juju-metadata-model-generate.1",
+		"juju-metadata-model-help.1",
+		"juju-metadata-model.1",
+		"juju-metadata.1",
+	})
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "juju-metadata-generate.1"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(data), gc.Equals, `
+.TH "JUJU\-METADATA\-GENERATE" 1
+.SH NAME
+juju\-metadata\-generate \- Generate tools metadata.
+.SH SYNOPSIS
+\fBjuju\-metadata generate\fR [flags] <dir>
+.SH DESCRIPTION
+.nf
+Generate simplestreams metadata for the tools in <dir>.
+\&.hidden is not a request
+.fi
+.SH FLAGS
+.TP
+\fB\-s\fR, \fB\-\-stream\fR (= released)
+The stream to generate
+.SH EXAMPLES
+.nf
+juju\-metadata generate \-s devel ./tools
+.fi
+.SH ALIASES
+gen
+.SH SEE ALSO
+\fBjuju\-metadata\-validate\fR(1)
+`[1:])
+
+	data, err = ioutil.ReadFile(filepath.Join(dir, "juju-metadata.1"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(data), gc.Matches, `(?s)\.TH "JUJU\\-METADATA" 1
+\.SH NAME
+juju\\-metadata \\- Manage simplestreams metadata\.
+\.SH SYNOPSIS
+\\fBjuju\\-metadata\\fR \[flags\] <command> \.\.\.
+\.SH DESCRIPTION
+\.nf
+commands:
+.*    generate      \\- Generate tools metadata\.
+.*`)
+}
+
+func (s *ManSuite) TestGenerateManPagesMissingDir(c *gc.C) {
+	err := cmd.GenerateManPages(s.ctx, newDocTreeCommand(), filepath.Join(c.MkDir(), "missing"))
+	c.Assert(err, gc.ErrorMatches, `stat .*/missing: no such file or directory`)
+}
+
+func (s *ManSuite) TestDocumentationMan(c *gc.C) {
+	dir := c.MkDir()
+	code := cmd.Main(newDocTreeCommand(), s.ctx, []string{"documentation", "--man", "--out", dir})
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(listDir(c, dir), gc.HasLen, 9)
+	c.Assert(cmdtesting.Stdout(s.ctx), gc.Equals, "")
+}
+
+func (s *ManSuite) TestDocumentationManFileMode(c *gc.C) {
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "juju-metadata", Log: &cmd.Log{}})
+	sc.Register(&manTestCommand{})
+	err := os.Mkdir(filepath.Join(s.ctx.Dir, "man"), 0755)
+	c.Assert(err, jc.ErrorIsNil)
+	code := cmd.Main(sc, s.ctx, []string{"--file-mode", "0600", "documentation", "--man", "--out", "man"})
+	c.Assert(code, gc.Equals, 0, gc.Commentf("%s", cmdtesting.Stderr(s.ctx)))
+	dir := filepath.Join(s.ctx.Dir, "man")
+	c.Assert(listDir(c, dir), jc.DeepEquals, []string{
+		"juju-metadata-completion.1",
+		"juju-metadata-documentation.1",
+		"juju-metadata-generate.1",
+		"juju-metadata-help.1",
+		"juju-metadata.1",
+	})
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(filepath.Join(dir, "juju-metadata-generate.1"))
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(info.Mode().Perm(), gc.Equals, os.FileMode(0600))
+	}
+}
+
+func (s *ManSuite) TestDocumentationManNeedsOut(c *gc.C) {
+	code := cmd.Main(newDocTreeCommand(), s.ctx, []string{"documentation", "--man"})
+	c.Assert(code, gc.Equals, 1)
+	c.Assert(cmdtesting.Stderr(s.ctx), gc.Equals, "ERROR set the output folder when using the man option\n")
+}
+
+func (s *ManSuite) TestPagesRender(c *gc.C) {
+	mandoc, err := exec.LookPath("mandoc")
+	if err != nil {
+		c.Skip("mandoc not available")
+	}
+	dir := c.MkDir()
+	err = cmd.GenerateManPages(s.ctx, newDocTreeCommand(), dir)
+	c.Assert(err, jc.ErrorIsNil)
+	out, err := exec.Command(mandoc, "-Tlint", "-Wwarning", filepath.Join(dir, "juju-metadata-generate.1")).CombinedOutput()
+	c.Assert(err, jc.ErrorIsNil, gc.Commentf("%s", out))
+}
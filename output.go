@@ -7,10 +7,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"os"
+	"reflect"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/juju/errors"
 	"github.com/juju/gnuflag"
 	goyaml "gopkg.in/yaml.v2"
 )
@@ -112,6 +114,29 @@ var DefaultFormatters = formatters{
 	"json":  TypeFormatter{Formatter: FormatJson, Serialisable: true},
 }
 
+// ShellFormatters holds the default formatters together with "shell" and
+// "powershell", which write a result as variable assignments (see
+// FormatShell). They are for commands whose results are meant to be
+// evaluated by scripts, which opt in by using them in place of
+// DefaultFormatters.
+var ShellFormatters = formatters{
+	"smart":      TypeFormatter{Formatter: FormatSmart, Serialisable: false},
+	"yaml":       TypeFormatter{Formatter: FormatYaml, Serialisable: true},
+	"json":       TypeFormatter{Formatter: FormatJson, Serialisable: true},
+	"shell":      TypeFormatter{Formatter: FormatShell, Serialisable: true},
+	"powershell": TypeFormatter{Formatter: FormatPowerShell, Serialisable: true},
+}
+
+// TabularFormatters holds the formatters for commands whose results are
+// tables (see Table): a human readable "tabular" format, which renders
+// other values as "smart" does, together with yaml and json. Commands
+// typically use "tabular" as their default format.
+var TabularFormatters = formatters{
+	"tabular": TypeFormatter{Formatter: FormatTable, Serialisable: false},
+	"yaml":    TypeFormatter{Formatter: FormatYaml, Serialisable: true},
+	"json":    TypeFormatter{Formatter: FormatJson, Serialisable: true},
+}
+
 // formatterValue implements gnuflag.Value for the --format flag.
 type formatterValue struct {
 	name       string
@@ -159,11 +184,18 @@ func (v *formatterValue) format(writer io.Writer, value interface{}) error {
 	return v.formatters[v.name](writer, value)
 }
 
+// HumanTimeLayout is the layout used by Output.FormatTime when rendering
+// times for non-serialisable (human readable) formats.
+const HumanTimeLayout = "02 Jan 2006 15:04:05Z07:00"
+
 // Output is responsible for interpreting output-related command line flags
 // and writing a value to a file or to stdout as directed.
 type Output struct {
 	formatter *formatterValue
 	outPath   string
+	utc       bool
+	sortBy    string
+	columns   string
 }
 
 // AddFlags injects the --format and --output command line flags into f.
@@ -174,6 +206,45 @@ func (c *Output) AddFlags(f *gnuflag.FlagSet, defaultFormatter string, formatter
 	f.StringVar(&c.outPath, "output", "", "")
 }
 
+// AddTimeFlags injects the --utc command line flag into f. Commands that
+// display times should add this flag and render their times with
+// FormatTime.
+func (c *Output) AddTimeFlags(f *gnuflag.FlagSet) {
+	f.BoolVar(&c.utc, "utc", false, "Display times in UTC")
+}
+
+// FormatTime renders t according to the chosen output format. Serialisable
+// formats (e.g. yaml and json) always use RFC3339 in UTC, so that the output
+// is stable for machines. Other formats use HumanTimeLayout in the local
+// time zone, or in UTC if --utc was specified.
+func (c *Output) FormatTime(t time.Time) string {
+	if c.formatter != nil && isSerialisable(c.formatter.name, c.formatter.formatters[c.formatter.name]) {
+		return t.UTC().Format(time.RFC3339)
+	}
+	if c.utc {
+		return t.UTC().Format(HumanTimeLayout)
+	}
+	return t.Local().Format(HumanTimeLayout)
+}
+
+// isSerialisable reports whether formatter, chosen with the given format
+// name, writes output for machines. The formatter is looked up among the
+// formatters provided here, which are told apart by their code as
+// functions cannot be compared. A command's own formatter is taken to be
+// serialisable if it has the name of a default one that is.
+func isSerialisable(name string, formatter Formatter) bool {
+	code := reflect.ValueOf(formatter).Pointer()
+	for _, known := range []formatters{DefaultFormatters, TabularFormatters, ShellFormatters} {
+		for _, typeFormatter := range known {
+			if reflect.ValueOf(typeFormatter.Formatter).Pointer() == code {
+				return typeFormatter.Serialisable
+			}
+		}
+	}
+	typeFormatter, ok := DefaultFormatters[name]
+	return ok && typeFormatter.Serialisable
+}
+
 // Write formats and outputs the value as directed by the --format and
 // --output command line flags.
 func (c *Output) Write(ctx *Context, value interface{}) (err error) {
@@ -192,19 +263,21 @@ func (c *Output) WriteFormatter(ctx *Context, formatter Formatter, value interfa
 }
 
 func (c *Output) writeFormatter(ctx *Context, formatter Formatter, value interface{}) (err error) {
-	var target io.Writer
+	if table, ok := value.(*Table); ok {
+		if value, err = c.arrangeTable(table); err != nil {
+			return err
+		}
+	}
 	if c.outPath == "" {
-		target = ctx.Stdout
+		err = formatter(ctx.Stdout, value)
 	} else {
-		path := ctx.AbsPath(c.outPath)
-		var f *os.File
-		if f, err = os.Create(path); err != nil {
-			return
-		}
-		defer f.Close()
-		target = f
+		// Write to the output file atomically, so that a failure part
+		// way through formatting never leaves a truncated file behind.
+		err = ctx.WriteFileFunc(c.outPath, DefaultFileMode, func(target io.Writer) error {
+			return formatter(target, value)
+		})
 	}
-	if err := formatter(target, value); err != nil {
+	if err != nil {
 		return err
 	}
 	// Suppress the handling of errors on stdout when a machine formatter is used.
@@ -216,3 +289,179 @@ func (c *Output) writeFormatter(ctx *Context, formatter Formatter, value interfa
 func (c *Output) Name() string {
 	return c.formatter.name
 }
+
+// RecordStream writes records to the output chosen with the --format and
+// --output command line flags as they are produced, so that commands
+// listing many items need not hold them all in memory. It is created with
+// Output.Stream or Output.StreamTable, and must be closed once all records
+// have been written.
+//
+// With FormatJson, each record is written as a single line of JSON
+// (NDJSON). With FormatYaml, and with FormatSmart and FormatTable, which
+// format a list of records as yaml, each record is written as an item of a
+// single YAML sequence, so the output is the same as formatting a slice of
+// all the records. Other formatters, including a command's own "json" or
+// "yaml" formatter, cannot be written incrementally, so the records are
+// collected and formatted as a slice when the stream is closed.
+type RecordStream struct {
+	output    *Output
+	formatter Formatter
+	writer    io.Writer
+	file      *atomicFile
+	stream    func(writer io.Writer, record interface{}) error
+	records   []interface{}
+	table     *Table
+	rows      *tableRowWriter
+	err       error
+}
+
+// streamFormatter returns the function that writes a single record as
+// formatter would write it as part of a list of records, or nil if
+// formatter cannot be written incrementally. Formatters are told apart by
+// their code, as functions cannot be compared.
+func streamFormatter(formatter Formatter) func(writer io.Writer, record interface{}) error {
+	switch reflect.ValueOf(formatter).Pointer() {
+	case reflect.ValueOf(FormatJson).Pointer():
+		return FormatJson
+	case reflect.ValueOf(FormatYaml).Pointer(),
+		reflect.ValueOf(FormatSmart).Pointer(),
+		reflect.ValueOf(FormatTable).Pointer():
+		return func(writer io.Writer, record interface{}) error {
+			return FormatYaml(writer, []interface{}{record})
+		}
+	}
+	return nil
+}
+
+// Stream starts writing a stream of records as directed by the --format
+// and --output command line flags. An output file is only replaced when
+// the stream is closed without error.
+func (c *Output) Stream(ctx *Context) (*RecordStream, error) {
+	s, err := c.newStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.stream = streamFormatter(s.formatter)
+	return s, nil
+}
+
+// StreamTable starts writing a Table with the given headers as a stream
+// of rows, each written to the stream as a []string. The rows are
+// arranged as directed by the --columns flag. With FormatTable, rows are
+// written as they are produced: the first rows are held back to choose the
+// widths of the columns, so a later row with a wider cell does not line
+// up. The rows must be collected when they are to be sorted with --sort,
+// or written with another formatter, so they are then written as a Table
+// when the stream is closed.
+func (c *Output) StreamTable(ctx *Context, headers ...string) (*RecordStream, error) {
+	arranged, err := c.arrangeTable(&Table{Headers: headers})
+	if err != nil {
+		return nil, err
+	}
+	s, err := c.newStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.table = &Table{Headers: headers}
+	if c.sortBy == "" && reflect.ValueOf(s.formatter).Pointer() == reflect.ValueOf(FormatTable).Pointer() {
+		s.rows = &tableRowWriter{writer: s.writer, sample: [][]string{arranged.Headers}}
+	}
+	return s, nil
+}
+
+// newStream returns a RecordStream writing to the output directed by the
+// --output command line flag, that collects its records.
+func (c *Output) newStream(ctx *Context) (*RecordStream, error) {
+	s := &RecordStream{
+		output:    c,
+		formatter: c.formatter.formatters[c.formatter.name],
+		writer:    ctx.Stdout,
+	}
+	if c.outPath != "" {
+		file, err := ctx.createAtomicFile(c.outPath, DefaultFileMode)
+		if err != nil {
+			return nil, err
+		}
+		s.file = file
+		s.writer = file
+	}
+	// Suppress the handling of errors on stdout when a machine formatter is used.
+	ctx.outputFormatUsed = true
+	return s, nil
+}
+
+// Write writes a single record to the stream. Once writing has failed,
+// Write returns the same error for all later records.
+func (s *RecordStream) Write(record interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+	switch {
+	case s.table != nil:
+		row, ok := record.([]string)
+		if !ok {
+			s.err = errors.Errorf("cannot write %T as a table row", record)
+		} else if s.rows != nil {
+			s.err = s.writeRow(row)
+		} else {
+			s.table.AddRow(row...)
+		}
+	case s.stream != nil:
+		s.err = s.stream(s.writer, record)
+	default:
+		s.records = append(s.records, record)
+	}
+	return s.err
+}
+
+// writeRow writes a row of a streamed table, with the columns selected by
+// the --columns flag.
+func (s *RecordStream) writeRow(row []string) error {
+	arranged, err := s.output.arrangeTable(&Table{Headers: s.table.Headers, Rows: [][]string{row}})
+	if err != nil {
+		return err
+	}
+	return s.rows.write(arranged.Rows[0])
+}
+
+// Close finishes the stream, formatting any collected records, and
+// replacing the output file if there is one. It returns the first error
+// encountered while writing the stream.
+func (s *RecordStream) Close() error {
+	if s.err == nil {
+		s.err = s.finish()
+	}
+	if s.file == nil {
+		return s.err
+	}
+	if s.err != nil {
+		s.file.abort()
+	} else {
+		s.err = s.file.commit()
+	}
+	s.file = nil
+	return s.err
+}
+
+// finish writes out whatever the stream has held back.
+func (s *RecordStream) finish() error {
+	switch {
+	case s.rows != nil:
+		return s.rows.flush()
+	case s.table != nil:
+		table, err := s.output.arrangeTable(s.table)
+		s.table.Rows = nil
+		if err != nil {
+			return err
+		}
+		return s.formatter(s.writer, table)
+	case s.stream == nil:
+		records := s.records
+		if records == nil {
+			records = []interface{}{}
+		}
+		s.records = nil
+		return s.formatter(s.writer, records)
+	}
+	return nil
+}
@@ -4,6 +4,15 @@
 package cmd_test
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
 	"github.com/juju/gnuflag"
 	"github.com/juju/loggo"
 	"github.com/juju/testing"
@@ -183,7 +192,7 @@ func (s *OutputSuite) TestUnknownOutputFormat(c *gc.C) {
 	result := cmd.Main(&OutputCommand{}, s.ctx, []string{"--format", "cuneiform"})
 	c.Check(result, gc.Equals, 2)
 	c.Check(bufferString(s.ctx.Stdout), gc.Equals, "")
-	c.Check(bufferString(s.ctx.Stderr), gc.Matches, ".*: unknown format \"cuneiform\"\n")
+	c.Check(bufferString(s.ctx.Stderr), gc.Matches, "ERROR .*: unknown format \"cuneiform\"\n(?s).*")
 }
 
 // Py juju allowed both --format json and --format=json. This test verifies that juju is
@@ -206,3 +215,263 @@ func (s *OutputSuite) TestFormatters(c *gc.C) {
 		c.Assert(ok, gc.Equals, true)
 	}
 }
+
+// TimeOutputCommand is a command that uses Output.FormatTime.
+type TimeOutputCommand struct {
+	cmd.CommandBase
+	out        cmd.Output
+	formatters map[string]cmd.Formatter
+	when       time.Time
+}
+
+func (c *TimeOutputCommand) Info() *cmd.Info {
+	return &cmd.Info{Name: "time-output"}
+}
+
+func (c *TimeOutputCommand) SetFlags(f *gnuflag.FlagSet) {
+	formatters := c.formatters
+	if formatters == nil {
+		formatters = cmd.DefaultFormatters.Formatters()
+	}
+	c.out.AddFlags(f, "smart", formatters)
+	c.out.AddTimeFlags(f)
+}
+
+func (c *TimeOutputCommand) Run(ctx *cmd.Context) error {
+	return c.out.Write(ctx, map[string]string{"when": c.out.FormatTime(c.when)})
+}
+
+func (s *OutputSuite) TestFormatTime(c *gc.C) {
+	zone := time.FixedZone("test", 10*60*60)
+	when := time.Date(2022, 2, 3, 4, 5, 6, 0, zone)
+	s.PatchValue(&time.Local, zone)
+
+	for i, test := range []struct {
+		args   []string
+		output string
+	}{{
+		args:   nil,
+		output: "when: 03 Feb 2022 04:05:06+10:00\n",
+	}, {
+		args:   []string{"--utc"},
+		output: "when: 02 Feb 2022 18:05:06Z\n",
+	}, {
+		args:   []string{"--format", "yaml"},
+		output: "when: \"2022-02-02T18:05:06Z\"\n",
+	}, {
+		args:   []string{"--format", "json", "--utc"},
+		output: `{"when":"2022-02-02T18:05:06Z"}` + "\n",
+	}} {
+		c.Logf("test %d: %v", i, test.args)
+		ctx := cmdtesting.Context(c)
+		result := cmd.Main(&TimeOutputCommand{when: when}, ctx, test.args)
+		c.Check(result, gc.Equals, 0)
+		c.Check(bufferString(ctx.Stdout), gc.Equals, test.output)
+	}
+}
+
+func (s *OutputSuite) TestFormatTimeCommandFormatters(c *gc.C) {
+	zone := time.FixedZone("test", 10*60*60)
+	when := time.Date(2022, 2, 3, 4, 5, 6, 0, zone)
+	s.PatchValue(&time.Local, zone)
+
+	for i, test := range []struct {
+		formatters map[string]cmd.Formatter
+		args       []string
+		output     string
+	}{{
+		formatters: cmd.ShellFormatters.Formatters(),
+		args:       []string{"--format", "shell"},
+		output:     "when='2022-02-02T18:05:06Z'\n",
+	}, {
+		// The command's own "yaml" format is not serialisable.
+		formatters: map[string]cmd.Formatter{"smart": cmd.FormatSmart, "yaml": cmd.FormatSmart},
+		args:       []string{"--format", "yaml"},
+		output:     "when: 03 Feb 2022 04:05:06+10:00\n",
+	}, {
+		formatters: map[string]cmd.Formatter{"smart": cmd.FormatSmart, "machine": cmd.FormatJson},
+		args:       []string{"--format", "machine"},
+		output:     `{"when":"2022-02-02T18:05:06Z"}` + "\n",
+	}} {
+		c.Logf("test %d: %v", i, test.args)
+		ctx := cmdtesting.Context(c)
+		result := cmd.Main(&TimeOutputCommand{formatters: test.formatters, when: when}, ctx, test.args)
+		c.Check(result, gc.Equals, 0)
+		c.Check(bufferString(ctx.Stdout), gc.Equals, test.output)
+	}
+}
+
+func (s *OutputSuite) TestOutputFile(c *gc.C) {
+	result := cmd.Main(&OutputCommand{value: "hello"}, s.ctx, []string{"-o", "out.txt"})
+	c.Assert(result, gc.Equals, 0)
+	c.Check(bufferString(s.ctx.Stdout), gc.Equals, "")
+	data, err := ioutil.ReadFile(filepath.Join(s.ctx.Dir, "out.txt"))
+	c.Assert(err, gc.IsNil)
+	c.Check(string(data), gc.Equals, "hello\n")
+}
+
+func (s *OutputSuite) TestOutputSpecialFile(c *gc.C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("no /dev/null on windows")
+	}
+	result := cmd.Main(&OutputCommand{value: "hello"}, s.ctx, []string{"-o", os.DevNull})
+	c.Assert(result, gc.Equals, 0)
+	result = cmd.Main(&StreamOutputCommand{records: streamRecords}, s.ctx, []string{"--format", "json", "-o", os.DevNull})
+	c.Assert(result, gc.Equals, 0)
+	c.Check(bufferString(s.ctx.Stderr), gc.Equals, "")
+}
+
+// StreamOutputCommand is a command that streams records to its output.
+type StreamOutputCommand struct {
+	cmd.CommandBase
+	out        cmd.Output
+	formatters map[string]cmd.Formatter
+	records    []interface{}
+	afterWrite func(ctx *cmd.Context, i int)
+}
+
+func (c *StreamOutputCommand) Info() *cmd.Info {
+	return &cmd.Info{Name: "stream-output"}
+}
+
+func (c *StreamOutputCommand) SetFlags(f *gnuflag.FlagSet) {
+	formatters := c.formatters
+	if formatters == nil {
+		formatters = cmd.DefaultFormatters.Formatters()
+	}
+	c.out.AddFlags(f, "smart", formatters)
+}
+
+func (c *StreamOutputCommand) Run(ctx *cmd.Context) error {
+	stream, err := c.out.Stream(ctx)
+	if err != nil {
+		return err
+	}
+	for i, record := range c.records {
+		if err := stream.Write(record); err != nil {
+			stream.Close()
+			return err
+		}
+		if c.afterWrite != nil {
+			c.afterWrite(ctx, i)
+		}
+	}
+	return stream.Close()
+}
+
+var streamRecords = []interface{}{
+	map[string]interface{}{"version": "2.9.0", "arch": "amd64"},
+	map[string]interface{}{"version": "2.9.1", "arch": "arm64"},
+}
+
+func (s *OutputSuite) TestStreamJson(c *gc.C) {
+	// While Main runs, ctx.Stdout wraps the buffer.
+	stdout := s.ctx.Stdout
+	var lines []string
+	command := &StreamOutputCommand{records: streamRecords, afterWrite: func(ctx *cmd.Context, i int) {
+		lines = append(lines, bufferString(stdout))
+	}}
+	result := cmd.Main(command, s.ctx, []string{"--format", "json"})
+	c.Assert(result, gc.Equals, 0)
+	c.Check(lines, gc.DeepEquals, []string{
+		`{"arch":"amd64","version":"2.9.0"}` + "\n",
+		`{"arch":"amd64","version":"2.9.0"}` + "\n" + `{"arch":"arm64","version":"2.9.1"}` + "\n",
+	})
+	c.Check(bufferString(s.ctx.Stdout), gc.Equals, lines[1])
+}
+
+func (s *OutputSuite) TestStreamYaml(c *gc.C) {
+	command := &StreamOutputCommand{records: streamRecords}
+	result := cmd.Main(command, s.ctx, []string{"--format", "yaml"})
+	c.Assert(result, gc.Equals, 0)
+	c.Check(bufferString(s.ctx.Stdout), gc.Equals, `
+- arch: amd64
+  version: 2.9.0
+- arch: arm64
+  version: 2.9.1
+`[1:])
+}
+
+func (s *OutputSuite) TestStreamSmart(c *gc.C) {
+	stdout := s.ctx.Stdout
+	var written []string
+	command := &StreamOutputCommand{records: streamRecords, afterWrite: func(ctx *cmd.Context, i int) {
+		written = append(written, bufferString(stdout))
+	}}
+	result := cmd.Main(command, s.ctx, nil)
+	c.Assert(result, gc.Equals, 0)
+	c.Check(written[0], gc.Equals, "- arch: amd64\n  version: 2.9.0\n")
+	c.Check(bufferString(s.ctx.Stdout), gc.Equals, `
+- arch: amd64
+  version: 2.9.0
+- arch: arm64
+  version: 2.9.1
+`[1:])
+}
+
+func (s *OutputSuite) TestStreamCollected(c *gc.C) {
+	// A command's own json formatter cannot be streamed.
+	formatJson := func(w io.Writer, value interface{}) error {
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+	stdout := s.ctx.Stdout
+	command := &StreamOutputCommand{
+		formatters: map[string]cmd.Formatter{"smart": cmd.FormatSmart, "json": formatJson},
+		records:    streamRecords,
+		afterWrite: func(ctx *cmd.Context, i int) {
+			c.Check(bufferString(stdout), gc.Equals, "")
+		},
+	}
+	result := cmd.Main(command, s.ctx, []string{"--format", "json"})
+	c.Assert(result, gc.Equals, 0)
+	c.Check(bufferString(s.ctx.Stdout), gc.Equals, `
+[
+  {
+    "arch": "amd64",
+    "version": "2.9.0"
+  },
+  {
+    "arch": "arm64",
+    "version": "2.9.1"
+  }
+]
+`[1:])
+}
+
+func (s *OutputSuite) TestStreamEmpty(c *gc.C) {
+	result := cmd.Main(&StreamOutputCommand{}, s.ctx, []string{"--format", "json"})
+	c.Assert(result, gc.Equals, 0)
+	c.Check(bufferString(s.ctx.Stdout), gc.Equals, "")
+}
+
+func (s *OutputSuite) TestStreamFile(c *gc.C) {
+	path := filepath.Join(s.ctx.Dir, "out.json")
+	command := &StreamOutputCommand{records: streamRecords, afterWrite: func(ctx *cmd.Context, i int) {
+		_, err := os.Stat(path)
+		c.Check(os.IsNotExist(err), gc.Equals, true)
+	}}
+	result := cmd.Main(command, s.ctx, []string{"--format", "json", "-o", "out.json"})
+	c.Assert(result, gc.Equals, 0)
+	c.Check(bufferString(s.ctx.Stdout), gc.Equals, "")
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, gc.IsNil)
+	c.Check(string(data), gc.Equals, `{"arch":"amd64","version":"2.9.0"}`+"\n"+`{"arch":"arm64","version":"2.9.1"}`+"\n")
+	entries, err := ioutil.ReadDir(s.ctx.Dir)
+	c.Assert(err, gc.IsNil)
+	c.Check(entries, gc.HasLen, 1)
+}
+
+func (s *OutputSuite) TestStreamFileError(c *gc.C) {
+	command := &StreamOutputCommand{records: []interface{}{streamRecords[0], make(chan int)}}
+	result := cmd.Main(command, s.ctx, []string{"--format", "json", "-o", "out.json"})
+	c.Assert(result, gc.Equals, 1)
+	c.Check(bufferString(s.ctx.Stderr), gc.Matches, "ERROR json: unsupported type: chan int\n")
+	entries, err := ioutil.ReadDir(s.ctx.Dir)
+	c.Assert(err, gc.IsNil)
+	c.Check(entries, gc.HasLen, 0)
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// durationNow returns the current time, for timing commands.
+var durationNow = time.Now
+
+// phaseTimings records the time taken by each phase of a command, as
+// reported with Context.StartPhase.
+type phaseTimings struct {
+	mu     sync.Mutex
+	phases []phaseTiming
+}
+
+type phaseTiming struct {
+	name     string
+	duration time.Duration
+}
+
+// StartPhase records the start of a named phase of the command, such as
+// "fetching" or "generating", and returns a function to be called when the
+// phase is done. If the command was run with --show-duration (see
+// SuperCommandParams.ShowDurationFlag), the time taken by each phase is
+// shown when the command finishes; otherwise the phases are not recorded.
+// A phase may be started more than once, in which case the times are
+// added together.
+func (ctx *Context) StartPhase(name string) (done func()) {
+	timings := ctx.phaseTimings
+	if timings == nil {
+		return func() {}
+	}
+	start := durationNow()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			timings.add(name, durationNow().Sub(start))
+		})
+	}
+}
+
+func (t *phaseTimings) add(name string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.phases {
+		if t.phases[i].name == name {
+			t.phases[i].duration += d
+			return
+		}
+	}
+	t.phases = append(t.phases, phaseTiming{name: name, duration: d})
+}
+
+// writeDuration writes the total time taken by a command, and by each of
+// the phases it reported, to ctx.Stderr.
+func (ctx *Context) writeDuration(total time.Duration) {
+	lines := []string{fmt.Sprintf("Took %s", roundDuration(total))}
+	if ctx.phaseTimings != nil {
+		ctx.phaseTimings.mu.Lock()
+		for _, phase := range ctx.phaseTimings.phases {
+			lines = append(lines, fmt.Sprintf("  %s: %s", phase.name, roundDuration(phase.duration)))
+		}
+		ctx.phaseTimings.mu.Unlock()
+	}
+	for _, line := range lines {
+		ctx.writeLine(ctx.Stderr, "%s", line)
+	}
+}
+
+// roundDuration rounds d for display, to milliseconds for durations of a
+// second or more, and to microseconds otherwise.
+func roundDuration(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(time.Microsecond)
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type PhaseTimingsSuite struct {
+	testing.LoggingCleanupSuite
+	now time.Time
+}
+
+var _ = gc.Suite(&PhaseTimingsSuite{})
+
+func (s *PhaseTimingsSuite) SetUpTest(c *gc.C) {
+	s.LoggingCleanupSuite.SetUpTest(c)
+	s.now = time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	s.PatchValue(cmd.DurationNow, func() time.Time { return s.now })
+}
+
+func (s *PhaseTimingsSuite) advance(d time.Duration) {
+	s.now = s.now.Add(d)
+}
+
+func (s *PhaseTimingsSuite) run(c *gc.C, runErr error, args ...string) (*cmd.Context, int) {
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{
+		Name:             "juju-metadata",
+		ShowDurationFlag: true,
+	})
+	sc.Register(&TestCommand{Name: "generate", CustomRun: func(ctx *cmd.Context) error {
+		done := ctx.StartPhase("fetching")
+		s.advance(1500 * time.Millisecond)
+		done()
+		done = ctx.StartPhase("generating")
+		s.advance(250 * time.Millisecond)
+		done()
+		done = ctx.StartPhase("fetching")
+		s.advance(500 * time.Millisecond)
+		done()
+		done()
+		return runErr
+	}})
+	ctx := cmdtesting.Context(c)
+	code := cmd.Main(sc, ctx, append([]string{"generate"}, args...))
+	return ctx, code
+}
+
+func (s *PhaseTimingsSuite) TestShowDuration(c *gc.C) {
+	ctx, code := s.run(c, nil, "--show-duration")
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, `
+Took 2.25s
+  fetching: 2s
+  generating: 250ms
+`[1:])
+}
+
+func (s *PhaseTimingsSuite) TestShowDurationBeforeSubcommand(c *gc.C) {
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{
+		Name:             "juju-metadata",
+		ShowDurationFlag: true,
+	})
+	sc.Register(&TestCommand{Name: "generate", CustomRun: func(ctx *cmd.Context) error {
+		s.advance(time.Second)
+		return nil
+	}})
+	ctx := cmdtesting.Context(c)
+	code := cmd.Main(sc, ctx, []string{"--show-duration", "generate"})
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "Took 1s\n")
+}
+
+func (s *PhaseTimingsSuite) TestShowDurationOnError(c *gc.C) {
+	ctx, code := s.run(c, errors.New("storage write failed"), "--show-duration")
+	c.Assert(code, gc.Equals, 1)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, `
+ERROR storage write failed
+Took 2.25s
+  fetching: 2s
+  generating: 250ms
+`[1:])
+}
+
+func (s *PhaseTimingsSuite) TestNotShown(c *gc.C) {
+	ctx, code := s.run(c, nil)
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "")
+}
+
+func (s *PhaseTimingsSuite) TestFlagOptIn(c *gc.C) {
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "juju-metadata"})
+	sc.Register(&TestCommand{Name: "generate"})
+	ctx := cmdtesting.Context(c)
+	code := cmd.Main(sc, ctx, []string{"generate", "--show-duration"})
+	c.Assert(code, gc.Equals, 2)
+	c.Assert(cmdtesting.Stderr(ctx), jc.HasPrefix, "ERROR flag provided but not defined: --show-duration\n")
+}
+
+func (s *PhaseTimingsSuite) TestStartPhaseNotRecording(c *gc.C) {
+	ctx := cmdtesting.Context(c)
+	done := ctx.StartPhase("fetching")
+	done()
+}
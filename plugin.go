@@ -0,0 +1,298 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+const (
+	// PluginProtocolVersion is the version of the protocol between a
+	// SuperCommand and the plugins it runs that is implemented by this
+	// package. It is increased whenever the protocol changes in a way
+	// that is visible to either side.
+	PluginProtocolVersion = 1
+
+	// PluginProtocolEnvVar is the environment variable in which the
+	// protocol version of the host is passed to the plugins it runs.
+	PluginProtocolEnvVar = "JUJU_CMD_PLUGIN_PROTOCOL"
+)
+
+// PluginDescription describes a plugin, as written in JSON by a plugin run
+// with --describe, for hosts to integrate the plugin into their help.
+type PluginDescription struct {
+	// Protocol is the plugin protocol version of the plugin.
+	Protocol int `json:"protocol"`
+	// Name is the name of the plugin.
+	Name string `json:"name"`
+	// Purpose is a short description of the plugin.
+	Purpose string `json:"purpose,omitempty"`
+	// Version is the version of the plugin, if it has one.
+	Version string `json:"version,omitempty"`
+	// Commands describes the subcommands of the plugin.
+	Commands []PluginCommandDescription `json:"commands,omitempty"`
+}
+
+// PluginCommandDescription describes a subcommand of a plugin.
+type PluginCommandDescription struct {
+	Name    string `json:"name"`
+	Purpose string `json:"purpose,omitempty"`
+}
+
+// PluginHostProtocol returns the plugin protocol version of the host that
+// ran the current process as a plugin, and whether it was run by a host
+// that supports the protocol at all.
+func PluginHostProtocol() (int, bool) {
+	version, err := strconv.Atoi(os.Getenv(PluginProtocolEnvVar))
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// DescribePlugin runs the plugin executable at path with --describe, as
+// is done for plugins found by NewPluginCallback, and returns the
+// description it writes. If the plugin does not support the plugin
+// protocol, or supports only a later version of it, the error satisfies
+// errors.IsNotSupported, and hosts may fall back to running the plugin
+// with --description.
+func DescribePlugin(ctx *Context, path string) (*PluginDescription, error) {
+	var stdout, stderr bytes.Buffer
+	plugin := exec.CommandContext(ctx, path, "--describe")
+	plugin.Dir = ctx.Dir
+	plugin.Env = pluginEnv(ctx)
+	plugin.Stdout = &stdout
+	plugin.Stderr = &stderr
+	if err := plugin.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			logger.Debugf("plugin %s --describe failed: %s", path, strings.TrimSpace(stderr.String()))
+			return nil, errors.NotSupportedf("describing plugin %q", path)
+		}
+		return nil, errors.Annotatef(err, "describing plugin %q", path)
+	}
+	var description PluginDescription
+	if err := json.Unmarshal(stdout.Bytes(), &description); err != nil {
+		return nil, errors.Annotatef(err, "invalid description of plugin %q", path)
+	}
+	if description.Protocol <= 0 {
+		return nil, errors.NotSupportedf("describing plugin %q", path)
+	}
+	if description.Protocol > PluginProtocolVersion {
+		return nil, errors.NotSupportedf("plugin %q protocol version %d (up to %d supported)",
+			path, description.Protocol, PluginProtocolVersion)
+	}
+	return &description, nil
+}
+
+// NewPluginCallback returns a MissingCallback that runs external plugins
+// for subcommands that are not registered with a SuperCommand. The plugin
+// for a subcommand is an executable named "<prefix>-<subcommand>" found
+// in a directory on the PATH, so that, for example, with a prefix of
+// "juju", "juju metadata generate" runs "juju-metadata generate". The
+// plugin is run with the remaining arguments, in ctx.Dir, with ctx's
+// standard streams and with the process environment updated with
+// ctx.Env and with PluginProtocolEnvVar set. If the plugin exits with a
+// non-zero code, the returned error is an RcPassthroughError holding that
+// code.
+func NewPluginCallback(prefix string) MissingCallback {
+	return func(ctx *Context, subcommand string, args []string) error {
+		path, ok := findPlugin(ctx, prefix+"-"+subcommand)
+		if !ok {
+			return DefaultUnrecognizedCommand(subcommand)
+		}
+		logger.Debugf("running plugin %s", path)
+		// The plugin is not killed when ctx is cancelled, as it receives
+		// any interrupt from the terminal itself, and may handle it.
+		plugin := exec.Command(path, args...)
+		plugin.Dir = ctx.Dir
+		plugin.Env = pluginEnv(ctx)
+		// The plugin writes to the underlying writers, so that it can
+		// tell whether they are terminals.
+		plugin.Stdin = ctx.Stdin
+		plugin.Stdout = flushedWriter(ctx.Stdout)
+		plugin.Stderr = flushedWriter(ctx.Stderr)
+		err := plugin.Run()
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() > 0 {
+			return NewRcPassthroughError(exitErr.ExitCode())
+		}
+		return errors.Annotatef(err, "running plugin %q", path)
+	}
+}
+
+// findPlugin returns the path of the named executable, if it is found in
+// a directory on the PATH of ctx.
+func findPlugin(ctx *Context, name string) (string, bool) {
+	if strings.ContainsAny(name, `/\`) {
+		return "", false
+	}
+	for _, dir := range pluginDirs(ctx) {
+		path := filepath.Join(dir, name)
+		if isExecutable(path) {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// findPlugins returns the paths of the executables on the PATH of ctx
+// whose names start with prefix, keyed by name. Where there are several
+// with the same name, the first on the PATH is the one that is run.
+func findPlugins(ctx *Context, prefix string) map[string]string {
+	plugins := make(map[string]string)
+	for _, dir := range pluginDirs(ctx) {
+		names, err := filepath.Glob(filepath.Join(dir, prefix+"*"))
+		if err != nil {
+			continue
+		}
+		for _, path := range names {
+			name := filepath.Base(path)
+			if _, found := plugins[name]; !found && isExecutable(path) {
+				plugins[name] = path
+			}
+		}
+	}
+	return plugins
+}
+
+// pluginDirs returns the directories on the PATH of ctx.
+func pluginDirs(ctx *Context) []string {
+	pathList, ok := ctx.Env["PATH"]
+	if !ok {
+		pathList = os.Getenv("PATH")
+	}
+	var dirs []string
+	for _, dir := range filepath.SplitList(pathList) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// isExecutable reports whether path is an executable regular file.
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0
+}
+
+// addPluginsTopic adds the "plugins" help topic, which lists the plugins
+// with the given prefix that the SuperCommand can run.
+func (c *SuperCommand) addPluginsTopic(prefix string) {
+	c.help.topics["plugins"] = topic{
+		short: "Show the plugins found on the PATH",
+		write: func(ctx *Context) error {
+			return writePluginList(ctx, prefix)
+		},
+	}
+}
+
+// writePluginList writes the name and purpose of each plugin with the
+// given prefix to ctx.Stdout. Plugins are described with DescribePlugin,
+// or with --description if they do not support the plugin protocol.
+func writePluginList(ctx *Context, prefix string) error {
+	plugins := findPlugins(ctx, prefix+"-")
+	if len(plugins) == 0 {
+		fmt.Fprintln(ctx.Stdout, "No plugins found.")
+		return nil
+	}
+	names := make([]string, 0, len(plugins))
+	longest := 0
+	for name := range plugins {
+		name = strings.TrimPrefix(name, prefix+"-")
+		names = append(names, name)
+		if len(name) > longest {
+			longest = len(name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := plugins[prefix+"-"+name]
+		var purpose string
+		description, err := DescribePlugin(ctx, path)
+		switch {
+		case err == nil:
+			purpose = description.Purpose
+		case errors.IsNotSupported(err):
+			purpose = legacyPluginDescription(ctx, path)
+		default:
+			logger.Debugf("%v", err)
+		}
+		fmt.Fprintln(ctx.Stdout, strings.TrimRight(fmt.Sprintf("%-*s  %s", longest, name, purpose), " "))
+	}
+	return nil
+}
+
+// legacyPluginDescription returns the first line written by the plugin at
+// path when it is run with --description, or "" if it fails.
+func legacyPluginDescription(ctx *Context, path string) string {
+	var stdout bytes.Buffer
+	plugin := exec.CommandContext(ctx, path, "--description")
+	plugin.Dir = ctx.Dir
+	plugin.Env = pluginEnv(ctx)
+	plugin.Stdout = &stdout
+	if err := plugin.Run(); err != nil {
+		logger.Debugf("plugin %s --description failed: %v", path, err)
+		return ""
+	}
+	return strings.TrimSpace(strings.SplitN(stdout.String(), "\n", 2)[0])
+}
+
+// pluginEnv returns the environment in which plugins are run: that of the
+// process, updated with the variables set in ctx, and with the plugin
+// protocol version of the host.
+func pluginEnv(ctx *Context) []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		key := strings.SplitN(kv, "=", 2)[0]
+		if _, ok := ctx.Env[key]; !ok && key != PluginProtocolEnvVar {
+			env = append(env, kv)
+		}
+	}
+	for key, value := range ctx.Env {
+		if key != PluginProtocolEnvVar {
+			env = append(env, key+"="+value)
+		}
+	}
+	return append(env, PluginProtocolEnvVar+"="+strconv.Itoa(PluginProtocolVersion))
+}
+
+// describe writes the description of c as a plugin to ctx.Stdout, for
+// --describe.
+func (c *SuperCommand) describe(ctx *Context) error {
+	description := PluginDescription{
+		Protocol: PluginProtocolVersion,
+		Name:     c.Name,
+		Purpose:  c.Purpose,
+		Version:  c.version,
+	}
+	names := make([]string, 0, len(c.subcmds))
+	for name, ref := range c.subcmds {
+		if ref.alias == "" && !ref.hidden {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		description.Commands = append(description.Commands, PluginCommandDescription{
+			Name:    name,
+			Purpose: c.subcmds[name].command.Info().Purpose,
+		})
+	}
+	data, err := json.Marshal(description)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, err = ctx.Stdout.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,199 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type PluginSuite struct {
+	testing.LoggingCleanupSuite
+	ctx *cmd.Context
+	dir string
+}
+
+var _ = gc.Suite(&PluginSuite{})
+
+func (s *PluginSuite) SetUpTest(c *gc.C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("plugins are shell scripts")
+	}
+	s.LoggingCleanupSuite.SetUpTest(c)
+	s.ctx = cmdtesting.Context(c)
+	s.dir = c.MkDir()
+	err := s.ctx.Setenv("PATH", s.dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *PluginSuite) writePlugin(c *gc.C, name, script string, perm os.FileMode) {
+	path := filepath.Join(s.dir, name)
+	err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), perm)
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *PluginSuite) newSuperCommand() *cmd.SuperCommand {
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{
+		Name:         "juju",
+		PluginPrefix: "juju",
+	})
+	sc.Register(&simple{name: "blah"})
+	return sc
+}
+
+func (s *PluginSuite) TestRunsPlugin(c *gc.C) {
+	s.writePlugin(c, "juju-metadata", `echo "args: $*"; echo "dir: $(pwd)"; echo "env: $JUJU_MODEL"; cat >&2`, 0755)
+	s.ctx.Stdin = bytes.NewBufferString("input\n")
+	err := s.ctx.Setenv("JUJU_MODEL", "controller")
+	c.Assert(err, jc.ErrorIsNil)
+
+	code := cmd.Main(s.newSuperCommand(), s.ctx, []string{"metadata", "generate", "--stream", "devel"})
+	c.Check(code, gc.Equals, 0)
+	dir, err := filepath.EvalSymlinks(s.ctx.Dir)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(cmdtesting.Stdout(s.ctx), gc.Equals, "args: generate --stream devel\ndir: "+dir+"\nenv: controller\n")
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, "input\n")
+}
+
+func (s *PluginSuite) TestExitCodePassedThrough(c *gc.C) {
+	s.writePlugin(c, "juju-metadata", "exit 3", 0755)
+	code := cmd.Main(s.newSuperCommand(), s.ctx, []string{"metadata"})
+	c.Check(code, gc.Equals, 3)
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, "")
+}
+
+func (s *PluginSuite) TestRegisteredCommandPreferred(c *gc.C) {
+	s.writePlugin(c, "juju-blah", "echo plugin", 0755)
+	code := cmd.Main(s.newSuperCommand(), s.ctx, []string{"blah", "arg"})
+	c.Check(code, gc.Equals, 0)
+	c.Check(cmdtesting.Stdout(s.ctx), gc.Equals, "blah arg\n")
+}
+
+func (s *PluginSuite) TestNotFound(c *gc.C) {
+	s.writePlugin(c, "juju-metadata", "echo not executable", 0644)
+	code := cmd.Main(s.newSuperCommand(), s.ctx, []string{"metadata"})
+	c.Check(code, gc.Equals, 1)
+	c.Check(cmdtesting.Stdout(s.ctx), gc.Equals, "")
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, "ERROR unrecognized command: juju metadata\n")
+}
+
+func (s *PluginSuite) TestPathSeparatorRejected(c *gc.C) {
+	err := os.Mkdir(filepath.Join(s.dir, "juju-sub"), 0755)
+	c.Assert(err, jc.ErrorIsNil)
+	s.writePlugin(c, "juju-sub/tool", "echo escaped", 0755)
+	code := cmd.Main(s.newSuperCommand(), s.ctx, []string{"sub/tool"})
+	c.Check(code, gc.Equals, 1)
+	c.Check(cmdtesting.Stdout(s.ctx), gc.Equals, "")
+}
+
+func (s *PluginSuite) TestHelp(c *gc.C) {
+	s.writePlugin(c, "juju-metadata", `echo "help: $*"`, 0755)
+	code := cmd.Main(s.newSuperCommand(), s.ctx, []string{"help", "metadata"})
+	c.Check(code, gc.Equals, 0)
+	c.Check(cmdtesting.Stdout(s.ctx), gc.Equals, "help: --help\n")
+}
+
+func (s *PluginSuite) TestHelpPlugins(c *gc.C) {
+	s.writePlugin(c, "juju-metadata", `echo '{"protocol":1,"name":"juju-metadata","purpose":"manage metadata"}'`, 0755)
+	s.writePlugin(c, "juju-old", `[ "$1" = --description ] || exit 2; echo "an old plugin"`, 0755)
+	s.writePlugin(c, "juju-broken", "exit 1", 0755)
+	s.writePlugin(c, "juju-data", "echo not executable", 0644)
+	code := cmd.Main(s.newSuperCommand(), s.ctx, []string{"help", "plugins"})
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(cmdtesting.Stdout(s.ctx), gc.Equals, `
+broken
+metadata  manage metadata
+old       an old plugin
+`[1:])
+}
+
+func (s *PluginSuite) TestHelpPluginsNone(c *gc.C) {
+	err := s.ctx.Setenv("PATH", s.dir)
+	c.Assert(err, jc.ErrorIsNil)
+	code := cmd.Main(s.newSuperCommand(), s.ctx, []string{"help", "plugins"})
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(cmdtesting.Stdout(s.ctx), gc.Equals, "No plugins found.\n")
+
+	s.ctx = cmdtesting.Context(c)
+	code = cmd.Main(s.newSuperCommand(), s.ctx, []string{"help", "topics"})
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(cmdtesting.Stdout(s.ctx), gc.Matches, "(?s).*plugins +Show the plugins found on the PATH\n.*")
+}
+
+func (s *PluginSuite) TestPluginProtocolEnv(c *gc.C) {
+	s.writePlugin(c, "juju-metadata", `echo "protocol: $JUJU_CMD_PLUGIN_PROTOCOL"`, 0755)
+	code := cmd.Main(s.newSuperCommand(), s.ctx, []string{"metadata"})
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(cmdtesting.Stdout(s.ctx), gc.Equals, "protocol: 1\n")
+}
+
+func (s *PluginSuite) TestDescribePlugin(c *gc.C) {
+	s.writePlugin(c, "juju-metadata", `
+[ "$1" = --describe ] && [ "$JUJU_CMD_PLUGIN_PROTOCOL" = 1 ] || exit 2
+echo '{"protocol":1,"name":"juju-metadata","purpose":"manage metadata","commands":[{"name":"generate","purpose":"generate metadata"}]}'`, 0755)
+	description, err := cmd.DescribePlugin(s.ctx, filepath.Join(s.dir, "juju-metadata"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(description, jc.DeepEquals, &cmd.PluginDescription{
+		Protocol: 1,
+		Name:     "juju-metadata",
+		Purpose:  "manage metadata",
+		Commands: []cmd.PluginCommandDescription{{Name: "generate", Purpose: "generate metadata"}},
+	})
+}
+
+func (s *PluginSuite) TestDescribePluginUnsupported(c *gc.C) {
+	s.writePlugin(c, "juju-old", `echo "ERROR flag provided but not defined: --describe" >&2; exit 2`, 0755)
+	_, err := cmd.DescribePlugin(s.ctx, filepath.Join(s.dir, "juju-old"))
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
+func (s *PluginSuite) TestDescribePluginLaterProtocol(c *gc.C) {
+	s.writePlugin(c, "juju-new", `echo '{"protocol":2,"name":"juju-new"}'`, 0755)
+	_, err := cmd.DescribePlugin(s.ctx, filepath.Join(s.dir, "juju-new"))
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	c.Assert(err, gc.ErrorMatches, `plugin ".*juju-new" protocol version 2 \(up to 1 supported\) not supported`)
+}
+
+func (s *PluginSuite) TestDescribePluginInvalid(c *gc.C) {
+	s.writePlugin(c, "juju-bad", `echo 'not json'`, 0755)
+	_, err := cmd.DescribePlugin(s.ctx, filepath.Join(s.dir, "juju-bad"))
+	c.Assert(err, gc.ErrorMatches, `invalid description of plugin ".*juju-bad": .*`)
+}
+
+func (s *PluginSuite) TestDescribe(c *gc.C) {
+	s.PatchEnvironment(cmd.PluginProtocolEnvVar, "1")
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{
+		Name:    "juju-metadata",
+		Purpose: "manage metadata",
+		Version: "2.9.1",
+	})
+	sc.Register(&simple{name: "generate"})
+	sc.RegisterHidden(&simple{name: "debug"})
+	code := cmd.Main(sc, s.ctx, []string{"--describe"})
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(cmdtesting.Stdout(s.ctx), gc.Equals, `{"protocol":1,"name":"juju-metadata","purpose":"manage metadata","version":"2.9.1","commands":[`+
+		`{"name":"completion","purpose":"Generate a shell completion script"},`+
+		`{"name":"documentation","purpose":"Generate the documentation for all commands"},`+
+		`{"name":"generate","purpose":"to be simple"},`+
+		`{"name":"help","purpose":"Show help on a command or other topic."},`+
+		`{"name":"version","purpose":"Print the current version."}]}`+"\n")
+}
+
+func (s *PluginSuite) TestDescribeNotOffered(c *gc.C) {
+	s.PatchEnvironment(cmd.PluginProtocolEnvVar, "")
+	code := cmd.Main(s.newSuperCommand(), s.ctx, []string{"--describe"})
+	c.Assert(code, gc.Equals, 2)
+	c.Assert(cmdtesting.Stderr(s.ctx), gc.Matches, "ERROR flag provided but not defined: --describe\n(.|\n)*")
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Arg describes a positional argument of a command; see Info.Positional.
+type Arg struct {
+	// Name names the argument in usage lines and errors.
+	Name string
+
+	// Optional arguments may be omitted. They must follow all of the
+	// arguments that are not optional.
+	Optional bool
+
+	// Variadic, which may only be set for the last argument, allows the
+	// argument to be given any number of times: at least once, unless
+	// it is also optional.
+	Variadic bool
+
+	// Values, if set, lists the values that shell completion offers for
+	// the argument. Arguments without values are completed as file
+	// names. The values are not checked by CheckArgs.
+	Values []string
+}
+
+// usage returns the argument as shown in usage lines, such as "<series>",
+// "[<arch>]" or "<file>...".
+func (a Arg) usage() string {
+	usage := "<" + a.Name + ">"
+	if a.Variadic {
+		usage += "..."
+	}
+	if a.Optional {
+		usage = "[" + usage + "]"
+	}
+	return usage
+}
+
+// argsUsage returns the arguments shown in the command's usage line: Args
+// if it is set, or else a description of Positional.
+func (i *Info) argsUsage() string {
+	if i.Args != "" || len(i.Positional) == 0 {
+		return i.Args
+	}
+	usage := make([]string, len(i.Positional))
+	for n, arg := range i.Positional {
+		usage[n] = arg.usage()
+	}
+	return strings.Join(usage, " ")
+}
+
+// CheckArgs returns an error if args, the positional arguments given to the
+// command, do not match info.Positional: naming the arguments that are
+// missing, or listing those that are not expected. Commands that declare no
+// positional arguments are not checked. It is called before Init, which
+// can rely on the number of arguments being valid.
+func CheckArgs(info *Info, args []string) error {
+	if len(info.Positional) == 0 {
+		return nil
+	}
+	var missing []string
+	for n, arg := range info.Positional {
+		if n >= len(args) && !arg.Optional {
+			missing = append(missing, arg.usage())
+		}
+	}
+	switch len(missing) {
+	case 0:
+	case 1:
+		return fmt.Errorf("missing argument: %s", missing[0])
+	default:
+		return fmt.Errorf("missing arguments: %s", strings.Join(missing, " "))
+	}
+	if len(args) <= len(info.Positional) || info.Positional[len(info.Positional)-1].Variadic {
+		return nil
+	}
+	return CheckEmpty(args[len(info.Positional):])
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"github.com/juju/gnuflag"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type PositionalSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&PositionalSuite{})
+
+// positionalCommand is a command that declares its positional arguments.
+type positionalCommand struct {
+	cmd.CommandBase
+	positional []cmd.Arg
+	args       []string
+}
+
+func (c *positionalCommand) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:       "add-image",
+		Purpose:    "add image metadata",
+		Positional: c.positional,
+	}
+}
+
+func (c *positionalCommand) SetFlags(f *gnuflag.FlagSet) {}
+
+func (c *positionalCommand) Init(args []string) error {
+	c.args = args
+	return nil
+}
+
+func (c *positionalCommand) Run(ctx *cmd.Context) error {
+	return nil
+}
+
+var imageArgs = []cmd.Arg{
+	{Name: "image-id"},
+	{Name: "series"},
+	{Name: "arch", Optional: true},
+}
+
+func (s *PositionalSuite) TestCheckArgs(c *gc.C) {
+	for _, test := range []struct {
+		positional []cmd.Arg
+		args       []string
+		err        string
+	}{
+		{positional: imageArgs, args: []string{"ami-1", "jammy"}},
+		{positional: imageArgs, args: []string{"ami-1", "jammy", "arm64"}},
+		{positional: imageArgs, args: []string{"ami-1"}, err: "missing argument: <series>"},
+		{positional: imageArgs, args: nil, err: "missing arguments: <image-id> <series>"},
+		{positional: imageArgs, args: []string{"ami-1", "jammy", "arm64", "x"}, err: `unrecognized args: \["x"\]`},
+		{positional: []cmd.Arg{{Name: "file", Variadic: true}}, args: []string{"a", "b", "c"}},
+		{positional: []cmd.Arg{{Name: "file", Variadic: true}}, args: nil, err: `missing argument: <file>...`},
+		{positional: []cmd.Arg{{Name: "file", Optional: true, Variadic: true}}, args: nil},
+		{positional: nil, args: []string{"anything"}},
+	} {
+		err := cmd.CheckArgs(&cmd.Info{Positional: test.positional}, test.args)
+		if test.err == "" {
+			c.Check(err, jc.ErrorIsNil, gc.Commentf("%v %q", test.positional, test.args))
+		} else {
+			c.Check(err, gc.ErrorMatches, test.err, gc.Commentf("%v %q", test.positional, test.args))
+		}
+	}
+}
+
+func (s *PositionalSuite) TestInitNotCalled(c *gc.C) {
+	command := &positionalCommand{positional: imageArgs}
+	err := cmdtesting.InitCommand(command, []string{"ami-1"})
+	c.Assert(err, gc.ErrorMatches, "missing argument: <series>")
+	c.Assert(command.args, gc.IsNil)
+
+	err = cmdtesting.InitCommand(command, []string{"ami-1", "jammy"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(command.args, gc.DeepEquals, []string{"ami-1", "jammy"})
+}
+
+func (s *PositionalSuite) TestMain(c *gc.C) {
+	ctx := cmdtesting.Context(c)
+	code := cmd.Main(&positionalCommand{positional: imageArgs}, ctx, nil)
+	c.Assert(code, gc.Equals, 2)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "ERROR missing arguments: <image-id> <series>\n")
+}
+
+func (s *PositionalSuite) TestSuperCommand(c *gc.C) {
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "juju-metadata"})
+	sc.Register(&positionalCommand{positional: imageArgs})
+	ctx := cmdtesting.Context(c)
+	code := cmd.Main(sc, ctx, []string{"add-image", "ami-1", "jammy", "arm64", "extra"})
+	c.Assert(code, gc.Equals, 2)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "ERROR unrecognized args: [\"extra\"]\n")
+}
+
+func (s *PositionalSuite) TestUsage(c *gc.C) {
+	positional := append(imageArgs[:2:2], cmd.Arg{Name: "region", Optional: true, Variadic: true})
+	help := cmdtesting.HelpText(&positionalCommand{positional: positional}, "add-image")
+	c.Assert(help, gc.Equals, `
+Usage: add-image <image-id> <series> [<region>...]
+
+Summary:
+add image metadata
+`[1:])
+}
+
+func (s *PositionalSuite) TestUsageArgsTakesPrecedence(c *gc.C) {
+	info := &cmd.Info{
+		Name:       "add-image",
+		Args:       "<image-id> <series> [<arch>]",
+		Positional: []cmd.Arg{{Name: "id"}},
+	}
+	c.Assert(string(info.Help(cmdtesting.NewFlagSet())), gc.Matches, "Usage: add-image <image-id> <series> \\[<arch>\\]\n(.|\n)*")
+}
@@ -0,0 +1,64 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// setProcessTitle is called by Context.SetStatus to update the process
+// title as seen by tools such as ps and top, and with an empty status to
+// restore the original title. It is a variable so that it can be replaced
+// in tests.
+var setProcessTitle = platformSetProcessTitle
+
+// processTitle records whether the process title has been changed from
+// its original, as it is shared by all of the commands in the process.
+var processTitle struct {
+	mu      sync.Mutex
+	changed bool
+}
+
+// SetStatus records what the command is currently doing, for the benefit of
+// operators watching long running commands. Where the platform allows, the
+// process title is updated to show the name of the running program followed
+// by the status, e.g. "juju-metadata: hashing 14/60" after
+//
+//	ctx.SetStatus("hashing %d/%d", i, len(tools))
+//
+// On Linux the title is the process name shown by ps and top, which the
+// kernel limits to 15 bytes, so little of the status may be shown. The
+// original title is restored by ClearStatus, which Main calls when the
+// command's Run returns.
+//
+// The status is also logged at debug level, prefixed with the name of the
+// running program.
+func (ctx *Context) SetStatus(format string, params ...interface{}) {
+	status := fmt.Sprintf(format, params...)
+	name := filepath.Base(os.Args[0])
+	logger.Debugf("%s: %s", name, status)
+	processTitle.mu.Lock()
+	defer processTitle.mu.Unlock()
+	processTitle.changed = true
+	if err := setProcessTitle(name, status); err != nil {
+		logger.Tracef("cannot set process title: %v", err)
+	}
+}
+
+// ClearStatus restores the original process title, if SetStatus has
+// changed it.
+func (ctx *Context) ClearStatus() {
+	processTitle.mu.Lock()
+	defer processTitle.mu.Unlock()
+	if !processTitle.changed {
+		return
+	}
+	processTitle.changed = false
+	if err := setProcessTitle(filepath.Base(os.Args[0]), ""); err != nil {
+		logger.Tracef("cannot restore process title: %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+//go:build linux
+
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sync"
+)
+
+const procComm = "/proc/self/comm"
+
+// originalComm holds the name of the process before it was first changed.
+var originalComm struct {
+	once sync.Once
+	comm []byte
+	err  error
+}
+
+// platformSetProcessTitle sets the name of the process by writing
+// "<name>: <status>" to /proc/self/comm, or restores the original name if
+// the status is empty. The kernel truncates the name to 15 bytes.
+func platformSetProcessTitle(name, status string) error {
+	originalComm.once.Do(func() {
+		comm, err := ioutil.ReadFile(procComm)
+		originalComm.comm, originalComm.err = bytes.TrimSuffix(comm, []byte("\n")), err
+	})
+	if originalComm.err != nil {
+		return originalComm.err
+	}
+	title := originalComm.comm
+	if status != "" {
+		title = []byte(name + ": " + status)
+	}
+	return ioutil.WriteFile(procComm, title, 0644)
+}
@@ -0,0 +1,15 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+//go:build !linux
+
+package cmd
+
+import (
+	"github.com/juju/errors"
+)
+
+// platformSetProcessTitle is not supported on this platform.
+func platformSetProcessTitle(name, status string) error {
+	return errors.NotSupportedf("setting the process title")
+}
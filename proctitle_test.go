@@ -0,0 +1,97 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"io/ioutil"
+	"os"
+	"runtime"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type ProcessTitleSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&ProcessTitleSuite{})
+
+func (s *ProcessTitleSuite) TestSetStatus(c *gc.C) {
+	var titles [][2]string
+	s.PatchValue(cmd.SetProcessTitle, func(name, status string) error {
+		titles = append(titles, [2]string{name, status})
+		return nil
+	})
+	s.PatchValue(&os.Args, []string{"/usr/bin/juju-metadata", "generate-tools"})
+
+	ctx := cmdtesting.Context(c)
+	ctx.SetStatus("hashing %d/%d", 14, 60)
+	ctx.ClearStatus()
+	c.Assert(titles, gc.DeepEquals, [][2]string{
+		{"juju-metadata", "hashing 14/60"},
+		{"juju-metadata", ""},
+	})
+	c.Assert(cmdtesting.Stdout(ctx), gc.Equals, "")
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "")
+}
+
+func (s *ProcessTitleSuite) TestSetStatusNotSupported(c *gc.C) {
+	s.PatchValue(cmd.SetProcessTitle, func(name, status string) error {
+		return errors.NotSupportedf("setting the process title")
+	})
+	ctx := cmdtesting.Context(c)
+	ctx.SetStatus("listing")
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "")
+}
+
+func (s *ProcessTitleSuite) TestClearStatusUnchanged(c *gc.C) {
+	s.PatchValue(cmd.SetProcessTitle, func(name, status string) error {
+		c.Errorf("process title set to %q %q", name, status)
+		return nil
+	})
+	cmdtesting.Context(c).ClearStatus()
+}
+
+func (s *ProcessTitleSuite) TestRestoredWhenRunReturns(c *gc.C) {
+	var titles [][2]string
+	s.PatchValue(cmd.SetProcessTitle, func(name, status string) error {
+		titles = append(titles, [2]string{name, status})
+		return nil
+	})
+	s.PatchValue(&os.Args, []string{"/usr/bin/juju-metadata"})
+	command := &TestCommand{Name: "verb", CustomRun: func(ctx *cmd.Context) error {
+		ctx.SetStatus("hashing")
+		return errors.New("BAM!")
+	}}
+	code := cmd.Main(command, cmdtesting.Context(c), []string{"--option", "x"})
+	c.Assert(code, gc.Equals, 1)
+	c.Assert(titles, gc.DeepEquals, [][2]string{
+		{"juju-metadata", "hashing"},
+		{"juju-metadata", ""},
+	})
+}
+
+func (s *ProcessTitleSuite) TestProcessName(c *gc.C) {
+	if runtime.GOOS != "linux" {
+		c.Skip("the process name is only set on linux")
+	}
+	original, err := ioutil.ReadFile("/proc/self/comm")
+	c.Assert(err, jc.ErrorIsNil)
+	s.PatchValue(&os.Args, []string{"/usr/bin/jm"})
+	ctx := cmdtesting.Context(c)
+	ctx.SetStatus("hashing 14/60")
+	comm, err := ioutil.ReadFile("/proc/self/comm")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(comm), gc.Equals, "jm: hashing 14/\n")
+	ctx.ClearStatus()
+	comm, err = ioutil.ReadFile("/proc/self/comm")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(comm), gc.Equals, string(original))
+}
@@ -0,0 +1,222 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+const (
+	// progressBarInterval is the minimum time between redraws of a
+	// progress bar on a terminal.
+	progressBarInterval = 100 * time.Millisecond
+
+	// progressLineInterval is the minimum time between progress lines
+	// when stderr is not a terminal.
+	progressLineInterval = 5 * time.Second
+
+	progressBarWidth = 30
+)
+
+var (
+	progressNow = time.Now
+	isTerminal  = isTerminalWriter
+)
+
+// isTerminalWriter reports whether w writes to a terminal that can handle
+// control characters.
+func isTerminalWriter(w io.Writer) bool {
+	f, ok := unwrapWriter(w).(*os.File)
+	if !ok || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// ProgressUnits describes what the progress of an operation is counted in.
+type ProgressUnits int
+
+const (
+	// ProgressCount counts items, such as files processed.
+	ProgressCount ProgressUnits = iota
+
+	// ProgressBytes counts bytes, such as those downloaded.
+	ProgressBytes
+)
+
+// Progress reports the progress of a long-running operation on stderr. On
+// a terminal it is shown as a progress bar that is redrawn as the
+// operation proceeds; otherwise a plain line is written when the operation
+// starts, at most every few seconds while it proceeds, and when it is done.
+// Nothing is shown in quiet mode (see Context.Quiet). It is safe to use a
+// Progress from multiple goroutines.
+type Progress struct {
+	ctx   *Context
+	label string
+	tty   bool
+
+	mu       sync.Mutex
+	units    ProgressUnits
+	total    int64
+	current  int64
+	lastDraw time.Time
+	done     bool
+}
+
+// StartProgress starts reporting the progress of an operation described
+// by label, e.g. "Fetching agent binaries". Done must be called when the
+// operation is finished.
+func (ctx *Context) StartProgress(label string) *Progress {
+	p := &Progress{
+		ctx:   ctx,
+		label: label,
+		tty:   isTerminal(ctx.Stderr),
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastDraw = progressNow()
+	if p.tty {
+		p.draw()
+	} else {
+		ctx.Infof("%s...", label)
+	}
+	return p
+}
+
+// SetUnits sets what the progress is counted in; it is ProgressCount if
+// SetUnits is not called.
+func (p *Progress) SetUnits(units ProgressUnits) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.units = units
+}
+
+// SetTotal sets the expected total, so that the proportion of the
+// operation done can be shown. If the total is not known, it need not be
+// set.
+func (p *Progress) SetTotal(total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total = total
+}
+
+// Add records that n more units of the operation are done.
+func (p *Progress) Add(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current += n
+	if p.done {
+		return
+	}
+	now := progressNow()
+	interval := progressLineInterval
+	if p.tty {
+		interval = progressBarInterval
+	}
+	if now.Sub(p.lastDraw) < interval {
+		return
+	}
+	p.lastDraw = now
+	if p.tty {
+		p.draw()
+	} else {
+		p.ctx.Infof("%s: %s", p.label, p.status())
+	}
+}
+
+// Write implements io.Writer by recording that len(data) units are done,
+// so that the progress of copying data can be reported with an
+// io.MultiWriter or io.TeeReader. It should be used with ProgressBytes.
+func (p *Progress) Write(data []byte) (int, error) {
+	p.Add(int64(len(data)))
+	return len(data), nil
+}
+
+// Done records that the operation is finished, and shows its final
+// progress. Any further progress is not shown.
+func (p *Progress) Done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done {
+		return
+	}
+	p.done = true
+	if p.tty {
+		p.draw()
+		p.writeTerminal("\n")
+	} else {
+		p.ctx.Infof("%s: done (%s)", p.label, p.format(p.current))
+	}
+}
+
+// draw redraws the progress bar.
+func (p *Progress) draw() {
+	line := p.label
+	if p.total > 0 {
+		filled := int(float64(progressBarWidth) * p.fraction())
+		bar := strings.Repeat("=", filled)
+		if filled < progressBarWidth {
+			bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+		}
+		line += " [" + bar + "]"
+	}
+	if p.current > 0 || p.total > 0 {
+		line += " " + p.status()
+	}
+	// Return to the start of the line and clear it before drawing.
+	p.writeTerminal("\r\x1b[K" + line)
+}
+
+func (p *Progress) writeTerminal(s string) {
+	if p.ctx.quiet {
+		return
+	}
+	writeMutex.Lock()
+	defer writeMutex.Unlock()
+	writeNow(p.ctx.Stderr, s)
+}
+
+// status describes the progress so far, e.g. "45% (12.3MiB/27.0MiB)".
+func (p *Progress) status() string {
+	if p.total <= 0 {
+		return p.format(p.current)
+	}
+	return fmt.Sprintf("%d%% (%s/%s)", int(100*p.fraction()), p.format(p.current), p.format(p.total))
+}
+
+func (p *Progress) fraction() float64 {
+	fraction := float64(p.current) / float64(p.total)
+	if fraction > 1 {
+		return 1
+	}
+	return fraction
+}
+
+// format formats n in the units of the progress.
+func (p *Progress) format(n int64) string {
+	if p.units != ProgressBytes {
+		return fmt.Sprint(n)
+	}
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	value := float64(n)
+	suffix := ""
+	for _, s := range []string{"KiB", "MiB", "GiB", "TiB"} {
+		value /= unit
+		suffix = s
+		if value < unit {
+			break
+		}
+	}
+	return fmt.Sprintf("%.1f%s", value, suffix)
+}
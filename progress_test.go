@@ -0,0 +1,100 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type ProgressSuite struct {
+	testing.LoggingCleanupSuite
+	ctx *cmd.Context
+	now time.Time
+}
+
+var _ = gc.Suite(&ProgressSuite{})
+
+func (s *ProgressSuite) SetUpTest(c *gc.C) {
+	s.LoggingCleanupSuite.SetUpTest(c)
+	s.ctx = cmdtesting.Context(c)
+	s.now = time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	s.PatchValue(cmd.ProgressNow, func() time.Time { return s.now })
+}
+
+func (s *ProgressSuite) advance(d time.Duration) {
+	s.now = s.now.Add(d)
+}
+
+func (s *ProgressSuite) TestPlainLines(c *gc.C) {
+	p := s.ctx.StartProgress("Fetching agent binaries")
+	p.SetUnits(cmd.ProgressBytes)
+	p.SetTotal(4 << 20)
+	p.Add(1 << 20)
+	s.advance(5 * time.Second)
+	p.Add(1 << 20)
+	s.advance(time.Second)
+	p.Add(1 << 20)
+	p.Add(1 << 20)
+	p.Done()
+	p.Done()
+	c.Assert(cmdtesting.Stderr(s.ctx), gc.Equals, `
+Fetching agent binaries...
+Fetching agent binaries: 50% (2.0MiB/4.0MiB)
+Fetching agent binaries: done (4.0MiB)
+`[1:])
+}
+
+func (s *ProgressSuite) TestPlainCountWithoutTotal(c *gc.C) {
+	p := s.ctx.StartProgress("Hashing")
+	s.advance(10 * time.Second)
+	p.Add(3)
+	p.Done()
+	c.Assert(cmdtesting.Stderr(s.ctx), gc.Equals, "Hashing...\nHashing: 3\nHashing: done (3)\n")
+}
+
+func (s *ProgressSuite) TestTerminalBar(c *gc.C) {
+	s.PatchValue(cmd.IsTerminal, func(io.Writer) bool { return true })
+	p := s.ctx.StartProgress("Fetching")
+	p.SetTotal(4)
+	p.Add(1)
+	s.advance(time.Second)
+	p.Add(1)
+	p.Done()
+	clear := "\r\x1b[K"
+	c.Assert(cmdtesting.Stderr(s.ctx), gc.Equals, strings.Join([]string{
+		clear + "Fetching",
+		clear + "Fetching [===============>              ] 50% (2/4)",
+		clear + "Fetching [===============>              ] 50% (2/4)\n",
+	}, ""))
+}
+
+func (s *ProgressSuite) TestWrite(c *gc.C) {
+	p := s.ctx.StartProgress("Copying")
+	p.SetUnits(cmd.ProgressBytes)
+	n, err := io.Copy(p, strings.NewReader(strings.Repeat("x", 1536)))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(n, gc.Equals, int64(1536))
+	p.Done()
+	c.Assert(cmdtesting.Stderr(s.ctx), gc.Equals, "Copying...\nCopying: done (1.5KiB)\n")
+}
+
+func (s *ProgressSuite) TestQuiet(c *gc.C) {
+	s.PatchValue(cmd.IsTerminal, func(io.Writer) bool { return true })
+	log := newLogWithFlags(c, "", "--quiet")
+	err := log.Start(s.ctx)
+	c.Assert(err, jc.ErrorIsNil)
+	p := s.ctx.StartProgress("Fetching")
+	p.Add(1)
+	p.Done()
+	c.Assert(cmdtesting.Stderr(s.ctx), gc.Equals, "")
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/juju/gnuflag"
+	"github.com/juju/loggo"
+)
+
+// RedactedText replaces sensitive values in log messages.
+const RedactedText = "[REDACTED]"
+
+// secrets holds the sensitive values registered with RegisterSecret.
+var secrets = &secretRegistry{values: make(map[string]bool)}
+
+type secretRegistry struct {
+	mu     sync.Mutex
+	values map[string]bool
+	// sorted holds the values, longest first, so that a secret containing
+	// another is replaced whole.
+	sorted []string
+}
+
+func (r *secretRegistry) add(value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if value == "" || r.values[value] {
+		return
+	}
+	r.values[value] = true
+	r.sorted = append(r.sorted, value)
+	sort.SliceStable(r.sorted, func(i, j int) bool {
+		return len(r.sorted[i]) > len(r.sorted[j])
+	})
+}
+
+func (r *secretRegistry) redact(s string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, value := range r.sorted {
+		s = strings.Replace(s, value, RedactedText, -1)
+	}
+	return s
+}
+
+func (r *secretRegistry) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values = make(map[string]bool)
+	r.sorted = nil
+}
+
+// RegisterSecret marks value as sensitive, such as an authentication token
+// or a passphrase read from a config file. Every occurrence of it is masked
+// in the messages written by the log writers set up by Log.Start, and in
+// the events written to the event log. Empty values are ignored.
+func RegisterSecret(value string) {
+	secrets.add(value)
+}
+
+// Redact returns s with every registered secret replaced by RedactedText.
+func Redact(s string) string {
+	return secrets.redact(s)
+}
+
+// MarkFlagsSecret marks the named flags of f as sensitive, so that any
+// value they are set to is registered with RegisterSecret. It must be
+// called after the flags are defined, and before f is parsed; it is
+// typically called at the end of a command's SetFlags method.
+func MarkFlagsSecret(f *gnuflag.FlagSet, names ...string) error {
+	for _, name := range names {
+		err := wrapFlagValue(f, name, func(value gnuflag.Value) gnuflag.Value {
+			if _, ok := value.(*secretValue); ok {
+				return value
+			}
+			return &secretValue{value}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// secretValue wraps a flag value, registering every value it is set to
+// as a secret.
+type secretValue struct {
+	gnuflag.Value
+}
+
+// Set implements gnuflag.Value.
+func (v *secretValue) Set(s string) error {
+	RegisterSecret(s)
+	return v.Value.Set(s)
+}
+
+// IsBoolFlag implements the optional gnuflag boolFlag interface, so that
+// wrapping a boolean flag does not change how it is parsed.
+func (v *secretValue) IsBoolFlag() bool {
+	return isBoolValue(v.Value)
+}
+
+func (v *secretValue) unwrap() gnuflag.Value {
+	return v.Value
+}
+
+// redactingWriter masks registered secrets in the messages passed to the
+// loggo writer it wraps.
+type redactingWriter struct {
+	writer loggo.Writer
+}
+
+// newRedactingWriter returns a loggo writer that masks registered secrets
+// before passing entries on to writer.
+func newRedactingWriter(writer loggo.Writer) loggo.Writer {
+	return &redactingWriter{writer}
+}
+
+// Write implements loggo.Writer.
+func (w *redactingWriter) Write(entry loggo.Entry) {
+	entry.Message = Redact(entry.Message)
+	w.writer.Write(entry)
+}
+
+// redactFields returns a copy of fields with registered secrets masked in
+// any string values.
+func redactFields(fields map[string]interface{}) map[string]interface{} {
+	if fields == nil {
+		return nil
+	}
+	result := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		if s, ok := value.(string); ok {
+			value = Redact(s)
+		}
+		result[key] = value
+	}
+	return result
+}
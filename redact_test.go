@@ -0,0 +1,105 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/juju/gnuflag"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type RedactSuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&RedactSuite{})
+
+func (s *RedactSuite) SetUpTest(c *gc.C) {
+	s.LoggingCleanupSuite.SetUpTest(c)
+	cmd.ResetSecrets()
+	s.AddCleanup(func(*gc.C) { cmd.ResetSecrets() })
+}
+
+func (s *RedactSuite) TestRedact(c *gc.C) {
+	c.Assert(cmd.Redact("token s3cr3t"), gc.Equals, "token s3cr3t")
+	cmd.RegisterSecret("s3cr3t")
+	cmd.RegisterSecret("s3cr3t-longer")
+	cmd.RegisterSecret("")
+	c.Assert(cmd.Redact("token s3cr3t, other s3cr3t-longer"), gc.Equals, "token [REDACTED], other [REDACTED]")
+}
+
+func (s *RedactSuite) TestMarkFlagsSecret(c *gc.C) {
+	var token string
+	var insecure bool
+	f := cmdtesting.NewFlagSet()
+	f.StringVar(&token, "token", "", "auth token")
+	f.BoolVar(&insecure, "insecure", false, "skip verification")
+	err := cmd.MarkFlagsSecret(f, "token", "insecure")
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = f.Parse(false, []string{"--token", "s3cr3t", "--insecure"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(token, gc.Equals, "s3cr3t")
+	c.Assert(insecure, jc.IsTrue)
+	c.Assert(cmd.Redact("using s3cr3t"), gc.Equals, "using [REDACTED]")
+}
+
+func (s *RedactSuite) TestMarkFlagsSecretUnknown(c *gc.C) {
+	f := gnuflag.NewFlagSetWithFlagKnownAs("test", gnuflag.ContinueOnError, "option")
+	err := cmd.MarkFlagsSecret(f, "token")
+	c.Assert(err, gc.ErrorMatches, `option "token" not found`)
+}
+
+func (s *RedactSuite) TestLogsRedacted(c *gc.C) {
+	l := &cmd.Log{Path: "foo.log", Config: "<root>=DEBUG", ShowLog: true}
+	ctx := cmdtesting.Context(c)
+	err := l.Start(ctx)
+	c.Assert(err, gc.IsNil)
+
+	cmd.RegisterSecret("s3cr3t")
+	logger.Debugf("authenticating with s3cr3t")
+
+	content, err := ioutil.ReadFile(filepath.Join(ctx.Dir, "foo.log"))
+	c.Assert(err, gc.IsNil)
+	c.Check(string(content), gc.Matches, `.* authenticating with \[REDACTED\]\n`)
+	c.Check(cmdtesting.Stderr(ctx), gc.Matches, `.* authenticating with \[REDACTED\]\n`)
+}
+
+func (s *RedactSuite) TestWarningsRedacted(c *gc.C) {
+	l := &cmd.Log{}
+	ctx := cmdtesting.Context(c)
+	err := l.Start(ctx)
+	c.Assert(err, gc.IsNil)
+
+	cmd.RegisterSecret("s3cr3t")
+	logger.Warningf("token s3cr3t expires soon")
+
+	c.Check(cmdtesting.Stderr(ctx), gc.Equals, "WARNING token [REDACTED] expires soon\n")
+}
+
+func (s *RedactSuite) TestEventLogRedacted(c *gc.C) {
+	l := &cmd.Log{EventLogDir: "logs"}
+	ctx := cmdtesting.Context(c)
+	err := l.Start(ctx)
+	c.Assert(err, gc.IsNil)
+
+	cmd.RegisterSecret("s3cr3t")
+	logger.Infof("using s3cr3t")
+	ctx.Event("login", map[string]interface{}{"token": "s3cr3t", "attempt": 1})
+
+	matches, err := filepath.Glob(filepath.Join(ctx.Dir, "logs", "*.json"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(matches, gc.HasLen, 1)
+	content, err := ioutil.ReadFile(matches[0])
+	c.Assert(err, gc.IsNil)
+	c.Check(string(content), gc.Not(gc.Matches), `(?s).*s3cr3t.*`)
+	c.Check(string(content), gc.Matches, `(?s).*"message":"using \[REDACTED\]".*"fields":{"attempt":1,"token":"\[REDACTED\]"}.*`)
+}
@@ -0,0 +1,60 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/juju/errors"
+	"github.com/juju/gnuflag"
+)
+
+// RootPolicy describes how a command behaves when it is run by the root
+// user. Files and directories created by a command run as root are often
+// not writable by later, unprivileged, runs.
+type RootPolicy int
+
+const (
+	// RootPermitted allows the command to be run as root. It is the
+	// default.
+	RootPermitted RootPolicy = iota
+
+	// RootWarn allows the command to be run as root, but writes a warning
+	// first, unless --allow-root is given.
+	RootWarn
+
+	// RootRefuse refuses to run the command as root, unless --allow-root
+	// is given.
+	RootRefuse
+)
+
+// geteuid returns the effective user id of the running process. It is a
+// variable so that it can be replaced in tests.
+var geteuid = os.Geteuid
+
+const allowRootDoc = "Run the command even when running as root"
+
+// addAllowRootFlag adds the --allow-root flag to f if the command described
+// by info does not permit running as root.
+func addAllowRootFlag(f *gnuflag.FlagSet, info *Info, target *bool) {
+	if info.RootPolicy != RootPermitted {
+		f.BoolVar(target, "allow-root", false, allowRootDoc)
+	}
+}
+
+// checkRoot applies the root policy of the command described by info, if
+// the process is running as root and allowRoot is not set. A nil info is
+// treated as permitting root.
+func checkRoot(ctx *Context, info *Info, allowRoot bool) error {
+	if info == nil || info.RootPolicy == RootPermitted || allowRoot || geteuid() != 0 {
+		return nil
+	}
+	switch info.RootPolicy {
+	case RootWarn:
+		ctx.Warningf("running %q as root; files it creates may not be usable by other users (use --allow-root to suppress this warning)", info.Name)
+	case RootRefuse:
+		return errors.Errorf("refusing to run %q as root, use --allow-root to override", info.Name)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/juju/loggo"
+	gitjujutesting "github.com/juju/testing"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type RootGuardSuite struct {
+	gitjujutesting.IsolationSuite
+
+	ctx  *cmd.Context
+	euid int
+}
+
+var _ = gc.Suite(&RootGuardSuite{})
+
+func (s *RootGuardSuite) SetUpTest(c *gc.C) {
+	s.IsolationSuite.SetUpTest(c)
+	s.ctx = cmdtesting.Context(c)
+	loggo.ReplaceDefaultWriter(cmd.NewWarningWriter(s.ctx.Stderr))
+	s.euid = 0
+	s.PatchValue(cmd.Geteuid, func() int { return s.euid })
+}
+
+type rootPolicyCommand struct {
+	cmd.CommandBase
+	policy cmd.RootPolicy
+	ran    bool
+}
+
+func (c *rootPolicyCommand) Info() *cmd.Info {
+	return &cmd.Info{Name: "generate", Purpose: "generate things", RootPolicy: c.policy}
+}
+
+func (c *rootPolicyCommand) Run(ctx *cmd.Context) error {
+	c.ran = true
+	return nil
+}
+
+func (s *RootGuardSuite) run(c *gc.C, command cmd.Command, args ...string) int {
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "jujutest"})
+	sc.Register(command)
+	return cmd.Main(sc, s.ctx, args)
+}
+
+func (s *RootGuardSuite) TestPermitted(c *gc.C) {
+	command := &rootPolicyCommand{}
+	code := s.run(c, command, "generate")
+	c.Assert(code, gc.Equals, 0)
+	c.Check(command.ran, gc.Equals, true)
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, "")
+}
+
+func (s *RootGuardSuite) TestPermittedHasNoAllowRootFlag(c *gc.C) {
+	code := s.run(c, &rootPolicyCommand{}, "generate", "--allow-root")
+	c.Assert(code, gc.Equals, 2)
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, `
+ERROR flag provided but not defined: --allow-root
+Usage: jujutest generate [flags]
+See "jujutest help generate" for details.
+`[1:])
+}
+
+func (s *RootGuardSuite) TestWarn(c *gc.C) {
+	command := &rootPolicyCommand{policy: cmd.RootWarn}
+	code := s.run(c, command, "generate")
+	c.Assert(code, gc.Equals, 0)
+	c.Check(command.ran, gc.Equals, true)
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Matches, `WARNING running "generate" as root; .*--allow-root.*\n`)
+}
+
+func (s *RootGuardSuite) TestRefuse(c *gc.C) {
+	command := &rootPolicyCommand{policy: cmd.RootRefuse}
+	code := s.run(c, command, "generate")
+	c.Assert(code, gc.Equals, 1)
+	c.Check(command.ran, gc.Equals, false)
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, "ERROR refusing to run \"generate\" as root, use --allow-root to override\n")
+}
+
+func (s *RootGuardSuite) TestAllowRoot(c *gc.C) {
+	for _, policy := range []cmd.RootPolicy{cmd.RootWarn, cmd.RootRefuse} {
+		s.ctx = cmdtesting.Context(c)
+		command := &rootPolicyCommand{policy: policy}
+		code := s.run(c, command, "generate", "--allow-root")
+		c.Assert(code, gc.Equals, 0)
+		c.Check(command.ran, gc.Equals, true)
+		c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, "")
+	}
+}
+
+func (s *RootGuardSuite) TestNotRoot(c *gc.C) {
+	s.euid = 1000
+	command := &rootPolicyCommand{policy: cmd.RootRefuse}
+	code := s.run(c, command, "generate")
+	c.Assert(code, gc.Equals, 0)
+	c.Check(command.ran, gc.Equals, true)
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, "")
+}
+
+func (s *RootGuardSuite) TestAllowRootInHelp(c *gc.C) {
+	code := s.run(c, &rootPolicyCommand{policy: cmd.RootRefuse}, "help", "generate")
+	c.Assert(code, gc.Equals, 0)
+	c.Check(cmdtesting.Stdout(s.ctx), gc.Matches, `(?s).*--allow-root  \(= false\)\n    Run the command even when running as root.*`)
+}
+
+func (s *RootGuardSuite) TestMain(c *gc.C) {
+	command := &rootPolicyCommand{policy: cmd.RootRefuse}
+	code := cmd.Main(command, s.ctx, nil)
+	c.Assert(code, gc.Equals, 1)
+	c.Check(command.ran, gc.Equals, false)
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, "ERROR refusing to run \"generate\" as root, use --allow-root to override\n")
+
+	s.ctx = cmdtesting.Context(c)
+	code = cmd.Main(command, s.ctx, []string{"--allow-root"})
+	c.Assert(code, gc.Equals, 0)
+	c.Check(command.ran, gc.Equals, true)
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, "")
+}
+
+func (s *RootGuardSuite) TestMainPermittedHasNoAllowRootFlag(c *gc.C) {
+	code := cmd.Main(&rootPolicyCommand{}, s.ctx, []string{"--allow-root"})
+	c.Assert(code, gc.Equals, 2)
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Matches, "(?s)ERROR flag provided but not defined: --allow-root\n.*")
+}
+
+func (s *RootGuardSuite) runNested(c *gc.C, command cmd.Command, args ...string) int {
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "top", Log: &cmd.Log{}})
+	mid := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "mid"})
+	sc.Register(mid)
+	mid.Register(command)
+	return cmd.Main(sc, s.ctx, args)
+}
+
+func (s *RootGuardSuite) TestNestedRefuse(c *gc.C) {
+	command := &rootPolicyCommand{policy: cmd.RootRefuse}
+	code := s.runNested(c, command, "--log-file", "run.log", "mid", "generate")
+	c.Assert(code, gc.Equals, 1)
+	c.Check(command.ran, gc.Equals, false)
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals,
+		"ERROR refusing to run \"generate\" as root, use --allow-root to override\n")
+	// Logging is not started for a command that refuses to run.
+	_, err := os.Stat(filepath.Join(s.ctx.Dir, "run.log"))
+	c.Check(os.IsNotExist(err), gc.Equals, true)
+}
+
+func (s *RootGuardSuite) TestNestedAllowRoot(c *gc.C) {
+	command := &rootPolicyCommand{policy: cmd.RootRefuse}
+	code := s.runNested(c, command, "mid", "generate", "--allow-root")
+	c.Assert(code, gc.Equals, 0)
+	c.Check(command.ran, gc.Equals, true)
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, "")
+}
+
+func (s *RootGuardSuite) TestNestedWarnOnce(c *gc.C) {
+	command := &rootPolicyCommand{policy: cmd.RootWarn}
+	code := s.runNested(c, command, "mid", "generate")
+	c.Assert(code, gc.Equals, 0)
+	c.Check(command.ran, gc.Equals, true)
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals,
+		"WARNING running \"generate\" as root; files it creates may not be usable by other users (use --allow-root to suppress this warning)\n")
+}
@@ -0,0 +1,56 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+// RunHook is called by a SuperCommand to run each of its subcommands, so
+// that code such as timing, telemetry, environment checks or locking can be
+// run before and after every subcommand without changing each command. The
+// hook must call run to run the subcommand, and would normally return the
+// error it returns, if any; it may instead return an error without calling
+// run to stop the subcommand from running. The error returned by the hook
+// is reported as if it were returned by the subcommand.
+//
+// When SuperCommands are nested, the hooks of each are called in turn, with
+// the subcommand at that level.
+type RunHook func(ctx *Context, call RunCall, run func() error) error
+
+// RunCall describes the running of a subcommand, for RunHooks.
+type RunCall struct {
+	// Name is the full name of the subcommand, including the names of
+	// the SuperCommands it was run by, such as "juju metadata generate".
+	Name string
+
+	// Command is the subcommand being run.
+	Command Command
+
+	// Args holds the positional arguments the subcommand was initialized
+	// with, after flags were parsed.
+	Args []string
+}
+
+// AddRunHook adds a hook to be called around the running of each
+// subcommand of c. Hooks are called in the order they are added, so that
+// the first hook added is the first to run and the last to return.
+func (c *SuperCommand) AddRunHook(hook RunHook) {
+	c.runHooks = append(c.runHooks, hook)
+}
+
+// runAction runs the selected subcommand, through any run hooks.
+func (c *SuperCommand) runAction(ctx *Context) error {
+	call := RunCall{
+		Name:    c.fullName() + " " + c.action.name,
+		Command: c.action.command,
+		Args:    c.actionArgs,
+	}
+	run := func() error {
+		return c.action.command.Run(ctx)
+	}
+	for i := len(c.runHooks) - 1; i >= 0; i-- {
+		hook, next := c.runHooks[i], run
+		run = func() error {
+			return hook(ctx, call, next)
+		}
+	}
+	return run()
+}
@@ -0,0 +1,100 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/juju/testing"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type RunHookSuite struct {
+	testing.LoggingSuite
+}
+
+var _ = gc.Suite(&RunHookSuite{})
+
+// recordingHook returns a hook that records the calls around it in calls.
+func recordingHook(name string, calls *[]string) cmd.RunHook {
+	return func(ctx *cmd.Context, call cmd.RunCall, run func() error) error {
+		*calls = append(*calls, fmt.Sprintf("%s before %s %v", name, call.Name, call.Args))
+		err := run()
+		*calls = append(*calls, fmt.Sprintf("%s after %v", name, err))
+		return err
+	}
+}
+
+func (*RunHookSuite) TestHooks(c *gc.C) {
+	var calls []string
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{
+		Name:     "juju-metadata",
+		RunHooks: []cmd.RunHook{recordingHook("first", &calls)},
+	})
+	sc.AddRunHook(recordingHook("second", &calls))
+	sc.Register(&simple{name: "generate"})
+	ctx := cmdtesting.Context(c)
+	code := cmd.Main(sc, ctx, []string{"generate", "a", "b"})
+	c.Assert(code, gc.Equals, 0)
+	c.Check(cmdtesting.Stdout(ctx), gc.Equals, "generate a, b\n")
+	c.Check(calls, gc.DeepEquals, []string{
+		"first before juju-metadata generate [a b]",
+		"second before juju-metadata generate [a b]",
+		"second after <nil>",
+		"first after <nil>",
+	})
+}
+
+func (*RunHookSuite) TestHookSeesError(c *gc.C) {
+	var calls []string
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "juju-metadata"})
+	sc.AddRunHook(recordingHook("hook", &calls))
+	sc.Register(&TestCommand{Name: "generate", CustomRun: func(*cmd.Context) error {
+		return errors.New("storage write failed")
+	}})
+	ctx := cmdtesting.Context(c)
+	code := cmd.Main(sc, ctx, []string{"generate"})
+	c.Assert(code, gc.Equals, 1)
+	c.Check(calls, gc.DeepEquals, []string{
+		"hook before juju-metadata generate []",
+		"hook after storage write failed",
+	})
+	c.Check(cmdtesting.Stderr(ctx), gc.Equals, "ERROR storage write failed\n")
+}
+
+func (*RunHookSuite) TestHookPreventsRun(c *gc.C) {
+	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "juju-metadata"})
+	sc.AddRunHook(func(ctx *cmd.Context, call cmd.RunCall, run func() error) error {
+		return cmd.UsageErrorf("%s needs a model", call.Name)
+	})
+	sc.Register(&simple{name: "generate"})
+	ctx := cmdtesting.Context(c)
+	code := cmd.Main(sc, ctx, []string{"generate"})
+	c.Assert(code, gc.Equals, cmd.ExitUsage)
+	c.Check(cmdtesting.Stdout(ctx), gc.Equals, "")
+	c.Check(cmdtesting.Stderr(ctx), gc.Equals, "ERROR juju-metadata generate needs a model\n")
+}
+
+func (*RunHookSuite) TestNested(c *gc.C) {
+	var calls []string
+	tool := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "tool"})
+	tool.AddRunHook(recordingHook("outer", &calls))
+	metadata := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "metadata"})
+	metadata.AddRunHook(recordingHook("inner", &calls))
+	tool.Register(metadata)
+	metadata.Register(&simple{name: "generate"})
+	ctx := cmdtesting.Context(c)
+	code := cmd.Main(tool, ctx, []string{"metadata", "generate", "x"})
+	c.Assert(code, gc.Equals, 0)
+	c.Check(calls, gc.DeepEquals, []string{
+		"outer before tool metadata [generate x]",
+		"inner before tool metadata generate [x]",
+		"inner after <nil>",
+		"outer after <nil>",
+	})
+}
@@ -0,0 +1,338 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/gnuflag"
+	"github.com/juju/utils/v3"
+)
+
+// SelfUpdateParams configures the command returned by
+// NewSelfUpdateCommand.
+type SelfUpdateParams struct {
+	// ReleaseURL is the URL of a JSON document describing the latest
+	// release, for example:
+	//
+	//	{
+	//	  "version": "2.9.2",
+	//	  "binaries": {
+	//	    "linux/amd64": {
+	//	      "url": "https://example.com/juju-metadata-2.9.2-linux-amd64",
+	//	      "sha256": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+	//	      "signature": "base64 encoded ed25519 signature of the binary"
+	//	    }
+	//	  }
+	//	}
+	//
+	// with a binary for each supported GOOS/GOARCH. A relative binary URL
+	// is resolved against ReleaseURL. The signature is only needed when
+	// PublicKey is set.
+	ReleaseURL string
+
+	// PublicKey, if set, is the key that release binaries must be signed
+	// with. Otherwise binaries are only checked against their hash.
+	PublicKey ed25519.PublicKey
+
+	// CurrentVersion is the version of the running executable. If it is
+	// empty, BuildVersion is used.
+	CurrentVersion string
+
+	// Executable is the path of the executable to replace. If it is
+	// empty, the path of the running executable is used.
+	Executable string
+
+	// HTTPClient is used to fetch the release and binaries. If it is nil,
+	// http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// releaseInfo is the document found at SelfUpdateParams.ReleaseURL.
+type releaseInfo struct {
+	Version  string                   `json:"version"`
+	Binaries map[string]releaseBinary `json:"binaries"`
+}
+
+type releaseBinary struct {
+	URL       string `json:"url"`
+	SHA256    string `json:"sha256"`
+	Signature string `json:"signature"`
+}
+
+// NewSelfUpdateCommand returns an "upgrade" command that replaces the
+// running executable with the latest release, for tools installed
+// outside of package managers. Commands opt in by registering it with
+// their SuperCommand. The new binary is checked against the SHA-256 hash
+// published with the release, and against its detached signature if
+// params.PublicKey is set, before it atomically replaces the executable.
+// Without a public key the check is only as trustworthy as the release
+// document, which should then be served over HTTPS.
+func NewSelfUpdateCommand(params SelfUpdateParams) Command {
+	return &selfUpdateCommand{params: params}
+}
+
+type selfUpdateCommand struct {
+	CommandBase
+	params    SelfUpdateParams
+	checkOnly bool
+	force     bool
+}
+
+func (c *selfUpdateCommand) Info() *Info {
+	return &Info{
+		Name:    "upgrade",
+		Purpose: "Upgrade to the latest release.",
+		Doc: `
+The latest release is downloaded, verified and installed in place of
+the running executable. Use --check to only report whether a newer
+release is available.
+
+If either version cannot be compared, such as when the current version
+is unknown, --force is needed to upgrade.`,
+	}
+}
+
+func (c *selfUpdateCommand) SetFlags(f *gnuflag.FlagSet) {
+	f.BoolVar(&c.checkOnly, "check", false, "Only check whether a newer release is available")
+	f.BoolVar(&c.force, "force", false, "Upgrade even if the versions cannot be compared")
+}
+
+func (c *selfUpdateCommand) Init(args []string) error {
+	if c.params.ReleaseURL == "" {
+		return errors.New("no release URL configured")
+	}
+	if c.params.PublicKey != nil && len(c.params.PublicKey) != ed25519.PublicKeySize {
+		return errors.New("invalid public key configured")
+	}
+	return CheckEmpty(args)
+}
+
+func (c *selfUpdateCommand) Run(ctx *Context) error {
+	current := c.params.CurrentVersion
+	if current == "" {
+		current = BuildVersion
+	}
+	release, err := c.fetchRelease(ctx)
+	if err != nil {
+		return err
+	}
+	newer, comparable := compareVersions(release.Version, current)
+	if comparable && newer <= 0 {
+		ctx.Infof("Already up to date (version %s).", current)
+		return nil
+	}
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	binary, ok := release.Binaries[platform]
+	if !ok {
+		return errors.NotFoundf("release %s for %s", release.Version, platform)
+	}
+	if c.checkOnly {
+		ctx.Infof("Version %s is available (current version %s).", release.Version, displayVersion(current))
+		return nil
+	}
+	if !comparable && !c.force {
+		return errors.Errorf("cannot compare version %s with current version %s; use --force to upgrade anyway",
+			release.Version, displayVersion(current))
+	}
+	executable := c.params.Executable
+	if executable == "" {
+		if executable, err = os.Executable(); err != nil {
+			return errors.Annotate(err, "finding executable")
+		}
+		if executable, err = filepath.EvalSymlinks(executable); err != nil {
+			return errors.Annotate(err, "finding executable")
+		}
+	}
+	if err := c.install(ctx, binary, executable); err != nil {
+		return err
+	}
+	ctx.Infof("Upgraded from version %s to %s.", displayVersion(current), release.Version)
+	return nil
+}
+
+// fetchRelease fetches the description of the latest release.
+func (c *selfUpdateCommand) fetchRelease(ctx *Context) (*releaseInfo, error) {
+	body, err := c.get(ctx, c.params.ReleaseURL)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	var release releaseInfo
+	if err := json.NewDecoder(body).Decode(&release); err != nil {
+		return nil, errors.Annotatef(err, "invalid release information from %q", c.params.ReleaseURL)
+	}
+	if release.Version == "" {
+		return nil, errors.Errorf("invalid release information from %q: no version", c.params.ReleaseURL)
+	}
+	return &release, nil
+}
+
+// install downloads binary next to executable, verifies it and then
+// replaces executable with it.
+func (c *selfUpdateCommand) install(ctx *Context, binary releaseBinary, executable string) error {
+	want, err := hex.DecodeString(binary.SHA256)
+	if err != nil || len(want) != sha256.Size {
+		return errors.Errorf("invalid SHA-256 hash %q for %q", binary.SHA256, binary.URL)
+	}
+	var signature []byte
+	if c.params.PublicKey != nil {
+		if binary.Signature == "" {
+			return errors.Errorf("no signature for %q", binary.URL)
+		}
+		signature, err = base64.StdEncoding.DecodeString(binary.Signature)
+		if err != nil || len(signature) != ed25519.SignatureSize {
+			return errors.Errorf("invalid signature %q for %q", binary.Signature, binary.URL)
+		}
+	}
+	info, err := os.Stat(executable)
+	if err != nil {
+		return errors.Annotate(err, "finding executable")
+	}
+	binaryURL, err := resolveURL(c.params.ReleaseURL, binary.URL)
+	if err != nil {
+		return err
+	}
+	body, err := c.get(ctx, binaryURL)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	tmp, err := ioutil.TempFile(filepath.Dir(executable), tempFilePrefix+filepath.Base(executable)+".*"+tempFileSuffix)
+	if err != nil {
+		return errors.Annotatef(err, "writing %q", executable)
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	writers := []io.Writer{tmp, hash}
+	// The signature covers the whole binary, so it is kept to be verified.
+	var content bytes.Buffer
+	if signature != nil {
+		writers = append(writers, &content)
+	}
+	_, err = io.Copy(io.MultiWriter(writers...), body)
+	if err == nil {
+		err = tmp.Chmod(info.Mode().Perm())
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Annotatef(err, "downloading %q", binaryURL)
+	}
+	if got := hash.Sum(nil); !bytes.Equal(got, want) {
+		return errors.Errorf("downloaded binary has SHA-256 hash %x, expected %s", got, binary.SHA256)
+	}
+	if signature != nil && !ed25519.Verify(c.params.PublicKey, content.Bytes(), signature) {
+		return errors.New("downloaded binary does not match its signature")
+	}
+	if err := utils.ReplaceFile(tmp.Name(), executable); err != nil {
+		return errors.Annotatef(err, "replacing %q", executable)
+	}
+	return nil
+}
+
+// get fetches rawURL, returning an error unless the response is
+// successful.
+func (c *selfUpdateCommand) get(ctx *Context, rawURL string) (io.ReadCloser, error) {
+	client := c.params.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, NewRemoteError(errors.Annotatef(err, "fetching %q", rawURL))
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, NewRemoteError(errors.Errorf("fetching %q: %s", rawURL, resp.Status))
+	}
+	return resp.Body, nil
+}
+
+// resolveURL resolves ref relative to base.
+func resolveURL(base, ref string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", errors.Annotate(err, "invalid release URL")
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return "", errors.Annotate(err, "invalid binary URL")
+	}
+	return baseURL.ResolveReference(refURL).String(), nil
+}
+
+// compareVersions compares two dotted version numbers such as "2.9.10",
+// returning a negative number if a is earlier than b, zero if they are
+// the same and a positive number if a is later. The versions are not
+// comparable, and false is returned, unless both are made up of numbers.
+func compareVersions(a, b string) (int, bool) {
+	aParts, ok := parseVersion(a)
+	if !ok {
+		return 0, false
+	}
+	bParts, ok := parseVersion(b)
+	if !ok {
+		return 0, false
+	}
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aPart, bPart int
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+		if aPart != bPart {
+			return aPart - bPart, true
+		}
+	}
+	return 0, true
+}
+
+// parseVersion returns the numbers in a dotted version number, or false
+// if it is not one.
+func parseVersion(version string) ([]int, bool) {
+	if version == "" {
+		return nil, false
+	}
+	var parts []int
+	for _, part := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || strings.HasPrefix(part, "+") {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
+
+// displayVersion returns version for display, if it is known.
+func displayVersion(version string) string {
+	if version == "" {
+		return "unknown"
+	}
+	return version
+}
@@ -0,0 +1,208 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type SelfUpdateSuite struct {
+	testing.LoggingCleanupSuite
+	server     *httptest.Server
+	release    string
+	binary     string
+	executable string
+	publicKey  ed25519.PublicKey
+}
+
+var _ = gc.Suite(&SelfUpdateSuite{})
+
+const newBinary = "#!/bin/sh\necho new\n"
+
+func (s *SelfUpdateSuite) SetUpTest(c *gc.C) {
+	s.LoggingCleanupSuite.SetUpTest(c)
+	s.binary = newBinary
+	s.publicKey = nil
+	s.release = s.releaseJSON("2.9.2", sha256Hex(newBinary))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/release.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, s.release)
+	})
+	mux.HandleFunc("/bin/tool", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, s.binary)
+	})
+	s.server = httptest.NewServer(mux)
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+
+	s.executable = filepath.Join(c.MkDir(), "tool")
+	err := ioutil.WriteFile(s.executable, []byte("#!/bin/sh\necho old\n"), 0755)
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *SelfUpdateSuite) releaseJSON(version, hash string) string {
+	return fmt.Sprintf(`{"version": %q, "binaries": {"%s/%s": {"url": "bin/tool", "sha256": %q}}}`,
+		version, runtime.GOOS, runtime.GOARCH, hash)
+}
+
+// signedReleaseJSON returns a release signed with a new key, which the
+// command is configured to trust.
+func (s *SelfUpdateSuite) signedReleaseJSON(c *gc.C, version, content string) string {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, jc.ErrorIsNil)
+	s.publicKey = publicKey
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte(content)))
+	return fmt.Sprintf(`{"version": %q, "binaries": {"%s/%s": {"url": "bin/tool", "sha256": %q, "signature": %q}}}`,
+		version, runtime.GOOS, runtime.GOARCH, sha256Hex(content), signature)
+}
+
+func (s *SelfUpdateSuite) run(c *gc.C, current string, args ...string) (*cmd.Context, error) {
+	command := cmd.NewSelfUpdateCommand(cmd.SelfUpdateParams{
+		ReleaseURL:     s.server.URL + "/release.json",
+		CurrentVersion: current,
+		Executable:     s.executable,
+		PublicKey:      s.publicKey,
+	})
+	return cmdtesting.RunCommand(c, command, args...)
+}
+
+func (s *SelfUpdateSuite) checkExecutable(c *gc.C, content string) {
+	data, err := ioutil.ReadFile(s.executable)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(data), gc.Equals, content)
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(s.executable)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(info.Mode().Perm(), gc.Equals, os.FileMode(0755))
+	}
+	entries, err := ioutil.ReadDir(filepath.Dir(s.executable))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(entries, gc.HasLen, 1)
+}
+
+func (s *SelfUpdateSuite) TestUpgrade(c *gc.C) {
+	ctx, err := s.run(c, "2.9.1")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(cmdtesting.Stderr(ctx), gc.Equals, "Upgraded from version 2.9.1 to 2.9.2.\n")
+	s.checkExecutable(c, newBinary)
+}
+
+func (s *SelfUpdateSuite) TestUpToDate(c *gc.C) {
+	for _, current := range []string{"2.9.2", "2.9.10", "3.0"} {
+		ctx, err := s.run(c, current)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(cmdtesting.Stderr(ctx), gc.Equals, "Already up to date (version "+current+").\n")
+	}
+	s.checkExecutable(c, "#!/bin/sh\necho old\n")
+}
+
+func (s *SelfUpdateSuite) TestCheck(c *gc.C) {
+	ctx, err := s.run(c, "2.9.1", "--check")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(cmdtesting.Stderr(ctx), gc.Equals, "Version 2.9.2 is available (current version 2.9.1).\n")
+	s.checkExecutable(c, "#!/bin/sh\necho old\n")
+}
+
+func (s *SelfUpdateSuite) TestUnknownVersion(c *gc.C) {
+	s.PatchValue(&cmd.BuildVersion, "")
+	for _, current := range []string{"", "2.9-dev"} {
+		_, err := s.run(c, current)
+		c.Assert(err, gc.ErrorMatches, `cannot compare version 2.9.2 with current version .*; use --force to upgrade anyway`)
+		s.checkExecutable(c, "#!/bin/sh\necho old\n")
+	}
+
+	ctx, err := s.run(c, "", "--force")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(cmdtesting.Stderr(ctx), gc.Equals, "Upgraded from version unknown to 2.9.2.\n")
+	s.checkExecutable(c, newBinary)
+}
+
+func (s *SelfUpdateSuite) TestSigned(c *gc.C) {
+	s.release = s.signedReleaseJSON(c, "2.9.2", newBinary)
+	_, err := s.run(c, "2.9.1")
+	c.Assert(err, jc.ErrorIsNil)
+	s.checkExecutable(c, newBinary)
+}
+
+func (s *SelfUpdateSuite) TestSignatureMismatch(c *gc.C) {
+	// The hash matches the binary, but the binary was not signed.
+	tampered := "#!/bin/sh\necho tampered\n"
+	s.release = s.signedReleaseJSON(c, "2.9.2", newBinary)
+	s.release = strings.Replace(s.release, sha256Hex(newBinary), sha256Hex(tampered), 1)
+	s.binary = tampered
+	_, err := s.run(c, "2.9.1")
+	c.Assert(err, gc.ErrorMatches, "downloaded binary does not match its signature")
+	s.checkExecutable(c, "#!/bin/sh\necho old\n")
+}
+
+func (s *SelfUpdateSuite) TestUnsigned(c *gc.C) {
+	s.signedReleaseJSON(c, "2.9.2", newBinary)
+	_, err := s.run(c, "2.9.1")
+	c.Assert(err, gc.ErrorMatches, `no signature for "bin/tool"`)
+	s.checkExecutable(c, "#!/bin/sh\necho old\n")
+}
+
+func (s *SelfUpdateSuite) TestHashMismatch(c *gc.C) {
+	s.binary = "#!/bin/sh\necho tampered\n"
+	_, err := s.run(c, "2.9.1")
+	c.Assert(err, gc.ErrorMatches, `downloaded binary has SHA-256 hash [0-9a-f]+, expected `+sha256Hex(newBinary))
+	s.checkExecutable(c, "#!/bin/sh\necho old\n")
+}
+
+func (s *SelfUpdateSuite) TestInvalidHash(c *gc.C) {
+	s.release = s.releaseJSON("2.9.2", "abc")
+	_, err := s.run(c, "2.9.1")
+	c.Assert(err, gc.ErrorMatches, `invalid SHA-256 hash "abc" for "bin/tool"`)
+}
+
+func (s *SelfUpdateSuite) TestNoBinaryForPlatform(c *gc.C) {
+	s.release = `{"version": "2.9.2", "binaries": {}}`
+	_, err := s.run(c, "2.9.1")
+	c.Assert(err, gc.ErrorMatches, `release 2.9.2 for .* not found`)
+	c.Assert(cmd.ExitCode(err), gc.Equals, cmd.ExitNotFound)
+}
+
+func (s *SelfUpdateSuite) TestFetchFailure(c *gc.C) {
+	command := cmd.NewSelfUpdateCommand(cmd.SelfUpdateParams{
+		ReleaseURL: s.server.URL + "/missing.json",
+		Executable: s.executable,
+	})
+	_, err := cmdtesting.RunCommand(c, command)
+	c.Assert(err, gc.ErrorMatches, `fetching ".*/missing.json": 404 Not Found`)
+	c.Assert(cmd.ExitCode(err), gc.Equals, cmd.ExitRemote)
+}
+
+func (s *SelfUpdateSuite) TestInvalidRelease(c *gc.C) {
+	s.release = `{}`
+	_, err := s.run(c, "2.9.1")
+	c.Assert(err, gc.ErrorMatches, `invalid release information from ".*": no version`)
+}
+
+func (s *SelfUpdateSuite) TestNoReleaseURL(c *gc.C) {
+	err := cmdtesting.InitCommand(cmd.NewSelfUpdateCommand(cmd.SelfUpdateParams{}), nil)
+	c.Assert(err, gc.ErrorMatches, "no release URL configured")
+}
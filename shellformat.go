@@ -0,0 +1,107 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// FormatShell writes out value as POSIX shell variable assignments, one
+// per line, which a script can evaluate:
+//
+//	eval "$(juju-metadata tools info --format=shell)"
+//
+// value must be a map or struct; each of its fields, as named when
+// marshalled as json, becomes a variable. Characters that are not allowed
+// in shell variable names are replaced with underscores, so "image-id"
+// becomes image_id. Every value is single quoted, so that evaluating the
+// output never runs anything; values that are themselves maps or lists
+// are assigned as json.
+func FormatShell(writer io.Writer, value interface{}) error {
+	return formatShellVariables(writer, value, func(name, value string) string {
+		return name + "='" + strings.Replace(value, "'", `'\''`, -1) + "'"
+	})
+}
+
+// FormatPowerShell writes out value as PowerShell variable assignments, as
+// FormatShell does for POSIX shells. The output can be evaluated with
+// Invoke-Expression.
+func FormatPowerShell(writer io.Writer, value interface{}) error {
+	return formatShellVariables(writer, value, func(name, value string) string {
+		return "$" + name + " = '" + powerShellQuotes.Replace(value) + "'"
+	})
+}
+
+// powerShellQuotes escapes the characters that PowerShell treats as single
+// quotes, which include the typographic ones, by doubling them.
+var powerShellQuotes = strings.NewReplacer(
+	"'", "''",
+	"\u2018", "\u2018\u2018",
+	"\u2019", "\u2019\u2019",
+	"\u201a", "\u201a\u201a",
+	"\u201b", "\u201b\u201b",
+)
+
+// formatShellVariables writes out the fields of value, sorted by name,
+// with assign rendering each assignment.
+func formatShellVariables(writer io.Writer, value interface{}, assign func(name, value string) string) error {
+	if value == nil {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return errors.Errorf("cannot format %T as shell variables: expected a map or struct", value)
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintln(&buf, assign(shellVariableName(name), shellValue(fields[name])))
+	}
+	_, err = writer.Write(buf.Bytes())
+	return err
+}
+
+// shellValue returns the text of a json value: strings are unquoted,
+// null is empty and any other value is left as json.
+func shellValue(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	if string(raw) == "null" {
+		return ""
+	}
+	return string(raw)
+}
+
+// shellVariableName returns name with any characters that are not valid
+// in a shell variable name replaced with underscores. Since a variable name
+// cannot start with a digit, such names are prefixed with an underscore.
+func shellVariableName(name string) string {
+	result := []byte(name)
+	for i, c := range result {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			result[i] = '_'
+		}
+	}
+	if len(result) == 0 || result[0] >= '0' && result[0] <= '9' {
+		return "_" + string(result)
+	}
+	return string(result)
+}
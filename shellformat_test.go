@@ -0,0 +1,145 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"bytes"
+	"os/exec"
+	"runtime"
+
+	"github.com/juju/gnuflag"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type ShellFormatSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&ShellFormatSuite{})
+
+type toolInfo struct {
+	Version string            `json:"version"`
+	Path    string            `json:"path"`
+	ImageID string            `json:"image-id,omitempty"`
+	Size    int               `json:"size"`
+	Signed  bool              `json:"signed"`
+	Series  []string          `json:"series"`
+	Labels  map[string]string `json:"labels"`
+}
+
+var shellTestInfo = toolInfo{
+	Version: "2.9.2",
+	Path:    "/var/lib/it's here/$(rm -rf ~)",
+	ImageID: "ami-1234",
+	Size:    1024,
+	Signed:  true,
+	Series:  []string{"focal", "jammy"},
+}
+
+func (s *ShellFormatSuite) TestFormatShell(c *gc.C) {
+	var buf bytes.Buffer
+	err := cmd.FormatShell(&buf, shellTestInfo)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(buf.String(), gc.Equals, `
+image_id='ami-1234'
+labels=''
+path='/var/lib/it'\''s here/$(rm -rf ~)'
+series='["focal","jammy"]'
+signed='true'
+size='1024'
+version='2.9.2'
+`[1:])
+}
+
+func (s *ShellFormatSuite) TestFormatPowerShell(c *gc.C) {
+	var buf bytes.Buffer
+	err := cmd.FormatPowerShell(&buf, shellTestInfo)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(buf.String(), gc.Equals, `
+$image_id = 'ami-1234'
+$labels = ''
+$path = '/var/lib/it''s here/$(rm -rf ~)'
+$series = '["focal","jammy"]'
+$signed = 'true'
+$size = '1024'
+$version = '2.9.2'
+`[1:])
+}
+
+func (s *ShellFormatSuite) TestFormatPowerShellQuotes(c *gc.C) {
+	var buf bytes.Buffer
+	err := cmd.FormatPowerShell(&buf, map[string]string{"path": "it's \u2018a\u2019 \u201ab\u201b"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(buf.String(), gc.Equals, "$path = 'it''s \u2018\u2018a\u2019\u2019 \u201a\u201ab\u201b\u201b'\n")
+}
+
+func (s *ShellFormatSuite) TestFormatShellVariableNames(c *gc.C) {
+	var buf bytes.Buffer
+	err := cmd.FormatShell(&buf, map[string]string{
+		"endpoint.url": "https://example.com",
+		"2fa":          "on",
+		"":             "empty",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(buf.String(), gc.Equals, `
+_='empty'
+_2fa='on'
+endpoint_url='https://example.com'
+`[1:])
+}
+
+func (s *ShellFormatSuite) TestFormatShellNil(c *gc.C) {
+	var buf bytes.Buffer
+	err := cmd.FormatShell(&buf, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(buf.String(), gc.Equals, "")
+}
+
+func (s *ShellFormatSuite) TestFormatShellNotAMap(c *gc.C) {
+	var buf bytes.Buffer
+	err := cmd.FormatShell(&buf, "2.9.2")
+	c.Assert(err, gc.ErrorMatches, "cannot format string as shell variables: expected a map or struct")
+	err = cmd.FormatPowerShell(&buf, []string{"focal"})
+	c.Assert(err, gc.ErrorMatches, `cannot format \[\]string as shell variables: expected a map or struct`)
+	c.Assert(buf.String(), gc.Equals, "")
+}
+
+func (s *ShellFormatSuite) TestEval(c *gc.C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("no POSIX shell on windows")
+	}
+	var buf bytes.Buffer
+	err := cmd.FormatShell(&buf, shellTestInfo)
+	c.Assert(err, jc.ErrorIsNil)
+	out, err := exec.Command("/bin/sh", "-c", `eval "$1"; printf '%s|%s' "$path" "$version"`, "sh", buf.String()).CombinedOutput()
+	c.Assert(err, jc.ErrorIsNil, gc.Commentf("%s", out))
+	c.Assert(string(out), gc.Equals, shellTestInfo.Path+"|2.9.2")
+}
+
+// shellOutputCommand is an OutputCommand that opts in to the shell formats.
+type shellOutputCommand struct {
+	OutputCommand
+}
+
+func (c *shellOutputCommand) SetFlags(f *gnuflag.FlagSet) {
+	c.out.AddFlags(f, "smart", cmd.ShellFormatters.Formatters())
+}
+
+func (s *ShellFormatSuite) TestOutputFlag(c *gc.C) {
+	value := map[string]interface{}{"version": "2.9.2"}
+	ctx := cmdtesting.Context(c)
+	code := cmd.Main(&shellOutputCommand{OutputCommand{value: value}}, ctx, []string{"--format", "shell"})
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(cmdtesting.Stdout(ctx), gc.Equals, "version='2.9.2'\n")
+
+	ctx = cmdtesting.Context(c)
+	code = cmd.Main(&OutputCommand{value: value}, ctx, []string{"--format", "shell"})
+	c.Assert(code, gc.Equals, 2)
+	c.Assert(cmdtesting.Stderr(ctx), gc.Matches, `(?s)ERROR invalid value "shell" for flag --format: unknown format "shell".*`)
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Summary accumulates the outcome of each item processed by a batch
+// command, so that all such commands report their results the same way.
+// It is safe for concurrent use. A Summary may be included in a command's
+// formatted output, where it is rendered as its counts.
+type Summary struct {
+	mu        sync.Mutex
+	succeeded int
+	skipped   int
+	failed    int
+}
+
+// SummaryCounts holds the counts recorded by a Summary.
+type SummaryCounts struct {
+	Processed int `yaml:"processed" json:"processed"`
+	Succeeded int `yaml:"succeeded" json:"succeeded"`
+	Skipped   int `yaml:"skipped" json:"skipped"`
+	Failed    int `yaml:"failed" json:"failed"`
+}
+
+// Succeed records that an item was processed successfully.
+func (s *Summary) Succeed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.succeeded++
+}
+
+// Skip records that an item was skipped, e.g. because it was up to date.
+func (s *Summary) Skip() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.skipped++
+}
+
+// Fail records that processing an item failed.
+func (s *Summary) Fail() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed++
+}
+
+// Record records the outcome of processing an item: a failure if err is
+// not nil, otherwise a success. It returns err.
+func (s *Summary) Record(err error) error {
+	if err != nil {
+		s.Fail()
+	} else {
+		s.Succeed()
+	}
+	return err
+}
+
+// Counts returns the counts recorded so far.
+func (s *Summary) Counts() SummaryCounts {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SummaryCounts{
+		Processed: s.succeeded + s.skipped + s.failed,
+		Succeeded: s.succeeded,
+		Skipped:   s.skipped,
+		Failed:    s.failed,
+	}
+}
+
+// String returns the summary line written by Context.WriteSummary, e.g.
+// "12 processed: 10 succeeded, 1 skipped, 1 failed".
+func (s *Summary) String() string {
+	counts := s.Counts()
+	return fmt.Sprintf("%d processed: %d succeeded, %d skipped, %d failed",
+		counts.Processed, counts.Succeeded, counts.Skipped, counts.Failed)
+}
+
+// Err returns an error if any item failed, and nil otherwise. Batch
+// commands can return it from Run, so that they exit with a non-zero
+// status when some of their work failed.
+func (s *Summary) Err() error {
+	counts := s.Counts()
+	if counts.Failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d items failed", counts.Failed, counts.Processed)
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (s *Summary) MarshalYAML() (interface{}, error) {
+	return s.Counts(), nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s *Summary) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Counts())
+}
+
+// WriteSummary writes the summary line for s to stderr, as Infof does, and
+// records the counts as a "summary" event (see Context.Event).
+func (ctx *Context) WriteSummary(s *Summary) {
+	counts := s.Counts()
+	ctx.Infof("%s", s)
+	ctx.Event("summary", map[string]interface{}{
+		"processed": counts.Processed,
+		"succeeded": counts.Succeeded,
+		"skipped":   counts.Skipped,
+		"failed":    counts.Failed,
+	})
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type SummarySuite struct {
+	testing.LoggingCleanupSuite
+}
+
+var _ = gc.Suite(&SummarySuite{})
+
+func (s *SummarySuite) newSummary() *cmd.Summary {
+	summary := &cmd.Summary{}
+	for i := 0; i < 3; i++ {
+		summary.Succeed()
+	}
+	summary.Skip()
+	summary.Record(errors.New("boom"))
+	summary.Record(nil)
+	return summary
+}
+
+func (s *SummarySuite) TestCounts(c *gc.C) {
+	summary := s.newSummary()
+	c.Assert(summary.Counts(), jc.DeepEquals, cmd.SummaryCounts{
+		Processed: 6,
+		Succeeded: 4,
+		Skipped:   1,
+		Failed:    1,
+	})
+	c.Assert(summary.String(), gc.Equals, "6 processed: 4 succeeded, 1 skipped, 1 failed")
+	c.Assert(summary.Err(), gc.ErrorMatches, "1 of 6 items failed")
+}
+
+func (s *SummarySuite) TestNoFailures(c *gc.C) {
+	summary := &cmd.Summary{}
+	summary.Succeed()
+	c.Assert(summary.Err(), jc.ErrorIsNil)
+}
+
+func (s *SummarySuite) TestConcurrent(c *gc.C) {
+	summary := &cmd.Summary{}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			summary.Succeed()
+		}()
+	}
+	wg.Wait()
+	c.Assert(summary.Counts().Succeeded, gc.Equals, 20)
+}
+
+func (s *SummarySuite) TestFormatted(c *gc.C) {
+	result := struct {
+		Summary *cmd.Summary `yaml:"summary" json:"summary"`
+	}{s.newSummary()}
+
+	var buf bytes.Buffer
+	err := cmd.FormatJson(&buf, result)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(buf.String(), gc.Equals, `{"summary":{"processed":6,"succeeded":4,"skipped":1,"failed":1}}`+"\n")
+
+	buf.Reset()
+	err = cmd.FormatYaml(&buf, result)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(buf.String(), gc.Equals, `
+summary:
+  processed: 6
+  succeeded: 4
+  skipped: 1
+  failed: 1
+`[1:])
+}
+
+func (s *SummarySuite) TestWriteSummary(c *gc.C) {
+	ctx := cmdtesting.Context(c)
+	ctx.WriteSummary(s.newSummary())
+	c.Assert(cmdtesting.Stderr(ctx), gc.Equals, "6 processed: 4 succeeded, 1 skipped, 1 failed\n")
+	c.Assert(cmdtesting.Stdout(ctx), gc.Equals, "")
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/juju/errors"
@@ -22,6 +23,9 @@ type topic struct {
 	// Help aliases are not output when topics are listed, but are used
 	// to search for the help topic
 	alias bool
+	// write, if set, writes the topic in place of long, for topics that
+	// depend on the context the help is shown in.
+	write func(ctx *Context) error
 }
 
 // UnrecognizedCommand defines an error that specifies when a command is not
@@ -55,16 +59,33 @@ type MissingCallback func(ctx *Context, subcommand string, args []string) error
 // SuperCommandParams provides a way to have default parameter to the
 // `NewSuperCommand` call.
 type SuperCommandParams struct {
-	// UsagePrefix should be set when the SuperCommand is
+	// UsagePrefix may be set when the SuperCommand is
 	// actually a subcommand of some other SuperCommand;
 	// if NotifyRun is called, it name will be prefixed accordingly,
-	// unless UsagePrefix is identical to Name.
+	// unless UsagePrefix is identical to Name. If it is not set, the
+	// full name of the SuperCommand it is registered with is used, so
+	// that SuperCommands may be nested to any depth.
 	UsagePrefix string
 
 	// Notify, if not nil, is called when the SuperCommand
 	// is about to run a sub-command.
 	NotifyRun func(cmdName string)
 
+	// RunHooks, if set, are called around the running of each
+	// subcommand; see RunHook and AddRunHook.
+	RunHooks []RunHook
+
+	// PosixFlags, if set, causes the flags of the SuperCommand and all
+	// its subcommands to be parsed with ParsePosixFlags; see
+	// Info.PosixFlags.
+	PosixFlags bool
+
+	// ShowDurationFlag, if set, adds a --show-duration flag to the
+	// SuperCommand and all its subcommands, which causes the time taken
+	// by the command, and by each phase reported with Context.StartPhase,
+	// to be shown when it finishes.
+	ShowDurationFlag bool
+
 	// NotifyHelp is called just before help is printed, with the
 	// arguments received by the help command. This can be
 	// used, for example, to load command information for external
@@ -79,14 +100,24 @@ type SuperCommandParams struct {
 	// no logging flags will be configured.
 	Log *Log
 	// GlobalFlags specifies a value that can add more global flags to the
-	// supercommand which will also be available on all subcommands.
+	// supercommand which will also be available on all subcommands,
+	// including those of nested supercommands, before or after the
+	// subcommand name.
 	GlobalFlags     FlagAdder
 	MissingCallback MissingCallback
-	Aliases         []string
-	Version         string
+	// PluginPrefix, if set and MissingCallback is not, causes subcommands
+	// that are not registered to be run as external plugins named
+	// "<PluginPrefix>-<subcommand>"; see NewPluginCallback.
+	PluginPrefix string
+	Aliases      []string
+	// Version is reported by the default version subcommand and the
+	// --version flag. If it is empty, BuildVersion is used; if that is also
+	// empty, neither is available.
+	Version string
 	// VersionDetail is a freeform information that is output when the default version
 	// subcommand is passed --all. Output is formatted using the user-selected formatter.
-	// Exported fields should specify yaml and json field tags.
+	// Exported fields should specify yaml and json field tags. If it is nil,
+	// the build information returned by CurrentBuildInfo is output.
 	VersionDetail interface{}
 
 	// UserAliasesFilename refers to the location of a file that contains
@@ -95,6 +126,13 @@ type SuperCommandParams struct {
 	// to add flags, or provide short cuts to longer commands.
 	UserAliasesFilename string
 
+	// UserDefaultsFilename refers to the location of a YAML file that
+	// holds per-command default values for flags, keyed by command name
+	// (see ParseDefaultsFile), such as ~/.config/<app>/defaults.yaml.
+	// Values given on the command line or in the environment (see
+	// FlagFromEnv) take precedence over the defaults.
+	UserDefaultsFilename string
+
 	// FlagKnownAs allows different projects to customise what their flags are
 	// known as, e.g. 'flag', 'option', 'item'. All error/log messages
 	// will use that name when referring to an individual items/flags in this command.
@@ -112,6 +150,14 @@ type FlagAdder interface {
 // NewSuperCommand creates and initializes a new `SuperCommand`, and returns
 // the fully initialized structure.
 func NewSuperCommand(params SuperCommandParams) *SuperCommand {
+	missingCallback := params.MissingCallback
+	if missingCallback == nil && params.PluginPrefix != "" {
+		missingCallback = NewPluginCallback(params.PluginPrefix)
+	}
+	version := params.Version
+	if version == "" {
+		version = BuildVersion
+	}
 	command := &SuperCommand{
 		Name:    params.Name,
 		Purpose: params.Purpose,
@@ -119,17 +165,24 @@ func NewSuperCommand(params SuperCommandParams) *SuperCommand {
 		Log:     params.Log,
 		Aliases: params.Aliases,
 
-		globalFlags:         params.GlobalFlags,
-		usagePrefix:         params.UsagePrefix,
-		missingCallback:     params.MissingCallback,
-		version:             params.Version,
-		versionDetail:       params.VersionDetail,
-		notifyRun:           params.NotifyRun,
-		notifyHelp:          params.NotifyHelp,
-		userAliasesFilename: params.UserAliasesFilename,
-		FlagKnownAs:         params.FlagKnownAs,
+		globalFlags:          params.GlobalFlags,
+		usagePrefix:          params.UsagePrefix,
+		missingCallback:      missingCallback,
+		version:              version,
+		versionDetail:        params.VersionDetail,
+		notifyRun:            params.NotifyRun,
+		runHooks:             append([]RunHook(nil), params.RunHooks...),
+		showDurationFlag:     params.ShowDurationFlag,
+		posixFlags:           params.PosixFlags,
+		notifyHelp:           params.NotifyHelp,
+		userAliasesFilename:  params.UserAliasesFilename,
+		userDefaultsFilename: params.UserDefaultsFilename,
+		FlagKnownAs:          params.FlagKnownAs,
 	}
 	command.init()
+	if params.MissingCallback == nil && params.PluginPrefix != "" {
+		command.addPluginsTopic(params.PluginPrefix)
+	}
 	return command
 }
 
@@ -151,6 +204,9 @@ type commandReference struct {
 	command Command
 	alias   string
 	check   DeprecationCheck
+	// hidden commands are runnable, but are not listed in help,
+	// completion or generated documentation.
+	hidden bool
 }
 
 // SuperCommand is a Command that selects a subcommand and assumes its
@@ -159,30 +215,42 @@ type commandReference struct {
 // its selected subcommand.
 type SuperCommand struct {
 	CommandBase
-	Name                string
-	Purpose             string
-	Doc                 string
-	Log                 *Log
-	Aliases             []string
-	globalFlags         FlagAdder
-	version             string
-	versionDetail       interface{}
-	usagePrefix         string
-	userAliasesFilename string
-	userAliases         map[string][]string
-	subcmds             map[string]commandReference
-	help                *helpCommand
-	documentation       *documentationCommand
-	commonflags         *gnuflag.FlagSet
-	flags               *gnuflag.FlagSet
-	action              commandReference
-	showHelp            bool
-	showDescription     bool
-	showVersion         bool
-	noAlias             bool
-	missingCallback     MissingCallback
-	notifyRun           func(string)
-	notifyHelp          func([]string)
+	Name                 string
+	Purpose              string
+	Doc                  string
+	Log                  *Log
+	Aliases              []string
+	globalFlags          FlagAdder
+	version              string
+	versionDetail        interface{}
+	usagePrefix          string
+	parent               *SuperCommand
+	userAliasesFilename  string
+	userAliases          map[string][]string
+	userDefaultsFilename string
+	userDefaults         map[string]map[string]string
+	subcmds              map[string]commandReference
+	help                 *helpCommand
+	documentation        *documentationCommand
+	commonflags          *gnuflag.FlagSet
+	inheritedFlags       *gnuflag.FlagSet
+	flags                *gnuflag.FlagSet
+	action               commandReference
+	showHelp             bool
+	showDescription      bool
+	showDescribe         bool
+	showVersion          bool
+	explain              bool
+	allowRoot            bool
+	noAlias              bool
+	missingCallback      MissingCallback
+	notifyRun            func(string)
+	runHooks             []RunHook
+	showDurationFlag     bool
+	posixFlags           bool
+	showDuration         bool
+	actionArgs           []string
+	notifyHelp           func([]string)
 
 	// FlagKnownAs allows different projects to customise what their flags are
 	// known as, e.g. 'flag', 'option', 'item'. All error/log messages
@@ -217,6 +285,8 @@ func (c *SuperCommand) init() {
 		"help": {command: c.help},
 		"documentation": {command: c.documentation,
 			name: "documentation"},
+		"completion": {command: &completionCommand{super: c},
+			name: "completion"},
 	}
 
 	if c.version != "" {
@@ -226,6 +296,7 @@ func (c *SuperCommand) init() {
 	}
 
 	c.userAliases = ParseAliasFile(c.userAliasesFilename)
+	c.userDefaults = ParseDefaultsFile(c.userDefaultsFilename)
 }
 
 // AddHelpTopic adds a new help topic with the description being the short
@@ -252,6 +323,19 @@ func (c *SuperCommand) Register(subcmd Command) {
 	}
 }
 
+// RegisterHidden makes a subcommand available for use on the command line,
+// like Register, but omits it and its aliases from the list of commands in
+// help and from shell completion and generated documentation. This is
+// intended for internal commands, such as those used for debugging, that
+// should not clutter the commands offered to users.
+func (c *SuperCommand) RegisterHidden(subcmd Command) {
+	info := subcmd.Info()
+	c.insert(commandReference{name: info.Name, command: subcmd, hidden: true})
+	for _, name := range info.Aliases {
+		c.insert(commandReference{name: name, command: subcmd, alias: info.Name, hidden: true})
+	}
+}
+
 // RegisterDeprecated makes a subcommand available for use on the command line if it
 // is not obsolete.  It inserts the command with the specified DeprecationCheck so
 // that a warning is displayed if the command is deprecated.
@@ -327,6 +411,16 @@ func (c *SuperCommand) insert(value commandReference) {
 		panic(fmt.Sprintf("command already registered: %q", value.name))
 	}
 	c.subcmds[value.name] = value
+	if sub, ok := value.command.(*SuperCommand); ok && value.alias == "" {
+		sub.parent = c
+		// Only the root command generates a completion script, which
+		// covers its nested SuperCommands.
+		if ref, ok := sub.subcmds["completion"]; ok {
+			if _, ok := ref.command.(*completionCommand); ok {
+				delete(sub.subcmds, "completion")
+			}
+		}
+	}
 }
 
 // describeCommands returns a short description of each registered subcommand.
@@ -337,15 +431,16 @@ func (c *SuperCommand) describeCommands(simple bool) string {
 		lineFormat = "%-*s  %s"
 		outputFormat = "%s"
 	}
-	cmds := make([]string, len(c.subcmds))
-	i := 0
+	cmds := make([]string, 0, len(c.subcmds))
 	longest := 0
-	for name := range c.subcmds {
+	for name, action := range c.subcmds {
+		if action.hidden {
+			continue
+		}
 		if len(name) > longest {
 			longest = len(name)
 		}
-		cmds[i] = name
-		i++
+		cmds = append(cmds, name)
 	}
 	sort.Strings(cmds)
 	var result []string
@@ -373,6 +468,12 @@ func (c *SuperCommand) Info() *Info {
 		info.FlagKnownAs = c.FlagKnownAs
 		return &info
 	}
+	return c.superInfo()
+}
+
+// superInfo returns the documentation for the SuperCommand itself, whichever
+// subcommand has been selected.
+func (c *SuperCommand) superInfo() *Info {
 	docParts := []string{}
 	if doc := strings.TrimSpace(c.Doc); doc != "" {
 		docParts = append(docParts, doc)
@@ -387,6 +488,7 @@ func (c *SuperCommand) Info() *Info {
 		Doc:         strings.Join(docParts, "\n\n"),
 		Aliases:     c.Aliases,
 		FlagKnownAs: c.FlagKnownAs,
+		PosixFlags:  c.posixFlags,
 	}
 }
 
@@ -409,6 +511,19 @@ func (c *SuperCommand) SetCommonFlags(f *gnuflag.FlagSet) {
 	// The Purpose attribute will be printed (if defined), allowing
 	// plugins to provide a sensible line of text for 'juju help plugins'.
 	f.BoolVar(&c.showDescription, "description", false, "Show short description of plugin, if any")
+	if c.showDurationFlag {
+		f.BoolVar(&c.showDuration, "show-duration", false, "Show the time taken by the command when it finishes")
+	}
+	// A nested SuperCommand also accepts the common flags of the
+	// SuperCommands it is run by, unless it defines a flag of its own
+	// with the same name.
+	if c.inheritedFlags != nil {
+		c.inheritedFlags.VisitAll(func(flag *gnuflag.Flag) {
+			if f.Lookup(flag.Name) == nil {
+				f.Var(flag.Value, flag.Name, flag.Usage)
+			}
+		})
+	}
 	c.commonflags = gnuflag.NewFlagSetWithFlagKnownAs(c.Info().Name, gnuflag.ContinueOnError, FlagAlias(c, "flag"))
 	c.commonflags.SetOutput(ioutil.Discard)
 	f.VisitAll(func(flag *gnuflag.Flag) {
@@ -429,6 +544,12 @@ func (c *SuperCommand) SetFlags(f *gnuflag.FlagSet) {
 	if c.userAliasesFilename != "" {
 		f.BoolVar(&c.noAlias, "no-alias", false, "do not process command aliases when running this command")
 	}
+	// The --describe flag of the plugin protocol is only offered when
+	// the command is run as a plugin by a host supporting the protocol,
+	// so that it does not clutter the help of other commands.
+	if _, ok := PluginHostProtocol(); ok {
+		f.BoolVar(&c.showDescribe, "describe", false, "Describe the plugin in JSON, for its host")
+	}
 	c.flags = f
 }
 
@@ -442,11 +563,12 @@ func (c *SuperCommand) AllowInterspersedFlags() bool {
 
 // Init initializes the command for running.
 func (c *SuperCommand) Init(args []string) error {
-	if c.showDescription {
+	if c.showDescription || c.showDescribe {
 		return CheckEmpty(args)
 	}
 	if len(args) == 0 {
 		c.action = c.subcmds["help"]
+		c.actionArgs = args
 		return c.action.command.Init(args)
 	}
 
@@ -460,17 +582,20 @@ func (c *SuperCommand) Init(args []string) error {
 	if c.action, found = c.subcmds[args[0]]; !found {
 		if c.missingCallback != nil {
 			c.action = commandReference{
+				name: args[0],
 				command: &missingCommand{
-					callback:  c.missingCallback,
-					superName: c.Name,
-					name:      args[0],
-					args:      args[1:],
+					callback:    c.missingCallback,
+					superName:   c.Name,
+					name:        args[0],
+					args:        args[1:],
+					suggestions: c.suggestSubCommands(args[0]),
 				},
 			}
+			c.actionArgs = args[1:]
 			// Yes return here, no Init called on missing Command.
 			return nil
 		}
-		return fmt.Errorf("unrecognized command: %s %s", c.Name, args[0])
+		return fmt.Errorf("unrecognized command: %s %s%s", c.fullName(), args[0], didYouMean(c.suggestSubCommands(args[0])))
 	}
 
 	args = args[1:]
@@ -478,12 +603,37 @@ func (c *SuperCommand) Init(args []string) error {
 	if subcmd.IsSuperCommand() {
 		f := gnuflag.NewFlagSetWithFlagKnownAs(c.Info().Name, gnuflag.ContinueOnError, FlagAlias(subcmd, "flag"))
 		f.SetOutput(ioutil.Discard)
+		if sub, ok := subcmd.(*SuperCommand); ok {
+			sub.inheritedFlags = c.commonflags
+			sub.posixFlags = sub.posixFlags || c.posixFlags
+			// A nested SuperCommand without a defaults file of its own
+			// uses the defaults of the SuperCommand it is run by.
+			if sub.userDefaultsFilename == "" {
+				sub.userDefaults = c.userDefaults
+			}
+		}
 		subcmd.SetFlags(f)
 	} else {
+		addExplainFlag(c.commonflags, subcmd, &c.explain)
+		addAllowRootFlag(c.commonflags, subcmd.Info(), &c.allowRoot)
 		subcmd.SetFlags(c.commonflags)
+		// The flags of c have already been parsed, and set from the
+		// environment, by Main or the SuperCommand c is nested in.
+		if err := applyFlagEnv(c.commonflags, c.givenFlags()); err != nil {
+			return err
+		}
 	}
-	if err := c.commonflags.Parse(subcmd.AllowInterspersedFlags(), args); err != nil {
-		return err
+	posix := c.posixFlags || subcmd.Info().PosixFlags
+	if err := ParseFlags(c.commonflags, posix, subcmd.AllowInterspersedFlags(), args); err != nil {
+		info := *c.Info()
+		name := c.fullName()
+		info.Name = name + " " + c.action.name
+		return withFlagContext(err, c.commonflags, &info, name+" help "+c.action.name)
+	}
+	if !subcmd.IsSuperCommand() {
+		if err := applyDefaults(c.commonflags, c.givenFlags(), c.userDefaults, subcmd.Info().Name); err != nil {
+			return err
+		}
 	}
 
 	args = c.commonflags.Args()
@@ -491,10 +641,67 @@ func (c *SuperCommand) Init(args []string) error {
 		// We want to treat help for the command the same way we would if we went "help foo".
 		args = []string{c.action.name}
 		c.action = c.subcmds["help"]
+	} else if !subcmd.IsSuperCommand() {
+		if err := checkRequiredFlags(subcmd.Info(), c.commonflags, c.givenFlags()); err != nil {
+			return err
+		}
+		if err := CheckArgs(subcmd.Info(), args); err != nil {
+			return err
+		}
 	}
+	c.actionArgs = args
 	return c.action.command.Init(args)
 }
 
+// givenFlags returns the unwrapped values of the flags that have been set
+// on the flag sets of c and of the SuperCommands it is nested in.
+func (c *SuperCommand) givenFlags() map[gnuflag.Value]bool {
+	given := make(map[gnuflag.Value]bool)
+	for sc := c; sc != nil; sc = sc.parent {
+		for _, f := range []*gnuflag.FlagSet{sc.flags, sc.commonflags} {
+			if f == nil {
+				continue
+			}
+			f.Visit(func(flag *gnuflag.Flag) {
+				given[unwrapFlagValue(flag.Value)] = true
+			})
+		}
+	}
+	return given
+}
+
+// fullName returns the name by which the SuperCommand is invoked,
+// including any usage prefix.
+func (c *SuperCommand) fullName() string {
+	if prefix := c.prefix(); prefix != "" && prefix != c.Name {
+		return prefix + " " + c.Name
+	}
+	return c.Name
+}
+
+// selectedSuperCommand returns the SuperCommand, either c or one nested
+// within it, whose selected subcommand is the command that will run.
+func (c *SuperCommand) selectedSuperCommand() *SuperCommand {
+	sc := c
+	for {
+		sub, ok := sc.action.command.(*SuperCommand)
+		if !ok || sub.action.command == nil {
+			return sc
+		}
+		sc = sub
+	}
+}
+
+// prefix returns the usage prefix of the SuperCommand: either the one it
+// was created with, or the full name of the SuperCommand it is registered
+// with.
+func (c *SuperCommand) prefix() string {
+	if c.usagePrefix == "" && c.parent != nil {
+		return c.parent.fullName()
+	}
+	return c.usagePrefix
+}
+
 // Run executes the subcommand that was selected in Init.
 func (c *SuperCommand) Run(ctx *Context) error {
 	if c.showDescription {
@@ -505,6 +712,9 @@ func (c *SuperCommand) Run(ctx *Context) error {
 		}
 		return nil
 	}
+	if c.showDescribe {
+		return c.describe(ctx)
+	}
 	if c.action.command == nil {
 		panic("Run: missing subcommand; Init failed or not called")
 	}
@@ -514,27 +724,47 @@ func (c *SuperCommand) Run(ctx *Context) error {
 	// appropriate action further down stream.
 	ctx.serialisable = c.isSerialisableFormatDirective()
 
-	if c.Log != nil {
+	// The root policy of the command that will finally run is checked
+	// once, by the outermost SuperCommand, before logging starts so that
+	// no log files are created by a command that refuses to run.
+	var err error
+	if !ctx.rootChecked {
+		ctx.rootChecked = true
+		sc := c.selectedSuperCommand()
+		err = checkRoot(ctx, sc.action.command.Info(), sc.allowRoot)
+	}
+
+	if c.Log != nil && err == nil {
 		if err := c.Log.Start(ctx); err != nil {
 			return err
 		}
 	}
 
+	if c.showDuration && ctx.phaseTimings == nil {
+		ctx.phaseTimings = &phaseTimings{}
+		start := durationNow()
+		defer func() {
+			ctx.writeDuration(durationNow().Sub(start))
+		}()
+	}
 	if c.notifyRun != nil {
-		name := c.Name
-		if c.usagePrefix != "" && c.usagePrefix != name {
-			name = c.usagePrefix + " " + name
-		}
-		c.notifyRun(name)
+		c.notifyRun(c.fullName())
 	}
 	if deprecated, replacement := c.action.Deprecated(); deprecated {
 		ctx.Warningf("%q is deprecated, please use %q", c.action.name, replacement)
 	}
+	warnDeprecatedFlags(ctx, c.commonflags)
 
-	err := c.action.command.Run(ctx)
+	if err == nil {
+		if c.explain {
+			err = c.runExplain(ctx)
+		} else {
+			err = c.runAction(ctx)
+		}
+	}
 	if err != nil && !IsErrSilent(err) {
 		// Handle formatting when displaying errors.
-		handleErr := c.handleErrorForMachineFormats(ctx)
+		handleErr := c.handleErrorForMachineFormats(ctx, err)
 		if handleErr != nil {
 			// If there is a handle error when attempting to find the machine
 			// format, we should let the user know. In doing so, we dump the
@@ -544,11 +774,14 @@ func (c *SuperCommand) Run(ctx *Context) error {
 			return handleErr
 		}
 
-		WriteError(ctx.Stderr, err)
+		ctx.writeError(err)
 		logger.Debugf("error stack: \n%v", errors.ErrorStack(err))
 
-		// Err has been logged above, we can make the err silent so it does not log again in cmd/main
-		if !IsRcPassthroughError(err) {
+		// Err has been logged above, we can make the err silent so it does not log again in cmd/main,
+		// keeping its exit code.
+		if code := ExitCode(err); code != ExitFailure {
+			err = NewRcPassthroughError(code)
+		} else {
 			err = ErrSilent
 		}
 	} else {
@@ -557,6 +790,25 @@ func (c *SuperCommand) Run(ctx *Context) error {
 	return err
 }
 
+// runExplain asks the selected subcommand for its plan, and writes it out
+// using the format chosen with the common --format flag, if any.
+func (c *SuperCommand) runExplain(ctx *Context) error {
+	explainer, ok := c.action.command.(Explainer)
+	if !ok {
+		return errors.Errorf("%q does not support --explain", c.action.name)
+	}
+	plan, err := explainer.Explain(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if ctx.IsSerial() {
+		formatName := c.commonflags.Lookup("format").Value.String()
+		ctx.outputFormatUsed = true
+		return DefaultFormatters[formatName].Formatter(ctx.Stdout, plan)
+	}
+	return FormatPlan(ctx.Stdout, plan)
+}
+
 // isSerialisableFormatDirective checks to see if the output format for a given
 // super command common flag (global), is intended to be used by a machine or
 // not.
@@ -578,11 +830,12 @@ func (c *SuperCommand) isSerialisableFormatDirective() bool {
 // formatting directives.
 // If the formatting directive is what we consider a machine format (yaml or
 // json), then we attempt to output nothing for that format. An example of this
-// would be; for json, that would be {}.
+// would be; for json, that would be {}. If err is an ErrorGroup, its errors are
+// output instead.
 // No additional writes to stdout or stderr should be performed when a
 // successful format lookup is done, otherwise return errors from a unsuccessful
 // lookup.
-func (c *SuperCommand) handleErrorForMachineFormats(ctx *Context) error {
+func (c *SuperCommand) handleErrorForMachineFormats(ctx *Context, err error) error {
 	// If an output format was used on stdout already we can omit correction
 	// of the machine output.
 	if !ctx.IsSerial() || ctx.outputFormatUsed {
@@ -603,7 +856,7 @@ func (c *SuperCommand) handleErrorForMachineFormats(ctx *Context) error {
 	// correctly handle the resulting empty value.
 	// If we place it into stderr, it means that you can never add any more
 	// additional information to stderr, even if it helps the user.
-	return typeFormatter.Formatter(ctx.Stdout, struct{}{})
+	return typeFormatter.Formatter(ctx.Stdout, machineErrorValue(err))
 }
 
 // FindClosestSubCommand attempts to find a sub command by a given name.
@@ -624,7 +877,10 @@ func (c *SuperCommand) FindClosestSubCommand(name string) (string, Command, bool
 		Value int
 	}
 	matches := make([]Indexed, 0, len(c.subcmds))
-	for cmdName := range c.subcmds {
+	for cmdName, action := range c.subcmds {
+		if action.hidden {
+			continue
+		}
 		matches = append(matches, Indexed{
 			Name:  cmdName,
 			Value: levenshteinDistance(name, cmdName),
@@ -641,6 +897,9 @@ func (c *SuperCommand) FindClosestSubCommand(name string) (string, Command, bool
 		}
 		return matches[i].Name < matches[j].Name
 	})
+	if len(matches) == 0 {
+		return "", nil, false
+	}
 	matchedName := matches[0].Name
 	matchedValue := matches[0].Value
 
@@ -652,6 +911,62 @@ func (c *SuperCommand) FindClosestSubCommand(name string) (string, Command, bool
 	return "", nil, false
 }
 
+// maxSuggestions is the most subcommands suggested in place of an
+// unrecognized one.
+const maxSuggestions = 3
+
+// suggestSubCommands returns the names of the subcommands and aliases
+// closest to the unrecognized name, closest first. Names that are further
+// than a third of the length of name away are not suggested, nor are
+// hidden or deprecated commands.
+func (c *SuperCommand) suggestSubCommands(name string) []string {
+	maxDistance := len(name) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+	distances := make(map[string]int)
+	var names []string
+	for cmdName, action := range c.subcmds {
+		if action.hidden {
+			continue
+		}
+		if deprecated, _ := action.Deprecated(); deprecated {
+			continue
+		}
+		if distance := levenshteinDistance(name, cmdName); distance <= maxDistance {
+			distances[cmdName] = distance
+			names = append(names, cmdName)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if distances[names[i]] != distances[names[j]] {
+			return distances[names[i]] < distances[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > maxSuggestions {
+		names = names[:maxSuggestions]
+	}
+	return names
+}
+
+// didYouMean returns a suffix for an unrecognized command error that
+// suggests the given names, or an empty string if there are none.
+func didYouMean(suggestions []string) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(suggestions))
+	for i, name := range suggestions {
+		quoted[i] = strconv.Quote(name)
+	}
+	last := len(quoted) - 1
+	if last == 0 {
+		return fmt.Sprintf(" (did you mean %s?)", quoted[0])
+	}
+	return fmt.Sprintf(" (did you mean %s or %s?)", strings.Join(quoted[:last], ", "), quoted[last])
+}
+
 // levenshteinDistance
 // from https://groups.google.com/forum/#!topic/golang-nuts/YyH1f_qCZVc
 // (no min, compute lengths once, 2 rows array)
@@ -695,6 +1010,9 @@ type missingCommand struct {
 	superName string
 	name      string
 	args      []string
+	// suggestions are the registered commands closest to name, for the
+	// error returned if the callback doesn't recognize it either.
+	suggestions []string
 }
 
 // Missing commands only need to supply Info for the interface, but this is
@@ -709,7 +1027,7 @@ func (c *missingCommand) Run(ctx *Context) error {
 	if !isUnrecognized {
 		return err
 	}
-	return DefaultUnrecognizedCommand(fmt.Sprintf("%s %s", c.superName, c.name))
+	return UnrecognizedCommandf("unrecognized command: %s %s%s", c.superName, c.name, didYouMean(c.suggestions))
 }
 
 // Deprecated calls into the check interface if one was specified,
@@ -49,9 +49,10 @@ type SuperCommandSuite struct {
 
 var _ = gc.Suite(&SuperCommandSuite{})
 
+const completionText = "\n    completion\\s+- Generate a shell completion script"
 const docText = "\n    documentation\\s+- Generate the documentation for all commands"
 const helpText = "\n    help\\s+- Show help on a command or other topic."
-const helpCommandsText = "commands:" + docText + helpText
+const helpCommandsText = "commands:" + completionText + docText + helpText
 
 func (s *SuperCommandSuite) SetUpTest(c *gc.C) {
 	s.IsolationSuite.SetUpTest(c)
@@ -71,7 +72,7 @@ func (s *SuperCommandSuite) TestDispatch(c *gc.C) {
 	info = jc.Info()
 	c.Assert(info.Name, gc.Equals, "jujutest")
 	c.Assert(info.Args, gc.Equals, "<command> ...")
-	c.Assert(info.Doc, gc.Matches, "commands:\n    defenestrate  - defenestrate the juju"+docText+helpText)
+	c.Assert(info.Doc, gc.Matches, "commands:"+completionText+"\n    defenestrate  - defenestrate the juju"+docText+helpText)
 
 	jc, tc, err := initDefenestrate([]string{"defenestrate"})
 	c.Assert(err, gc.IsNil)
@@ -141,6 +142,7 @@ func (s *SuperCommandSuite) TestAliasesRegistered(c *gc.C) {
 
 	info := jc.Info()
 	c.Assert(info.Doc, gc.Equals, `commands:
+    completion    - Generate a shell completion script
     documentation - Generate the documentation for all commands
     flap          - Alias for 'flip'.
     flip          - flip the juju
@@ -150,6 +152,7 @@ func (s *SuperCommandSuite) TestAliasesRegistered(c *gc.C) {
 
 func (s *SuperCommandSuite) TestInfo(c *gc.C) {
 	commandsDoc := `commands:
+    completion    - Generate a shell completion script
     documentation - Generate the documentation for all commands
     flapbabble    - flapbabble the juju
     flip          - flip the juju`
@@ -258,7 +261,11 @@ func (s *SuperCommandSuite) TestVersionNotProvidedFlag(c *gc.C) {
 	// juju --version
 	code := cmd.Main(jc, s.ctx, []string{"--version"})
 	c.Check(code, gc.Not(gc.Equals), 0)
-	c.Assert(cmdtesting.Stderr(s.ctx), gc.Equals, "ERROR flag provided but not defined: --version\n")
+	c.Assert(cmdtesting.Stderr(s.ctx), gc.Equals, `
+ERROR flag provided but not defined: --version
+Usage: jujutest [flags] <command> ...
+See "jujutest --help" for details.
+`[1:])
 }
 
 func (s *SuperCommandSuite) TestVersionNotProvidedOption(c *gc.C) {
@@ -271,7 +278,11 @@ func (s *SuperCommandSuite) TestVersionNotProvidedOption(c *gc.C) {
 	jc.FlagKnownAs = "option"
 	code := cmd.Main(jc, s.ctx, []string{"--version"})
 	c.Check(code, gc.Not(gc.Equals), 0)
-	c.Assert(cmdtesting.Stderr(s.ctx), gc.Equals, "ERROR option provided but not defined: --version\n")
+	c.Assert(cmdtesting.Stderr(s.ctx), gc.Equals, `
+ERROR option provided but not defined: --version
+Usage: jujutest [options] <command> ...
+See "jujutest --help" for details.
+`[1:])
 }
 
 func (s *SuperCommandSuite) TestLogging(c *gc.C) {
@@ -458,6 +469,7 @@ func (s *SuperCommandSuite) TestRegisterAlias(c *gc.C) {
 	info := jc.Info()
 	// NOTE: deprecated `bar` not shown in commands.
 	c.Assert(info.Doc, gc.Equals, `commands:
+    completion    - Generate a shell completion script
     documentation - Generate the documentation for all commands
     foo           - Alias for 'test'.
     help          - Show help on a command or other topic.
@@ -526,6 +538,7 @@ func (s *SuperCommandSuite) TestRegisterSuperAlias(c *gc.C) {
 	c.Assert(info.Doc, gc.Equals, `commands:
     bar           - bar functions
     bar-foo       - Alias for 'bar foo'.
+    completion    - Generate a shell completion script
     documentation - Generate the documentation for all commands
     help          - Show help on a command or other topic.
     test          - to be simple`)
@@ -548,7 +561,7 @@ func (s *SuperCommandSuite) TestRegisterSuperAlias(c *gc.C) {
 			stderr: "WARNING \"bar-dep\" is deprecated, please use \"bar foo\"\n",
 		}, {
 			args:   []string{"bar-ob", "arg"},
-			stderr: "ERROR unrecognized command: jujutest bar-ob\n",
+			stderr: "ERROR unrecognized command: jujutest bar-ob (did you mean \"bar-foo\"?)\n",
 			code:   2,
 		},
 	} {
@@ -626,6 +639,35 @@ func (s *SuperCommandSuite) TestRegisterDeprecated(c *gc.C) {
 	}
 }
 
+func (s *SuperCommandSuite) TestRegisterHidden(c *gc.C) {
+	jc := cmd.NewSuperCommand(cmd.SuperCommandParams{
+		Name: "jujutest",
+	})
+	jc.Register(&simple{name: "test"})
+	jc.RegisterHidden(&simple{name: "debug-state"})
+
+	info := jc.Info()
+	c.Assert(info.Doc, gc.Equals, `commands:
+    completion    - Generate a shell completion script
+    documentation - Generate the documentation for all commands
+    help          - Show help on a command or other topic.
+    test          - to be simple`)
+
+	code := cmd.Main(jc, s.ctx, []string{"debug-state", "arg"})
+	c.Check(code, gc.Equals, 0)
+	c.Check(cmdtesting.Stdout(s.ctx), gc.Equals, "debug-state arg\n")
+
+	s.SetUpTest(c)
+	code = cmd.Main(jc, s.ctx, []string{"completion", "bash"})
+	c.Check(code, gc.Equals, 0)
+	c.Check(cmdtesting.Stdout(s.ctx), gc.Not(gc.Matches), "(?s).*debug-state.*")
+
+	s.SetUpTest(c)
+	code = cmd.Main(jc, s.ctx, []string{"debug-stat"})
+	c.Check(code, gc.Equals, 2)
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, "ERROR unrecognized command: jujutest debug-stat\n")
+}
+
 func (s *SuperCommandSuite) TestGlobalFlagsBeforeCommand(c *gc.C) {
 	flag := ""
 	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{
@@ -668,6 +710,34 @@ func (s *SuperCommandSuite) TestGlobalFlagsAfterCommand(c *gc.C) {
 	c.Check(cmdtesting.Stdout(s.ctx), gc.Equals, "testoption\n")
 }
 
+func (s *SuperCommandSuite) TestGlobalFlagsNested(c *gc.C) {
+	for _, args := range [][]string{
+		{"--testflag=something", "metadata", "blah", "--option=testoption"},
+		{"metadata", "--testflag=something", "blah", "--option=testoption"},
+		{"metadata", "blah", "--option=testoption", "--testflag=something"},
+	} {
+		s.SetUpTest(c)
+		flag := ""
+		log := &cmd.Log{}
+		sc := cmd.NewSuperCommand(cmd.SuperCommandParams{
+			Name: "command",
+			GlobalFlags: flagAdderFunc(func(fset *gnuflag.FlagSet) {
+				fset.StringVar(&flag, "testflag", "", "global test flag")
+			}),
+			Log: log,
+		})
+		metadata := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "metadata"})
+		sc.Register(metadata)
+		metadata.Register(&TestCommand{Name: "blah"})
+		code := cmd.Main(sc, s.ctx, append(args, "--quiet"))
+		c.Check(code, gc.Equals, 0, gc.Commentf("%v", args))
+		c.Check(flag, gc.Equals, "something")
+		c.Check(log.Quiet, gc.Equals, true)
+		c.Check(cmdtesting.Stdout(s.ctx), gc.Equals, "testoption\n")
+		s.TearDownTest(c)
+	}
+}
+
 func (s *SuperCommandSuite) TestSuperSetFlags(c *gc.C) {
 	sc := cmd.NewSuperCommand(cmd.SuperCommandParams{
 		UsagePrefix: "juju",
@@ -696,7 +766,11 @@ func (s *SuperCommandSuite) assertFlagsAlias(c *gc.C, sc *cmd.SuperCommand, expe
 	c.Assert(code, gc.Equals, 2)
 	c.Check(s.ctx.IsSerial(), gc.Equals, false)
 	c.Check(cmdtesting.Stdout(s.ctx), gc.Equals, "")
-	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, fmt.Sprintf("ERROR %v provided but not defined: --fluffs\n", expectedAlias))
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, fmt.Sprintf(`
+ERROR %v provided but not defined: --fluffs
+Usage: juju command blah [%vs] <something>
+See "juju command help blah" for details.
+`[1:], expectedAlias, expectedAlias))
 }
 
 func (s *SuperCommandSuite) TestErrInJson(c *gc.C) {
@@ -865,3 +939,115 @@ func (s *SuperCommandSuite) TestFindClosestSubCommandReturnsConsistentResults(c
 	c.Assert(ok, gc.Equals, true)
 	c.Assert(name, gc.Equals, "help")
 }
+
+func newSuggestionsSuperCommand(callback cmd.MissingCallback) *cmd.SuperCommand {
+	jc := cmd.NewSuperCommand(cmd.SuperCommandParams{
+		Name:            "jujutest",
+		MissingCallback: callback,
+	})
+	jc.Register(&simple{name: "generate-tools"})
+	jc.Register(&simple{name: "generate-image"})
+	jc.RegisterAlias("gen-tools", "generate-tools", nil)
+	jc.RegisterAlias("generate-tool", "generate-tools", deprecate{replacement: "generate-tools"})
+	jc.RegisterHidden(&simple{name: "generate-tooling"})
+	return jc
+}
+
+func (s *SuperCommandSuite) TestUnrecognizedCommandSuggestions(c *gc.C) {
+	for _, test := range []struct {
+		name   string
+		stderr string
+	}{{
+		name:   "generate-toolz",
+		stderr: `ERROR unrecognized command: jujutest generate-toolz (did you mean "generate-tools"?)` + "\n",
+	}, {
+		name:   "generate-imags",
+		stderr: `ERROR unrecognized command: jujutest generate-imags (did you mean "generate-image" or "generate-tools"?)` + "\n",
+	}, {
+		name:   "gen-tool",
+		stderr: `ERROR unrecognized command: jujutest gen-tool (did you mean "gen-tools"?)` + "\n",
+	}, {
+		name:   "halp",
+		stderr: `ERROR unrecognized command: jujutest halp (did you mean "help"?)` + "\n",
+	}, {
+		name:   "discombobulate",
+		stderr: "ERROR unrecognized command: jujutest discombobulate\n",
+	}} {
+		s.SetUpTest(c)
+		code := cmd.Main(newSuggestionsSuperCommand(nil), s.ctx, []string{test.name})
+		c.Check(code, gc.Equals, 2)
+		c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, test.stderr)
+		s.TearDownTest(c)
+	}
+}
+
+func (s *SuperCommandSuite) TestUnrecognizedCommandSuggestionsMissingCallback(c *gc.C) {
+	callback := func(ctx *cmd.Context, subcommand string, args []string) error {
+		return cmd.DefaultUnrecognizedCommand(subcommand)
+	}
+	code := cmd.Main(newSuggestionsSuperCommand(callback), s.ctx, []string{"generate-toolz"})
+	c.Check(code, gc.Equals, 1)
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, `ERROR unrecognized command: jujutest generate-toolz (did you mean "generate-tools"?)`+"\n")
+}
+
+// newNestedSuperCommand returns a SuperCommand with SuperCommands nested
+// three deep, none of which have a UsagePrefix, and the command registered
+// with the innermost one.
+func newNestedSuperCommand(notifyRun func(string)) (*cmd.SuperCommand, *TestCommand) {
+	tool := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "tool"})
+	metadata := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "metadata", Purpose: "manage metadata"})
+	tools := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "tools", Purpose: "manage tools metadata", NotifyRun: notifyRun})
+	tool.Register(metadata)
+	metadata.Register(tools)
+	generate := &TestCommand{Name: "generate"}
+	tools.Register(generate)
+	return tool, generate
+}
+
+func (s *SuperCommandSuite) TestNested(c *gc.C) {
+	var ran string
+	tool, generate := newNestedSuperCommand(func(name string) { ran = name })
+	code := cmd.Main(tool, s.ctx, []string{"metadata", "tools", "generate", "--option", "value"})
+	c.Assert(code, gc.Equals, 0)
+	c.Check(generate.Option, gc.Equals, "value")
+	c.Check(ran, gc.Equals, "tool metadata tools")
+}
+
+func (s *SuperCommandSuite) TestNestedHelp(c *gc.C) {
+	for _, args := range [][]string{
+		{"metadata", "tools", "generate", "--help"},
+		{"metadata", "tools", "help", "generate"},
+		{"metadata", "help", "tools", "generate"},
+		{"help", "metadata", "tools", "generate"},
+	} {
+		s.SetUpTest(c)
+		tool, _ := newNestedSuperCommand(nil)
+		code := cmd.Main(tool, s.ctx, args)
+		c.Check(code, gc.Equals, 0)
+		c.Check(cmdtesting.Stdout(s.ctx), gc.Matches, "Usage: tool metadata tools generate \\[flags\\] <something>\n(.|\n)*", gc.Commentf("%v", args))
+		s.TearDownTest(c)
+	}
+
+	s.SetUpTest(c)
+	tool, _ := newNestedSuperCommand(nil)
+	code := cmd.Main(tool, s.ctx, []string{"metadata", "tools", "--help"})
+	c.Check(code, gc.Equals, 0)
+	c.Check(cmdtesting.Stdout(s.ctx), gc.Matches, "Usage: tool metadata tools \\[flags\\] <command> ...\n(.|\n)*")
+}
+
+func (s *SuperCommandSuite) TestNestedErrors(c *gc.C) {
+	tool, _ := newNestedSuperCommand(nil)
+	code := cmd.Main(tool, s.ctx, []string{"metadata", "tools", "unknown"})
+	c.Check(code, gc.Equals, 2)
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, "ERROR unrecognized command: tool metadata tools unknown\n")
+
+	s.SetUpTest(c)
+	tool, _ = newNestedSuperCommand(nil)
+	code = cmd.Main(tool, s.ctx, []string{"metadata", "tools", "generate", "--unknown"})
+	c.Check(code, gc.Equals, 2)
+	c.Check(cmdtesting.Stderr(s.ctx), gc.Equals, `
+ERROR flag provided but not defined: --unknown
+Usage: tool metadata tools generate [flags] <something>
+See "tool metadata tools help generate" for details.
+`[1:])
+}
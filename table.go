@@ -0,0 +1,248 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/juju/ansiterm"
+	"github.com/juju/errors"
+	"github.com/juju/gnuflag"
+)
+
+// Table is a tabular result, such as a list of tools. When a Table is
+// written with Output.Write, the rows are sorted and the columns selected
+// as directed by the --sort and --columns flags (see AddTableFlags),
+// whatever the output format.
+type Table struct {
+	// Headers holds the name of each column.
+	Headers []string `yaml:"headers" json:"headers"`
+
+	// Rows holds the rows of the table, each having a value for every
+	// column.
+	Rows [][]string `yaml:"rows" json:"rows"`
+}
+
+// AddRow appends a row to the table.
+func (t *Table) AddRow(values ...string) {
+	t.Rows = append(t.Rows, values)
+}
+
+// FormatTable writes out a Table as aligned columns, with a header line.
+// Other values are written as FormatSmart would.
+func FormatTable(writer io.Writer, value interface{}) error {
+	table, ok := value.(*Table)
+	if !ok {
+		return FormatSmart(writer, value)
+	}
+	tw := ansiterm.NewTabWriter(writer, 0, 1, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(table.Headers, "\t"))
+	for _, row := range table.Rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// tableSampleRows is the number of rows of a streamed table that are held
+// back to choose the widths of its columns.
+var tableSampleRows = 100
+
+// tableRowWriter writes the rows of a streamed table as FormatTable would,
+// once enough rows have been seen to choose the widths of the columns.
+type tableRowWriter struct {
+	writer io.Writer
+	// sample holds the header and the rows held back, until widths is
+	// set.
+	sample [][]string
+	widths []int
+}
+
+// write writes row, or holds it back if the widths of the columns have
+// not yet been chosen.
+func (w *tableRowWriter) write(row []string) error {
+	if w.widths != nil {
+		return w.writeRow(row)
+	}
+	w.sample = append(w.sample, row)
+	if len(w.sample) <= tableSampleRows {
+		return nil
+	}
+	return w.flush()
+}
+
+// flush writes out any rows held back.
+func (w *tableRowWriter) flush() error {
+	if w.widths != nil {
+		return nil
+	}
+	w.widths = []int{}
+	for _, row := range w.sample {
+		// The last cell of a row is not padded, so its width does not
+		// matter.
+		for i := 0; i < len(row)-1; i++ {
+			if i == len(w.widths) {
+				w.widths = append(w.widths, 0)
+			}
+			if width := utf8.RuneCountInString(row[i]); width > w.widths[i] {
+				w.widths[i] = width
+			}
+		}
+	}
+	sample := w.sample
+	w.sample = nil
+	for _, row := range sample {
+		if err := w.writeRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRow writes row, with each cell but the last padded to the width of
+// its column and separated from the next by at least two spaces.
+func (w *tableRowWriter) writeRow(row []string) error {
+	var line strings.Builder
+	for i, value := range row {
+		line.WriteString(value)
+		if i == len(row)-1 {
+			break
+		}
+		padding := 2
+		if i < len(w.widths) && w.widths[i] > utf8.RuneCountInString(value) {
+			padding += w.widths[i] - utf8.RuneCountInString(value)
+		}
+		line.WriteString(strings.Repeat(" ", padding))
+	}
+	line.WriteString("\n")
+	_, err := io.WriteString(w.writer, line.String())
+	return err
+}
+
+// AddTableFlags injects the --sort and --columns command line flags into
+// f. Commands that write a Table should add these flags.
+func (c *Output) AddTableFlags(f *gnuflag.FlagSet) {
+	f.StringVar(&c.sortBy, "sort", "", "Sort rows by the given comma separated columns; prefix a column with - to sort in descending order")
+	f.StringVar(&c.columns, "columns", "", "Show only the given comma separated columns, in the order given")
+}
+
+// arrangeTable returns a copy of table, sorted and with the columns
+// selected by the --sort and --columns flags.
+func (c *Output) arrangeTable(table *Table) (*Table, error) {
+	rows := make([][]string, len(table.Rows))
+	copy(rows, table.Rows)
+
+	if c.sortBy != "" {
+		type sortKey struct {
+			column     int
+			descending bool
+		}
+		var keys []sortKey
+		for _, name := range strings.Split(c.sortBy, ",") {
+			name = strings.TrimSpace(name)
+			descending := strings.HasPrefix(name, "-")
+			column, err := table.column(strings.TrimPrefix(name, "-"))
+			if err != nil {
+				return nil, errors.Annotate(err, "cannot sort")
+			}
+			keys = append(keys, sortKey{column, descending})
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			for _, key := range keys {
+				cmp := naturalCompare(cell(rows[i], key.column), cell(rows[j], key.column))
+				if cmp == 0 {
+					continue
+				}
+				if key.descending {
+					return cmp > 0
+				}
+				return cmp < 0
+			}
+			return false
+		})
+	}
+
+	if c.columns == "" {
+		return &Table{Headers: table.Headers, Rows: rows}, nil
+	}
+	var columns []int
+	var headers []string
+	for _, name := range strings.Split(c.columns, ",") {
+		column, err := table.column(strings.TrimSpace(name))
+		if err != nil {
+			return nil, errors.Annotate(err, "cannot select columns")
+		}
+		columns = append(columns, column)
+		headers = append(headers, table.Headers[column])
+	}
+	selected := make([][]string, len(rows))
+	for i, row := range rows {
+		selected[i] = make([]string, len(columns))
+		for j, column := range columns {
+			selected[i][j] = cell(row, column)
+		}
+	}
+	return &Table{Headers: headers, Rows: selected}, nil
+}
+
+// column returns the index of the named column, ignoring case.
+func (t *Table) column(name string) (int, error) {
+	for i, header := range t.Headers {
+		if strings.EqualFold(header, name) {
+			return i, nil
+		}
+	}
+	return 0, errors.Errorf("unknown column %q, expected one of: %s", name, strings.Join(t.Headers, ", "))
+}
+
+// cell returns the value of the given column of row, or "" if the row is
+// too short.
+func cell(row []string, column int) string {
+	if column < len(row) {
+		return row[column]
+	}
+	return ""
+}
+
+// naturalCompare compares a and b, treating runs of digits as numbers, so
+// that sizes and versions sort as expected (e.g. "2.9.9" before "2.9.10").
+// It returns a negative number if a sorts before b, a positive number if
+// it sorts after, and zero if they are equal.
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		aChunk, aDigits := nextChunk(a)
+		bChunk, bDigits := nextChunk(b)
+		a, b = a[len(aChunk):], b[len(bChunk):]
+		var cmp int
+		if aDigits && bDigits {
+			aNum := strings.TrimLeft(aChunk, "0")
+			bNum := strings.TrimLeft(bChunk, "0")
+			if cmp = len(aNum) - len(bNum); cmp == 0 {
+				cmp = strings.Compare(aNum, bNum)
+			}
+		} else {
+			cmp = strings.Compare(aChunk, bChunk)
+		}
+		if cmp != 0 {
+			return cmp
+		}
+	}
+	return len(a) - len(b)
+}
+
+// nextChunk returns the leading run of s that is either all digits or has
+// no digits, and whether it is digits.
+func nextChunk(s string) (string, bool) {
+	digits := unicode.IsDigit(rune(s[0]))
+	for i, r := range s {
+		if unicode.IsDigit(r) != digits {
+			return s[:i], digits
+		}
+	}
+	return s, digits
+}
@@ -0,0 +1,218 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"bytes"
+
+	"github.com/juju/gnuflag"
+	"github.com/juju/loggo"
+	"github.com/juju/testing"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+type TableSuite struct {
+	testing.LoggingCleanupSuite
+	ctx *cmd.Context
+}
+
+var _ = gc.Suite(&TableSuite{})
+
+func (s *TableSuite) SetUpTest(c *gc.C) {
+	s.LoggingCleanupSuite.SetUpTest(c)
+	s.ctx = cmdtesting.Context(c)
+	loggo.ReplaceDefaultWriter(cmd.NewWarningWriter(s.ctx.Stderr))
+}
+
+// TableCommand is a command that writes a table, or streams its rows.
+type TableCommand struct {
+	cmd.CommandBase
+	out        cmd.Output
+	stream     bool
+	rows       [][]string
+	afterWrite func(ctx *cmd.Context, i int)
+}
+
+var tableRows = [][]string{
+	{"2.9.9", "amd64", "1024"},
+	{"2.9.10", "arm64", "512"},
+	{"2.9.10", "amd64", "2048"},
+}
+
+func (c *TableCommand) Info() *cmd.Info {
+	return &cmd.Info{Name: "list-tools"}
+}
+
+func (c *TableCommand) SetFlags(f *gnuflag.FlagSet) {
+	c.out.AddFlags(f, "tabular", cmd.TabularFormatters.Formatters())
+	c.out.AddTableFlags(f)
+}
+
+func (c *TableCommand) Run(ctx *cmd.Context) error {
+	headers := []string{"Version", "Arch", "Size"}
+	rows := c.rows
+	if rows == nil {
+		rows = tableRows
+	}
+	if !c.stream {
+		return c.out.Write(ctx, &cmd.Table{Headers: headers, Rows: rows})
+	}
+	stream, err := c.out.StreamTable(ctx, headers...)
+	if err != nil {
+		return err
+	}
+	for i, row := range rows {
+		if err := stream.Write(row); err != nil {
+			stream.Close()
+			return err
+		}
+		if c.afterWrite != nil {
+			c.afterWrite(ctx, i)
+		}
+	}
+	return stream.Close()
+}
+
+func (s *TableSuite) run(c *gc.C, args ...string) (int, string) {
+	code := cmd.Main(&TableCommand{}, s.ctx, args)
+	return code, cmdtesting.Stdout(s.ctx)
+}
+
+func (s *TableSuite) TestDefault(c *gc.C) {
+	code, out := s.run(c)
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(out, gc.Equals, `
+Version  Arch   Size
+2.9.9    amd64  1024
+2.9.10   arm64  512
+2.9.10   amd64  2048
+`[1:])
+}
+
+func (s *TableSuite) TestSort(c *gc.C) {
+	code, out := s.run(c, "--sort", "version,arch")
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(out, gc.Equals, `
+Version  Arch   Size
+2.9.9    amd64  1024
+2.9.10   amd64  2048
+2.9.10   arm64  512
+`[1:])
+}
+
+func (s *TableSuite) TestSortDescending(c *gc.C) {
+	code, out := s.run(c, "--sort", "-size")
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(out, gc.Equals, `
+Version  Arch   Size
+2.9.10   amd64  2048
+2.9.9    amd64  1024
+2.9.10   arm64  512
+`[1:])
+}
+
+func (s *TableSuite) TestColumns(c *gc.C) {
+	code, out := s.run(c, "--columns", "size,Version")
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(out, gc.Equals, `
+Size  Version
+1024  2.9.9
+512   2.9.10
+2048  2.9.10
+`[1:])
+}
+
+func (s *TableSuite) TestSerialised(c *gc.C) {
+	code, out := s.run(c, "--format", "json", "--sort", "size", "--columns", "version")
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(out, gc.Equals, `{"headers":["Version"],"rows":[["2.9.10"],["2.9.9"],["2.9.10"]]}`+"\n")
+}
+
+func (s *TableSuite) TestUnknownColumn(c *gc.C) {
+	code, _ := s.run(c, "--sort", "name")
+	c.Assert(code, gc.Equals, 1)
+	c.Assert(cmdtesting.Stderr(s.ctx), gc.Equals, "ERROR cannot sort: unknown column \"name\", expected one of: Version, Arch, Size\n")
+}
+
+func (s *TableSuite) TestTabularFormatters(c *gc.C) {
+	code, out := s.run(c, "--format", "yaml", "--columns", "arch", "--sort", "arch")
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(out, gc.Equals, `
+headers:
+- Arch
+rows:
+- - amd64
+- - amd64
+- - arm64
+`[1:])
+}
+
+func (s *TableSuite) TestFormatTableOtherValues(c *gc.C) {
+	var buf bytes.Buffer
+	err := cmd.FormatTable(&buf, []string{"a", "b"})
+	c.Assert(err, gc.IsNil)
+	c.Assert(buf.String(), gc.Equals, "a\nb\n")
+}
+
+func (s *TableSuite) TestStreamSameAsWrite(c *gc.C) {
+	for i, args := range [][]string{
+		nil,
+		{"--sort", "-size"},
+		{"--columns", "size,Version"},
+		{"--format", "json", "--sort", "size", "--columns", "version"},
+		{"--format", "yaml"},
+		{"--sort", "name"},
+		{"--columns", "name"},
+	} {
+		c.Logf("test %d: %v", i, args)
+		written := cmdtesting.Context(c)
+		writtenCode := cmd.Main(&TableCommand{}, written, args)
+		streamed := cmdtesting.Context(c)
+		streamedCode := cmd.Main(&TableCommand{stream: true}, streamed, args)
+		c.Check(streamedCode, gc.Equals, writtenCode)
+		c.Check(cmdtesting.Stdout(streamed), gc.Equals, cmdtesting.Stdout(written))
+		c.Check(cmdtesting.Stderr(streamed), gc.Equals, cmdtesting.Stderr(written))
+	}
+}
+
+func (s *TableSuite) TestStreamIncremental(c *gc.C) {
+	s.PatchValue(cmd.TableSampleRows, 2)
+	// While Main runs, ctx.Stdout wraps the buffer.
+	stdout := s.ctx.Stdout
+	var written []string
+	command := &TableCommand{
+		stream: true,
+		rows:   append(tableRows, []string{"2.9.100-beta1", "s390x", "4096"}),
+		afterWrite: func(ctx *cmd.Context, i int) {
+			written = append(written, bufferString(stdout))
+		},
+	}
+	code := cmd.Main(command, s.ctx, []string{"--columns", "version,arch"})
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(written, gc.DeepEquals, []string{
+		"",
+		"Version  Arch\n2.9.9    amd64\n2.9.10   arm64\n",
+		"Version  Arch\n2.9.9    amd64\n2.9.10   arm64\n2.9.10   amd64\n",
+		"Version  Arch\n2.9.9    amd64\n2.9.10   arm64\n2.9.10   amd64\n2.9.100-beta1  s390x\n",
+	})
+	c.Assert(cmdtesting.Stdout(s.ctx), gc.Equals, written[3])
+}
+
+func (s *TableSuite) TestStreamEmpty(c *gc.C) {
+	code := cmd.Main(&TableCommand{stream: true, rows: [][]string{}}, s.ctx, nil)
+	c.Assert(code, gc.Equals, 0)
+	c.Assert(cmdtesting.Stdout(s.ctx), gc.Equals, "Version  Arch  Size\n")
+}
+
+func (s *TableSuite) TestStreamNotARow(c *gc.C) {
+	var out cmd.Output
+	out.AddFlags(cmdtesting.NewFlagSet(), "tabular", cmd.TabularFormatters.Formatters())
+	stream, err := out.StreamTable(s.ctx, "Version")
+	c.Assert(err, gc.IsNil)
+	c.Assert(stream.Write("2.9.9"), gc.ErrorMatches, "cannot write string as a table row")
+	c.Assert(stream.Close(), gc.ErrorMatches, "cannot write string as a table row")
+}
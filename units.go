@@ -0,0 +1,136 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a type that deserializes a CLI string using gnuflag's Value
+// semantics. It accepts a duration such as "90s" or "1h30m", as parsed by
+// time.ParseDuration. For compatibility with flags that previously took a
+// number of seconds, a plain non-negative integer is also accepted as a
+// number of seconds.
+type Duration struct {
+	Value *time.Duration
+}
+
+// Set implements gnuflag.Value's Set method.
+func (d Duration) Set(s string) error {
+	if seconds, err := strconv.ParseUint(s, 10, 32); err == nil {
+		*d.Value = time.Duration(seconds) * time.Second
+		return nil
+	}
+	value, err := time.ParseDuration(s)
+	if err != nil {
+		// gnuflag prepends the bad argument to the error message, so it is
+		// not restated here.
+		return errors.New(`expected a duration such as "90s" or "5m"`)
+	}
+	*d.Value = value
+	return nil
+}
+
+// String implements gnuflag.Value's String method.
+func (d Duration) String() string {
+	if d.Value == nil {
+		return ""
+	}
+	return d.Value.String()
+}
+
+// ByteSize is a type that deserializes a CLI string using gnuflag's Value
+// semantics. It accepts a number of bytes, optionally followed by a unit:
+// the binary units KiB, MiB, GiB and TiB (or K, M, G and T) are multiples
+// of 1024, and the decimal units kB, MB, GB and TB are multiples of 1000. A
+// fractional number is allowed with a unit, as in "1.5GiB". Units are not
+// case sensitive.
+type ByteSize struct {
+	Value *uint64
+}
+
+// byteUnits holds the multiplier of each unit accepted by ByteSize, largest
+// first within each kind so that String can find the largest exact unit.
+var byteUnits = []struct {
+	suffix     string
+	multiplier uint64
+}{
+	{"TiB", 1 << 40},
+	{"GiB", 1 << 30},
+	{"MiB", 1 << 20},
+	{"KiB", 1 << 10},
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"TB", 1e12},
+	{"GB", 1e9},
+	{"MB", 1e6},
+	{"kB", 1e3},
+	{"B", 1},
+}
+
+// Set implements gnuflag.Value's Set method.
+func (b ByteSize) Set(s string) error {
+	size, err := parseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*b.Value = size
+	return nil
+}
+
+func parseByteSize(s string) (uint64, error) {
+	invalid := errors.New(`expected a size such as "512", "10MiB" or "1.5GB"`)
+	number := strings.TrimRightFunc(s, func(r rune) bool {
+		return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+	})
+	suffix := strings.TrimSpace(s[len(number):])
+	number = strings.TrimSpace(number)
+	if suffix == "" {
+		size, err := strconv.ParseUint(number, 10, 64)
+		if err != nil {
+			return 0, invalid
+		}
+		return size, nil
+	}
+	for _, unit := range byteUnits {
+		if !strings.EqualFold(suffix, unit.suffix) {
+			continue
+		}
+		value, err := strconv.ParseFloat(number, 64)
+		if err != nil || value < 0 || math.IsInf(value, 0) || math.IsNaN(value) {
+			return 0, invalid
+		}
+		size := value * float64(unit.multiplier)
+		if size >= math.MaxUint64 {
+			return 0, errors.New("size too large")
+		}
+		return uint64(size), nil
+	}
+	return 0, invalid
+}
+
+// String implements gnuflag.Value's String method. The size is shown in
+// the largest binary unit that represents it exactly.
+func (b ByteSize) String() string {
+	if b.Value == nil {
+		return ""
+	}
+	size := *b.Value
+	if size == 0 {
+		return "0"
+	}
+	for _, unit := range byteUnits[:4] {
+		if size%unit.multiplier == 0 {
+			return fmt.Sprintf("%d%s", size/unit.multiplier, unit.suffix)
+		}
+	}
+	return strconv.FormatUint(size, 10)
+}
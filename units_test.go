@@ -0,0 +1,131 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENSE file for details.
+
+package cmd_test
+
+import (
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/cmd/v3"
+	"github.com/juju/cmd/v3/cmdtesting"
+)
+
+var _ = gc.Suite(&UnitsSuite{})
+
+type UnitsSuite struct {
+	testing.IsolationSuite
+}
+
+func (UnitsSuite) TestDuration(c *gc.C) {
+	for _, test := range []struct {
+		in  string
+		out time.Duration
+	}{
+		{"90s", 90 * time.Second},
+		{"5m", 5 * time.Minute},
+		{"1h30m", 90 * time.Minute},
+		{"300ms", 300 * time.Millisecond},
+		{"90", 90 * time.Second},
+		{"0", 0},
+	} {
+		var value time.Duration
+		err := cmd.Duration{Value: &value}.Set(test.in)
+		c.Check(err, jc.ErrorIsNil, gc.Commentf("%q", test.in))
+		c.Check(value, gc.Equals, test.out, gc.Commentf("%q", test.in))
+	}
+}
+
+func (UnitsSuite) TestDurationInvalid(c *gc.C) {
+	for _, in := range []string{"", "soon", "5 minutes", "-1"} {
+		value := time.Minute
+		err := cmd.Duration{Value: &value}.Set(in)
+		c.Check(err, gc.ErrorMatches, `expected a duration such as "90s" or "5m"`, gc.Commentf("%q", in))
+		c.Check(value, gc.Equals, time.Minute)
+	}
+}
+
+func (UnitsSuite) TestDurationString(c *gc.C) {
+	value := 90 * time.Second
+	c.Assert(cmd.Duration{Value: &value}.String(), gc.Equals, "1m30s")
+	c.Assert(cmd.Duration{}.String(), gc.Equals, "")
+}
+
+func (UnitsSuite) TestByteSize(c *gc.C) {
+	for _, test := range []struct {
+		in  string
+		out uint64
+	}{
+		{"0", 0},
+		{"512", 512},
+		{"512B", 512},
+		{"10KiB", 10 << 10},
+		{"10k", 10 << 10},
+		{"10MiB", 10 << 20},
+		{"10mib", 10 << 20},
+		{"10M", 10 << 20},
+		{"1.5GiB", 3 << 29},
+		{"2TiB", 2 << 40},
+		{"10kB", 10000},
+		{"10MB", 10000000},
+		{"1.5GB", 1500000000},
+		{"1 MiB", 1 << 20},
+	} {
+		var value uint64
+		err := cmd.ByteSize{Value: &value}.Set(test.in)
+		c.Check(err, jc.ErrorIsNil, gc.Commentf("%q", test.in))
+		c.Check(value, gc.Equals, test.out, gc.Commentf("%q", test.in))
+	}
+}
+
+func (UnitsSuite) TestByteSizeInvalid(c *gc.C) {
+	for _, in := range []string{"", "MiB", "ten", "1.5", "-1MiB", "10PiB", "10 Mi B"} {
+		value := uint64(42)
+		err := cmd.ByteSize{Value: &value}.Set(in)
+		c.Check(err, gc.ErrorMatches, `expected a size such as "512", "10MiB" or "1.5GB"`, gc.Commentf("%q", in))
+		c.Check(value, gc.Equals, uint64(42))
+	}
+}
+
+func (UnitsSuite) TestByteSizeTooLarge(c *gc.C) {
+	var value uint64
+	err := cmd.ByteSize{Value: &value}.Set("20000000TiB")
+	c.Assert(err, gc.ErrorMatches, "size too large")
+}
+
+func (UnitsSuite) TestByteSizeString(c *gc.C) {
+	for _, test := range []struct {
+		in  uint64
+		out string
+	}{
+		{0, "0"},
+		{512, "512"},
+		{1 << 10, "1KiB"},
+		{10 << 20, "10MiB"},
+		{3 << 29, "1536MiB"},
+		{1 << 40, "1TiB"},
+		{1000000, "1000000"},
+	} {
+		value := test.in
+		c.Check(cmd.ByteSize{Value: &value}.String(), gc.Equals, test.out)
+	}
+	c.Check(cmd.ByteSize{}.String(), gc.Equals, "")
+}
+
+func (UnitsSuite) TestFlags(c *gc.C) {
+	timeout := time.Minute
+	size := uint64(10 << 20)
+	f := cmdtesting.NewFlagSet()
+	f.Var(cmd.Duration{Value: &timeout}, "timeout", "how long to wait")
+	f.Var(cmd.ByteSize{Value: &size}, "max-size", "the largest file to upload")
+	err := f.Parse(false, []string{"--timeout", "90s", "--max-size", "1GiB"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(timeout, gc.Equals, 90*time.Second)
+	c.Assert(size, gc.Equals, uint64(1<<30))
+
+	err = f.Parse(false, []string{"--max-size", "lots"})
+	c.Assert(err, gc.ErrorMatches, `invalid value "lots" for flag --max-size: expected a size such as .*`)
+}
@@ -17,7 +17,16 @@ type versionCommand struct {
 	showAll bool
 }
 
+// newVersionCommand returns a version command printing the given version,
+// or BuildVersion if it is empty. With --all, it prints versionDetail, or
+// if it is nil the build information of the running binary.
 func newVersionCommand(version string, versionDetail interface{}) *versionCommand {
+	if version == "" {
+		version = BuildVersion
+	}
+	if versionDetail == nil {
+		versionDetail = CurrentBuildInfo(version)
+	}
 	return &versionCommand{
 		version:       version,
 		versionDetail: versionDetail,
@@ -5,6 +5,7 @@ package cmd_test
 
 import (
 	"fmt"
+	"runtime"
 
 	"github.com/juju/loggo"
 	"github.com/juju/testing"
@@ -15,7 +16,7 @@ import (
 )
 
 type VersionSuite struct {
-	testing.LoggingSuite
+	testing.LoggingCleanupSuite
 
 	ctx *cmd.Context
 }
@@ -29,7 +30,7 @@ type versionDetail struct {
 }
 
 func (s *VersionSuite) SetUpTest(c *gc.C) {
-	s.LoggingSuite.SetUpTest(c)
+	s.LoggingCleanupSuite.SetUpTest(c)
 	s.ctx = cmdtesting.Context(c)
 	loggo.ReplaceDefaultWriter(cmd.NewWarningWriter(s.ctx.Stderr))
 }
@@ -74,3 +75,63 @@ func (s *VersionSuite) TestVersionDetailJson(c *gc.C) {
 {"version":"999.888.777","git-commit-hash":"46f1a0bd5592a2f9244ca321b129902a06b53e03","git-tree-state":"dirty"}
 `[1:])
 }
+
+func (s *VersionSuite) patchBuildInfo(c *gc.C) {
+	s.PatchValue(&cmd.BuildVersion, "1.2.3")
+	s.PatchValue(&cmd.BuildCommit, "46f1a0bd5592a2f9244ca321b129902a06b53e03")
+	s.PatchValue(&cmd.BuildDate, "2022-06-01T12:00:00Z")
+}
+
+func (s *VersionSuite) TestVersionBuildInfoJson(c *gc.C) {
+	s.patchBuildInfo(c)
+
+	code := cmd.Main(cmd.NewVersionCommand("999.888.777", nil), s.ctx, []string{"--all", "--format", "json"})
+	c.Check(code, gc.Equals, 0)
+	c.Assert(cmdtesting.Stderr(s.ctx), gc.Equals, "")
+	c.Assert(cmdtesting.Stdout(s.ctx), gc.Equals, fmt.Sprintf(`
+{"version":"999.888.777","git-commit":"46f1a0bd5592a2f9244ca321b129902a06b53e03","build-date":"2022-06-01T12:00:00Z","go-version":%q}
+`[1:], runtime.Version()))
+}
+
+func (s *VersionSuite) TestVersionBuildInfoText(c *gc.C) {
+	s.patchBuildInfo(c)
+
+	code := cmd.Main(cmd.NewVersionCommand("", nil), s.ctx, []string{"--all"})
+	c.Check(code, gc.Equals, 0)
+	c.Assert(cmdtesting.Stderr(s.ctx), gc.Equals, "")
+	c.Assert(cmdtesting.Stdout(s.ctx), gc.Equals, fmt.Sprintf(`
+version: 1.2.3
+git-commit: 46f1a0bd5592a2f9244ca321b129902a06b53e03
+build-date: "2022-06-01T12:00:00Z"
+go-version: %s
+`[1:], runtime.Version()))
+}
+
+func (s *VersionSuite) TestVersionBuildVersion(c *gc.C) {
+	s.patchBuildInfo(c)
+
+	code := cmd.Main(cmd.NewVersionCommand("", nil), s.ctx, nil)
+	c.Check(code, gc.Equals, 0)
+	c.Assert(cmdtesting.Stderr(s.ctx), gc.Equals, "")
+	c.Assert(cmdtesting.Stdout(s.ctx), gc.Equals, "1.2.3\n")
+}
+
+func (s *VersionSuite) TestSuperCommandUsesBuildVersion(c *gc.C) {
+	s.patchBuildInfo(c)
+
+	jc := cmd.NewSuperCommand(cmd.SuperCommandParams{Name: "jujutest"})
+	code := cmd.Main(jc, s.ctx, []string{"--version"})
+	c.Check(code, gc.Equals, 0)
+	c.Assert(cmdtesting.Stdout(s.ctx), gc.Equals, "1.2.3\n")
+}
+
+func (s *VersionSuite) TestCurrentBuildInfo(c *gc.C) {
+	s.patchBuildInfo(c)
+
+	c.Assert(cmd.CurrentBuildInfo(""), gc.Equals, cmd.BuildInfo{
+		Version:   "1.2.3",
+		GitCommit: "46f1a0bd5592a2f9244ca321b129902a06b53e03",
+		BuildDate: "2022-06-01T12:00:00Z",
+		GoVersion: runtime.Version(),
+	})
+}